@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"os"
+
+	cfg "github.com/ch0ppy35/sherlock/internal/config"
+	"github.com/ch0ppy35/sherlock/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var lintConfigFile string
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:                   "config",
+	DisableFlagsInUseLine: true,
+	Short:                 "Work with test config files",
+	Long: `The config command checks test config files without running any tests.
+
+Examples:
+  sherlock config lint --config path/to/config.yaml`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Usage()
+		os.Exit(1)
+	},
+}
+
+// configLintCmd represents the config lint command
+var configLintCmd = &cobra.Command{
+	Use:                   "lint --config <path/to/config.yaml>",
+	DisableFlagsInUseLine: true,
+	Example:               "sherlock config lint --config path/to/config.yaml",
+	Short:                 "Check a config for mistakes before running it",
+	Long: `Check a config for mistakes that would otherwise only show up as test failures, or as tests
+passing for the wrong reason. Without querying any server, lint reports:
+
+  - validation errors, the same ones dns run stops at
+  - unknown testType values
+  - invalid IPs in the expected values of a and aaaa tests
+  - names in cname, mx and ns expectations missing their trailing dot
+  - duplicate tests
+  - tests of the same host and type that can't pass together
+  - cname tests alongside tests of other records for the same name, which DNS doesn't allow
+
+Exits with status 1 when any errors are found, warnings alone don't fail.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		lintConfig()
+	},
+}
+
+func lintConfig() {
+	config, err := cfg.ReadDNSRecordsFullTestConfig(lintConfigFile)
+	if err != nil {
+		ui.PrintErrMsgWithStatus("ERROR", "hiRed", "Trouble loading the config: %v\n", err)
+		os.Exit(1)
+	}
+
+	errors, warnings := 0, 0
+	for _, issue := range cfg.Lint(config) {
+		if issue.Severity == cfg.LintError {
+			errors++
+			ui.PrintErrMsgWithStatus("ERROR", "hiRed", "%s\n", issue)
+			continue
+		}
+		warnings++
+		ui.PrintErrMsgWithStatus("WARN", "hiYellow", "%s\n", issue)
+	}
+
+	if errors+warnings == 0 {
+		ui.PrintMsgWithStatus("INFO", "magenta", "No issues found in %s\n", lintConfigFile)
+		return
+	}
+	ui.PrintMsgWithStatus("INFO", "magenta", "Found %d errors and %d warnings in %s\n", errors, warnings, lintConfigFile)
+	if errors > 0 {
+		os.Exit(1)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configLintCmd)
+
+	configLintCmd.Flags().StringVarP(&lintConfigFile, "config", "c", "", "Path to the config file (config/config.yaml)")
+	configLintCmd.MarkFlagRequired("config")
+}
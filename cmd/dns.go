@@ -1,11 +1,17 @@
 package cmd
 
 import (
+	"context"
 	"os"
+	"time"
 
+	"github.com/ch0ppy35/sherlock/internal/tracing"
+	"github.com/ch0ppy35/sherlock/internal/ui"
 	"github.com/spf13/cobra"
 )
 
+var otlpEndpoint string
+
 // dnsCmd represents the dns command
 var dnsCmd = &cobra.Command{
 	Use:                   "dns",
@@ -23,6 +29,29 @@ Examples:
 	},
 }
 
+// startTracing sets up OTLP trace export when enabled and returns a func that flushes the pending spans
+func startTracing() func() {
+	if !tracing.Enabled(otlpEndpoint) {
+		return func() {}
+	}
+
+	shutdown, err := tracing.Setup(context.Background(), otlpEndpoint, version)
+	if err != nil {
+		ui.PrintErrMsgWithStatus("WARN", "hiYellow", "Tracing disabled: %v\n", err)
+		return func() {}
+	}
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			ui.PrintErrMsgWithStatus("WARN", "hiYellow", "Trouble exporting traces: %v\n", err)
+		}
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(dnsCmd)
+
+	dnsCmd.PersistentFlags().StringVar(&otlpEndpoint, "otlp-endpoint", "", "OTLP/HTTP endpoint to export traces to (e.g., http://localhost:4318), OTEL_EXPORTER_OTLP_* env vars are also honored")
 }
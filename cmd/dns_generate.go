@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	cfg "github.com/ch0ppy35/sherlock/internal/config"
+	"github.com/ch0ppy35/sherlock/internal/ui"
+	d "github.com/miekg/dns"
+	"github.com/spf13/cobra"
+)
+
+// generateCmd represents the generate command
+var generateCmd = &cobra.Command{
+	Use:                   "generate --hosts <hosts.txt> --server <dns-server> [--types <a,cname,mx,...>]",
+	DisableFlagsInUseLine: true,
+	Example:               "sherlock dns generate --hosts hosts.txt --types a,cname,mx --server 1.1.1.1 > config.yaml",
+	Short:                 "Generate a test config from the records hosts currently resolve to",
+	Long: `Query each host in the hosts file and write a config to stdout that expects the records
+the server currently answers with. Run it once to baseline an environment, then use the
+config with "sherlock dns run" to catch any drift afterwards.
+
+The hosts file lists one host per line, blank lines and lines starting with # are ignored.
+Hosts without records of a type get no test for it, they're listed on stderr.
+
+Flags:
+	--hosts string   File listing the hosts to generate tests for
+	--server string  The DNS server to query (e.g., 1.1.1.1)
+	--types strings  Comma-separated record types to generate tests for (default a,aaaa,cname,mx,txt,ns)`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runGenerate(cmd); err != nil {
+			ui.PrintErrMsgWithStatus("ERROR", "hiRed", "Trouble generating the config: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func runGenerate(cmd *cobra.Command) error {
+	hostsFile, _ := cmd.Flags().GetString("hosts")
+	dnsServer, _ := cmd.Flags().GetString("server")
+	testTypes, _ := cmd.Flags().GetStringSlice("types")
+	if hostsFile == "" || dnsServer == "" {
+		return fmt.Errorf("flags --hosts and --server are required")
+	}
+
+	hosts, err := readHosts(hostsFile)
+	if err != nil {
+		return err
+	}
+
+	config, skipped, err := cfg.GenerateDNSRecordsFullTestConfig(context.Background(), new(d.Client), dnsServer, hosts, testTypes)
+	if err != nil {
+		return err
+	}
+	for _, entry := range skipped {
+		ui.PrintErrMsgWithStatus("INFO", "magenta", "No records for %s, skipping\n", entry)
+	}
+
+	data, err := cfg.MarshalDNSRecordsFullTestConfig(config)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stdout.Write(data); err != nil {
+		return err
+	}
+	ui.PrintErrMsgWithStatus("INFO", "magenta", "Generated %d tests for %d hosts\n", len(config.Tests), len(hosts))
+	return nil
+}
+
+// readHosts reads one host per line, skipping blank lines and comments
+func readHosts(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var hosts []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		hosts = append(hosts, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return hosts, nil
+}
+
+func init() {
+	dnsCmd.AddCommand(generateCmd)
+
+	generateCmd.Flags().String("hosts", "", "File listing the hosts to generate tests for")
+	generateCmd.Flags().StringP("server", "s", "", "DNS server to query (e.g., 1.1.1.1)")
+	generateCmd.Flags().StringSliceP("types", "t", []string{"a", "aaaa", "cname", "mx", "txt", "ns"}, "Record types to generate tests for, comma-separated")
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	cfg "github.com/ch0ppy35/sherlock/internal/config"
+	"github.com/ch0ppy35/sherlock/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// importCmd represents the import command
+var importCmd = &cobra.Command{
+	Use:                   "import (--zonefile <db.example.com> | --route53 <file> | --octodns <file> | --terraform <file>) [--origin <zone>] [--server <dns-server>] [--run]",
+	DisableFlagsInUseLine: true,
+	Example: `sherlock dns import --zonefile db.example.com --origin example.com --server ns1.example.com --run
+aws route53 list-resource-record-sets --hosted-zone-id Z0123456789ABC > records.json
+sherlock dns import --route53 records.json --server 1.1.1.1 --run
+terraform show -json > state.json && sherlock dns import --terraform state.json > config.yaml`,
+	Short: "Generate tests from a zone file or a DNS provider's export, or run them directly",
+	Long: `Parse a BIND format zone file, or the records a DNS provider manages, and build a test for every
+name and type pair in it, expecting exactly the values in the file. The config is written to stdout,
+or with --run the tests are run against --server straight away, so CI can verify the deployed
+servers serve what is in the repo.
+
+Provider exports are read from local files:
+	--route53    The output of aws route53 list-resource-record-sets
+	--octodns    An OctoDNS zone file, the zone is taken from the file name unless --origin is set
+	--terraform  The output of terraform show -json, for its aws_route53_record resources
+
+Relative names are completed with --origin, the zone is named by its SOA when it isn't set.
+Records of unsupported types, wildcards, aliases and names delegated to child zones get no test,
+they're listed on stderr. Records with a routing policy get a test passing on any of their values.
+
+Flags:
+	--zonefile string   The zone file to import
+	--route53 string    A Route53 record sets export to import
+	--octodns string    An OctoDNS zone file to import
+	--terraform string  Terraform JSON output to import
+	--origin string     The zone's origin (e.g., example.com)
+	--server string     The DNS server the tests query (e.g., ns1.example.com), required with --run
+	--run               Run the tests instead of writing the config`,
+	Run: func(cmd *cobra.Command, args []string) {
+		config, err := importRecords(cmd)
+		if err != nil {
+			ui.PrintErrMsgWithStatus("ERROR", "hiRed", "Trouble importing the records: %v\n", err)
+			os.Exit(1)
+		}
+
+		if run, _ := cmd.Flags().GetBool("run"); run {
+			runConfig(config)
+			return
+		}
+
+		data, err := cfg.MarshalDNSRecordsFullTestConfig(config)
+		if err != nil {
+			ui.PrintErrMsgWithStatus("ERROR", "hiRed", "Trouble writing the config: %v\n", err)
+			os.Exit(1)
+		}
+		os.Stdout.Write(data)
+	},
+}
+
+func importRecords(cmd *cobra.Command) (cfg.DNSRecordsFullTestConfig, error) {
+	origin, _ := cmd.Flags().GetString("origin")
+	dnsServer, _ := cmd.Flags().GetString("server")
+	run, _ := cmd.Flags().GetBool("run")
+
+	var path string
+	var importer func(f *os.File) ([]cfg.DNSTestConfig, []string, error)
+	for _, format := range []string{"zonefile", "route53", "octodns", "terraform"} {
+		file, _ := cmd.Flags().GetString(format)
+		if file == "" {
+			continue
+		}
+		if path != "" {
+			return cfg.DNSRecordsFullTestConfig{}, fmt.Errorf("only one of --zonefile, --route53, --octodns and --terraform can be set")
+		}
+		path = file
+		switch format {
+		case "zonefile":
+			importer = func(f *os.File) ([]cfg.DNSTestConfig, []string, error) { return cfg.ImportZoneFile(f, origin, path) }
+		case "route53":
+			importer = func(f *os.File) ([]cfg.DNSTestConfig, []string, error) { return cfg.ImportRoute53(f, origin) }
+		case "octodns":
+			if origin == "" {
+				// OctoDNS names zone files after the zone, e.g. example.com.yaml
+				origin = strings.TrimSuffix(strings.TrimSuffix(filepath.Base(path), ".yaml"), ".yml")
+			}
+			importer = func(f *os.File) ([]cfg.DNSTestConfig, []string, error) { return cfg.ImportOctoDNS(f, origin) }
+		case "terraform":
+			importer = func(f *os.File) ([]cfg.DNSTestConfig, []string, error) { return cfg.ImportTerraform(f, origin) }
+		}
+	}
+	if path == "" {
+		return cfg.DNSRecordsFullTestConfig{}, fmt.Errorf("one of --zonefile, --route53, --octodns or --terraform is required")
+	}
+	if run && dnsServer == "" {
+		return cfg.DNSRecordsFullTestConfig{}, fmt.Errorf("flag --server is required with --run")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return cfg.DNSRecordsFullTestConfig{}, err
+	}
+	defer f.Close()
+
+	tests, skipped, err := importer(f)
+	if err != nil {
+		return cfg.DNSRecordsFullTestConfig{}, err
+	}
+	for _, entry := range skipped {
+		ui.PrintErrMsgWithStatus("INFO", "magenta", "Skipping %s\n", entry)
+	}
+	ui.PrintErrMsgWithStatus("INFO", "magenta", "Imported %d tests from %s\n", len(tests), path)
+
+	config := cfg.DNSRecordsFullTestConfig{DNSServer: dnsServer, Tests: tests}
+	if run {
+		if err := config.Validate(); err != nil {
+			return cfg.DNSRecordsFullTestConfig{}, fmt.Errorf("validation issue: %w", err)
+		}
+	}
+	return config, nil
+}
+
+func init() {
+	dnsCmd.AddCommand(importCmd)
+
+	importCmd.Flags().StringP("zonefile", "f", "", "The zone file to import")
+	importCmd.Flags().String("route53", "", "A Route53 record sets export to import, from aws route53 list-resource-record-sets")
+	importCmd.Flags().String("octodns", "", "An OctoDNS zone file to import")
+	importCmd.Flags().String("terraform", "", "Terraform JSON output to import, from terraform show -json")
+	importCmd.Flags().String("origin", "", "The zone's origin (e.g., example.com), defaults to the owner of the SOA record, or the file name with --octodns")
+	importCmd.Flags().StringP("server", "s", "", "The DNS server the tests query (e.g., ns1.example.com), required with --run")
+	importCmd.Flags().Bool("run", false, "Run the tests instead of writing the config")
+}
@@ -2,15 +2,24 @@ package cmd
 
 import (
 	"os"
+	"time"
 
 	cfg "github.com/ch0ppy35/sherlock/internal/config"
+	"github.com/ch0ppy35/sherlock/internal/dns"
 	dtexc "github.com/ch0ppy35/sherlock/internal/dns_test_executor"
+	"github.com/ch0ppy35/sherlock/internal/metrics"
+	"github.com/ch0ppy35/sherlock/internal/notify"
 	"github.com/ch0ppy35/sherlock/internal/ui"
-	"github.com/miekg/dns"
+	d "github.com/miekg/dns"
 	"github.com/spf13/cobra"
 )
 
-var configFile string
+var (
+	configFile  string
+	pushGateway string
+	statsdAddr  string
+	strictRun   bool
+)
 
 // runCmd represents the run command
 var runCmd = &cobra.Command{
@@ -24,7 +33,10 @@ all tests are run in a single execution without needing to rerun the tool.
 
 The configuration file should be in YAML format and specify the expected DNS records for
 the hosts to be tested. The tests will verify if the actual DNS records match the expected
-values and report any discrepancies after all tests are completed.`,
+values and report any discrepancies after all tests are completed.
+
+Since a one-shot run exits before it could be scraped, the results and timings can be sent
+before exiting with --push-gateway (Prometheus Pushgateway URL) and/or --statsd (UDP host:port).`,
 	Run: func(cmd *cobra.Command, args []string) {
 		runTests()
 	},
@@ -36,18 +48,82 @@ func runTests() {
 		ui.PrintErrMsgWithStatus("ERROR", "hiRed", "Trouble loading the config: %v\n", err)
 		os.Exit(1)
 	}
-	client := new(dns.Client)
+	runConfig(config)
+}
+
+// runConfig runs the config's tests, publishes and sends the results and exits with status 1 when any test fails
+func runConfig(config cfg.DNSRecordsFullTestConfig) {
+	if strictRun {
+		for i := range config.Tests {
+			config.Tests[i].Strict = true
+		}
+	}
+	stopTracing := startTracing()
+
+	client, err := newDNSClient(config)
+	if err != nil {
+		ui.PrintErrMsgWithStatus("ERROR", "hiRed", "Trouble setting up the DNS client: %v\n", err)
+		os.Exit(1)
+	}
+	var m *metrics.Metrics
+	if pushGateway != "" {
+		m = metrics.NewMetrics()
+		client = m.InstrumentClient(client)
+	}
+
 	executor := dtexc.NewDNSTestExecutor(config, client)
 	err = executor.RunAllTests()
+	stopTracing()
+	publishResults(m, executor.TestResults)
+	if len(config.Webhooks) > 0 {
+		if err := notify.NewNotifier(config.Webhooks).Notify(executor.TestResults); err != nil {
+			ui.PrintErrMsgWithStatus("WARN", "hiYellow", "Trouble sending notifications: %v\n", err)
+		}
+	}
 	if err != nil {
 		ui.PrintErrMsgWithStatus("FAIL", "hiRed", "One or more tests failed, check above\n")
 		os.Exit(1)
 	}
 }
 
+// newDNSClient returns the client used to query the config's DNS server, signing the queries when TSIG is configured,
+// or answering from the zone file when the server is a file:// URL
+func newDNSClient(config cfg.DNSRecordsFullTestConfig) (dns.IDNSClient, error) {
+	if dns.IsZoneFileURL(config.DNSServer) {
+		return dns.NewZoneClientFromURL(config.DNSServer)
+	}
+	client := new(d.Client)
+	if config.TSIG == nil {
+		return client, nil
+	}
+	key, err := config.TSIG.Key()
+	if err != nil {
+		return nil, err
+	}
+	return dns.NewTSIGClient(client, key), nil
+}
+
+// publishResults sends the run's results to the configured Pushgateway and StatsD server
+func publishResults(m *metrics.Metrics, results []dtexc.TestResult) {
+	if m != nil {
+		m.RecordRun(results, time.Now())
+		if err := m.Push(pushGateway, "sherlock"); err != nil {
+			ui.PrintErrMsgWithStatus("WARN", "hiYellow", "%v\n", err)
+		}
+	}
+	if statsdAddr != "" {
+		if err := metrics.SendStatsD(statsdAddr, results); err != nil {
+			ui.PrintErrMsgWithStatus("WARN", "hiYellow", "%v\n", err)
+		}
+	}
+}
+
 func init() {
 	dnsCmd.AddCommand(runCmd)
 
 	runCmd.PersistentFlags().StringVarP(&configFile, "config", "c", "", "Path to the config file (config/config.yaml)")
+	runCmd.PersistentFlags().StringVar(&pushGateway, "push-gateway", "", "Prometheus Pushgateway URL to push the results to (e.g., http://localhost:9091)")
+	runCmd.PersistentFlags().StringVar(&statsdAddr, "statsd", "", "StatsD address to send the results to (e.g., localhost:8125)")
+	runCmd.PersistentFlags().BoolVar(&strictRun, "strict", false, "Compare values byte for byte instead of normalizing names and IPs first")
 	runCmd.MarkPersistentFlagRequired("config")
 }
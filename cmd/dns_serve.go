@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/ch0ppy35/sherlock/internal/dns"
+	"github.com/ch0ppy35/sherlock/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// dnsServeCmd represents the dns serve command
+var dnsServeCmd = &cobra.Command{
+	Use:                   "serve --zone <zonefile> [--zone <zonefile>...] [--listen 127.0.0.1:5353]",
+	DisableFlagsInUseLine: true,
+	Example:               "sherlock dns serve --zone db.example.com --listen 127.0.0.1:5353 --latency 50ms --servfail-rate 0.1",
+	Short:                 "Serve zone files from a small authoritative DNS server",
+	Long: `Start an authoritative DNS server answering from zone files over UDP and TCP, to rehearse
+configs locally. Point a config's dnsServer at the listen address, e.g. 127.0.0.1:5353.
+
+Each zone is named by its SOA record, append ?origin=example.com to the path when the file uses
+relative names without a $ORIGIN. Faults can be injected to see how tests behave when a server
+is slow, failing or truncating its answers.
+
+Flags:
+	--zone strings         Zone files to serve, can be repeated
+	--listen string        Address to listen on (default 127.0.0.1:5353)
+	--latency duration     Delay every response by this long (e.g., 50ms)
+	--servfail-rate float  Fraction of queries answered with SERVFAIL, between 0 and 1
+	--truncate             Set TC on every UDP response and leave out its records, answers over TCP
+	                       are whole. Sherlock doesn't retry over TCP, so its tests see TC and no records`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := serveZones(cmd); err != nil {
+			ui.PrintErrMsgWithStatus("ERROR", "hiRed", "Trouble serving the zones: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func serveZones(cmd *cobra.Command) error {
+	zoneFiles, _ := cmd.Flags().GetStringSlice("zone")
+	listen, _ := cmd.Flags().GetString("listen")
+	var options dns.ServerOptions
+	options.Latency, _ = cmd.Flags().GetDuration("latency")
+	options.ServFailRate, _ = cmd.Flags().GetFloat64("servfail-rate")
+	options.Truncate, _ = cmd.Flags().GetBool("truncate")
+	if len(zoneFiles) == 0 {
+		return fmt.Errorf("flag --zone is required")
+	}
+
+	var zones []*dns.ZoneSnapshot
+	for _, zoneFile := range zoneFiles {
+		path, origin, err := dns.ParseZoneFileURL(dns.ZoneFileScheme + zoneFile)
+		if err != nil {
+			return err
+		}
+		zone, err := dns.LoadZoneFile(path, origin)
+		if err != nil {
+			return err
+		}
+		ui.PrintMsgWithStatus("INFO", "magenta", "Loaded %d records of %s from %s\n", len(zone.Records), zone.Zone, path)
+		zones = append(zones, zone)
+	}
+
+	server, err := dns.NewServer(dns.NewZoneClient(zones...), options)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := server.Start(listen); err != nil {
+		return err
+	}
+	ui.PrintMsgWithStatus("INFO", "magenta", "Serving DNS on %s (udp and tcp)\n", server.Addr)
+
+	<-ctx.Done()
+	return server.Shutdown()
+}
+
+func init() {
+	dnsCmd.AddCommand(dnsServeCmd)
+
+	dnsServeCmd.Flags().StringSliceP("zone", "z", nil, "Zone files to serve, can be repeated")
+	dnsServeCmd.Flags().StringP("listen", "l", "127.0.0.1:5353", "Address to listen on")
+	dnsServeCmd.Flags().Duration("latency", 0, "Delay every response by this long (e.g., 50ms)")
+	dnsServeCmd.Flags().Float64("servfail-rate", 0, "Fraction of queries answered with SERVFAIL, between 0 and 1")
+	dnsServeCmd.Flags().Bool("truncate", false, "Set TC on every UDP response and leave out its records, TCP answers are whole")
+}
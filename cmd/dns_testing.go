@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
 
@@ -29,7 +30,9 @@ Flags:
 	--type string      The type of DNS record to query (e.g., a, aaaa, cname, mx, txt, ns)
 	--host string      The hostname to look up (e.g., example.com)
 	--expected string  Comma-separated list of expected DNS records
-	--server string    The DNS server to query (e.g., 1.1.1.1)`,
+	--server string    The DNS server to query (e.g., 1.1.1.1)
+	--strict           Compare values byte for byte instead of normalizing names and IPs first
+	--ecs string       EDNS client subnet to send with the query (e.g., 203.0.113.0/24)`,
 	Run: func(cmd *cobra.Command, args []string) {
 		testType, expectedValues, dnsServer, host, err := parseFlags(cmd)
 		if err != nil {
@@ -38,7 +41,9 @@ Flags:
 			os.Exit(1)
 		}
 
-		if err := runDNSQueryAndCompare(testType, expectedValues, dnsServer, host); err != nil {
+		strict, _ := cmd.Flags().GetBool("strict")
+		ecs, _ := cmd.Flags().GetString("ecs")
+		if err := runDNSQueryAndCompare(testType, expectedValues, dnsServer, host, strict, ecs); err != nil {
 			ui.PrintErrMsgWithStatus("FAIL", "red", "Test failed: %v\n", err)
 			os.Exit(1)
 		} else {
@@ -60,15 +65,42 @@ func parseFlags(cmd *cobra.Command) (string, []string, string, string, error) {
 	return testType, expectedValues, dnsServer, host, nil
 }
 
-func runDNSQueryAndCompare(testType string, expectedValues []string, dnsServer, domain string) error {
+func runDNSQueryAndCompare(testType string, expectedValues []string, dnsServer, domain string, strict bool, ecs string) error {
 	client := new(d.Client)
 
-	actualValues, err := dns.QueryAndExtract(client, testType, dnsServer, domain)
+	qtype, err := dns.GetQueryTypeFromString(testType)
+	if err != nil {
+		return fmt.Errorf("invalid query type: %v", err)
+	}
+
+	var opts []dns.QueryOption
+	if ecs != "" {
+		prefix, err := dns.ParseClientSubnet(ecs)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, dns.WithClientSubnet(prefix))
+	}
+
+	records, err := dns.QueryDNSContext(context.Background(), domain, dnsServer, client, opts...)
 	if err != nil {
 		return fmt.Errorf("error querying DNS: %v", err)
 	}
 
-	if err := dns.CompareRecords(expectedValues, actualValues); err != nil {
+	if ecs != "" {
+		if scope, ok := records.ClientSubnetScopes[qtype]; ok {
+			ui.PrintMsgWithStatus("INFO", "magenta", "Client subnet %s answered with scope /%d\n", ecs, scope)
+		} else {
+			ui.PrintErrMsgWithStatus("WARN", "hiYellow", "The server didn't return a client subnet option\n")
+		}
+	}
+
+	actualValues, err := dns.ExtractComparableRecords(records, testType, expectedValues, false)
+	if err != nil {
+		return fmt.Errorf("failed to extract records: %v", err)
+	}
+
+	if err := dns.CompareRecords(expectedValues, actualValues, dns.CompareOptions{RecordType: testType, Strict: strict}); err != nil {
 		return fmt.Errorf("DNS comparison failed: %v", err)
 	}
 
@@ -82,4 +114,6 @@ func init() {
 	testCmd.Flags().StringSliceP("expected", "e", []string{}, "Expected DNS records, comma-separated")
 	testCmd.Flags().StringP("server", "s", "", "DNS server to query (e.g., 1.1.1.1)")
 	testCmd.Flags().StringP("host", "H", "", "The host you want to look up (e.g., example.com)")
+	testCmd.Flags().Bool("strict", false, "Compare values byte for byte instead of normalizing names and IPs first")
+	testCmd.Flags().String("ecs", "", "EDNS client subnet to send with the query (e.g., 203.0.113.0/24)")
 }
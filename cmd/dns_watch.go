@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	cfg "github.com/ch0ppy35/sherlock/internal/config"
+	dtexc "github.com/ch0ppy35/sherlock/internal/dns_test_executor"
+	"github.com/ch0ppy35/sherlock/internal/metrics"
+	"github.com/ch0ppy35/sherlock/internal/notify"
+	"github.com/ch0ppy35/sherlock/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	watchConfigFile string
+	watchInterval   time.Duration
+	metricsListen   string
+)
+
+// watchCmd represents the watch command
+var watchCmd = &cobra.Command{
+	Use:                   "watch --config <path/to/config.yaml> [--interval 1m] [--metrics-listen :9090]",
+	DisableFlagsInUseLine: true,
+	Example:               "sherlock dns watch --config path/to/config.yaml --interval 30s --metrics-listen :9090",
+	Short:                 "Continuously run DNS tests and expose the results as Prometheus metrics",
+	Long: `Run the DNS tests from the configuration file on a fixed interval until interrupted.
+
+The results of every run are exposed on /metrics in the Prometheus exposition format:
+
+  sherlock_dns_test_passing{host,type,server,variant}                   1 if the test passed on the last run
+  sherlock_dns_test_last_run_timestamp_seconds{host,type,server,variant} when the test last ran
+  sherlock_dns_test_duration_seconds{host,type,server,variant}          time spent on the test's query or check
+  sherlock_dns_query_duration_seconds{type,server}                      query round trip time histogram
+  sherlock_dns_query_responses_total{type,server,rcode}                 responses received by rcode
+  sherlock_dns_last_run_timestamp_seconds                               when the last run completed
+
+variant tells apart tests of the same host and type by their selector, ecs and senderIP.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		watchTests()
+	},
+}
+
+func watchTests() {
+	if watchInterval <= 0 {
+		ui.PrintErrMsgWithStatus("ERROR", "hiRed", "Flag --interval must be positive, got %s\n", watchInterval)
+		os.Exit(1)
+	}
+
+	config, err := cfg.LoadDNSRecordsFullTestConfig(watchConfigFile)
+	if err != nil {
+		ui.PrintErrMsgWithStatus("ERROR", "hiRed", "Trouble loading the config: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	stopTracing := startTracing()
+	defer stopTracing()
+
+	notifier := notify.NewNotifier(config.Webhooks)
+	m := metrics.NewMetrics()
+	dnsClient, err := newDNSClient(config)
+	if err != nil {
+		ui.PrintErrMsgWithStatus("ERROR", "hiRed", "Trouble setting up the DNS client: %v\n", err)
+		os.Exit(1)
+	}
+	client := m.InstrumentClient(dnsClient)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.Handler())
+	server := &http.Server{Addr: metricsListen, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			ui.PrintErrMsgWithStatus("ERROR", "hiRed", "Metrics server failed: %v\n", err)
+			os.Exit(1)
+		}
+	}()
+	ui.PrintMsgWithStatus("INFO", "magenta", "Serving metrics on %s/metrics\n", metricsListen)
+
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	for {
+		executor := dtexc.NewDNSTestExecutor(config, client)
+		if err := executor.RunAllTests(); err != nil {
+			ui.PrintErrMsgWithStatus("FAIL", "hiRed", "One or more tests failed, check above\n")
+		}
+		m.RecordRun(executor.TestResults, time.Now())
+		if err := notifier.Notify(executor.TestResults); err != nil {
+			ui.PrintErrMsgWithStatus("WARN", "hiYellow", "Trouble sending notifications: %v\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = server.Shutdown(shutdownCtx)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func init() {
+	dnsCmd.AddCommand(watchCmd)
+
+	watchCmd.PersistentFlags().StringVarP(&watchConfigFile, "config", "c", "", "Path to the config file (config/config.yaml)")
+	watchCmd.PersistentFlags().DurationVarP(&watchInterval, "interval", "i", time.Minute, "How often to run the tests")
+	watchCmd.PersistentFlags().StringVar(&metricsListen, "metrics-listen", ":9090", "Address to serve Prometheus metrics on")
+	watchCmd.MarkPersistentFlagRequired("config")
+}
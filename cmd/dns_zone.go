@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	cfg "github.com/ch0ppy35/sherlock/internal/config"
+	"github.com/ch0ppy35/sherlock/internal/dns"
+	"github.com/ch0ppy35/sherlock/internal/ui"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// zoneCmd represents the zone command
+var zoneCmd = &cobra.Command{
+	Use:                   "zone",
+	DisableFlagsInUseLine: true,
+	Short:                 "Snapshot zones with AXFR and compare snapshots",
+	Long: `The zone command pulls full copies of zones with AXFR and compares them, which gives
+change auditing on zones that aren't managed through code.
+
+Examples:
+  sherlock dns zone snapshot --zone example.com --server ns1.example.com --output example.com.zone
+  sherlock dns zone diff example.com.zone.old example.com.zone`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Usage()
+		os.Exit(1)
+	},
+}
+
+// zoneSnapshotCmd represents the zone snapshot command
+var zoneSnapshotCmd = &cobra.Command{
+	Use:                   "snapshot --zone <zone> --server <dns-server> [--output <file>]",
+	DisableFlagsInUseLine: true,
+	Example:               "sherlock dns zone snapshot --zone example.com --server ns1.example.com --tsig-name transfer --tsig-secret-env TSIG_SECRET",
+	Short:                 "Save a normalized, sorted copy of a zone pulled with AXFR",
+	Long: `Pull a full copy of the zone from the server with AXFR and save it in zone file format with
+one record per line. Owner names are lowercased and the records are sorted by name, type and
+value, so two snapshots of an unchanged zone are identical.
+
+Most servers only allow transfers signed with a TSIG key, the secret is read from an
+environment variable or a file and never passed on the command line.
+
+Flags:
+	--zone string              The zone to transfer (e.g., example.com)
+	--server string            The server to transfer the zone from (e.g., ns1.example.com)
+	--output string            File to save the snapshot to, defaults to stdout
+	--tsig-name string         Name of the TSIG key to sign the transfer with
+	--tsig-algorithm string    Algorithm of the TSIG key (default hmac-sha256)
+	--tsig-secret-env string   Environment variable holding the base64 TSIG secret
+	--tsig-secret-file string  File holding the base64 TSIG secret`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runZoneSnapshot(cmd); err != nil {
+			ui.PrintErrMsgWithStatus("ERROR", "hiRed", "Trouble taking the snapshot: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// zoneDiffCmd represents the zone diff command
+var zoneDiffCmd = &cobra.Command{
+	Use:                   "diff <old-snapshot> <new-snapshot>",
+	DisableFlagsInUseLine: true,
+	Example:               "sherlock dns zone diff example.com.zone.old example.com.zone",
+	Short:                 "Show the RRsets added, removed and changed between two snapshots",
+	Long: `Compare two snapshots of a zone RRset by RRset and show the ones that were added, removed
+or changed. An RRset is changed when any of its values or its TTL differ.
+
+The command exits with status 1 when the snapshots differ, so it can gate a pipeline.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		before, err := readZoneSnapshot(args[0])
+		if err != nil {
+			ui.PrintErrMsgWithStatus("ERROR", "hiRed", "Trouble reading %s: %v\n", args[0], err)
+			os.Exit(1)
+		}
+		after, err := readZoneSnapshot(args[1])
+		if err != nil {
+			ui.PrintErrMsgWithStatus("ERROR", "hiRed", "Trouble reading %s: %v\n", args[1], err)
+			os.Exit(1)
+		}
+		if before.Zone != after.Zone {
+			ui.PrintErrMsgWithStatus("WARN", "hiYellow", "Comparing snapshots of different zones, %s and %s\n", before.Zone, after.Zone)
+		}
+
+		diff := dns.DiffZones(before, after)
+		if diff.Empty() {
+			ui.PrintMsgWithStatus("GOOD", "green", "No changes in %s\n", after.Zone)
+			return
+		}
+		printZoneDiff(os.Stdout, diff)
+		ui.PrintErrMsgWithStatus("INFO", "magenta", "%s: %d added, %d removed, %d changed RRsets\n",
+			after.Zone, len(diff.Added), len(diff.Removed), len(diff.Changed))
+		os.Exit(1)
+	},
+}
+
+func runZoneSnapshot(cmd *cobra.Command) error {
+	zone, _ := cmd.Flags().GetString("zone")
+	dnsServer, _ := cmd.Flags().GetString("server")
+	output, _ := cmd.Flags().GetString("output")
+	if zone == "" || dnsServer == "" {
+		return fmt.Errorf("flags --zone and --server are required")
+	}
+
+	key, err := zoneTSIGKey(cmd)
+	if err != nil {
+		return err
+	}
+
+	stopTracing := startTracing()
+	snapshot, err := dns.TransferZone(context.Background(), zone, dnsServer, key)
+	stopTracing()
+	if err != nil {
+		return err
+	}
+
+	if output == "" {
+		_, err = snapshot.WriteTo(os.Stdout)
+		return err
+	}
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	if _, err := snapshot.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	ui.PrintMsgWithStatus("INFO", "magenta", "Saved %d records of %s to %s\n", len(snapshot.Records), snapshot.Zone, output)
+	return nil
+}
+
+// zoneTSIGKey loads the key set by the TSIG flags, or returns nil when no key name is set
+func zoneTSIGKey(cmd *cobra.Command) (*dns.TSIGKey, error) {
+	var tsig cfg.TSIGConfig
+	tsig.Name, _ = cmd.Flags().GetString("tsig-name")
+	tsig.Algorithm, _ = cmd.Flags().GetString("tsig-algorithm")
+	tsig.SecretEnv, _ = cmd.Flags().GetString("tsig-secret-env")
+	tsig.SecretFile, _ = cmd.Flags().GetString("tsig-secret-file")
+	if tsig.Name == "" {
+		if tsig.SecretEnv != "" || tsig.SecretFile != "" {
+			return nil, fmt.Errorf("--tsig-name must be set with a TSIG secret")
+		}
+		return nil, nil
+	}
+	if tsig.SecretEnv != "" && tsig.SecretFile != "" {
+		return nil, fmt.Errorf("only one of --tsig-secret-env or --tsig-secret-file can be set")
+	}
+
+	key, err := tsig.Key()
+	if err != nil {
+		return nil, fmt.Errorf("tsig %w", err)
+	}
+	return &key, nil
+}
+
+func readZoneSnapshot(path string) (*dns.ZoneSnapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return dns.ReadZoneSnapshot(f)
+}
+
+// printZoneDiff prints each RRset that differs with its old records prefixed with - and its new records with +
+func printZoneDiff(w io.Writer, diff dns.ZoneDiff) {
+	added := color.New(color.FgGreen)
+	removed := color.New(color.FgRed)
+	changed := color.New(color.FgYellow)
+
+	for _, set := range diff.Added {
+		added.Fprintf(w, "+ %s\n", set)
+		for _, rr := range set.Records {
+			added.Fprintf(w, "    + %s\n", rr)
+		}
+	}
+	for _, set := range diff.Removed {
+		removed.Fprintf(w, "- %s\n", set)
+		for _, rr := range set.Records {
+			removed.Fprintf(w, "    - %s\n", rr)
+		}
+	}
+	for _, change := range diff.Changed {
+		changed.Fprintf(w, "~ %s\n", change.New)
+		for _, rr := range change.Old.Records {
+			removed.Fprintf(w, "    - %s\n", rr)
+		}
+		for _, rr := range change.New.Records {
+			added.Fprintf(w, "    + %s\n", rr)
+		}
+	}
+}
+
+func init() {
+	dnsCmd.AddCommand(zoneCmd)
+	zoneCmd.AddCommand(zoneSnapshotCmd)
+	zoneCmd.AddCommand(zoneDiffCmd)
+
+	zoneSnapshotCmd.Flags().StringP("zone", "z", "", "The zone to transfer (e.g., example.com)")
+	zoneSnapshotCmd.Flags().StringP("server", "s", "", "The server to transfer the zone from (e.g., ns1.example.com)")
+	zoneSnapshotCmd.Flags().StringP("output", "o", "", "File to save the snapshot to, defaults to stdout")
+	zoneSnapshotCmd.Flags().String("tsig-name", "", "Name of the TSIG key to sign the transfer with")
+	zoneSnapshotCmd.Flags().String("tsig-algorithm", "", "Algorithm of the TSIG key (default hmac-sha256)")
+	zoneSnapshotCmd.Flags().String("tsig-secret-env", "", "Environment variable holding the base64 TSIG secret")
+	zoneSnapshotCmd.Flags().String("tsig-secret-file", "", "File holding the base64 TSIG secret")
+}
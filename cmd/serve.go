@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ch0ppy35/sherlock/internal/api"
+	"github.com/ch0ppy35/sherlock/internal/ui"
+	"github.com/miekg/dns"
+	"github.com/spf13/cobra"
+)
+
+var serveListen string
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:                   "serve [--listen :8080]",
+	DisableFlagsInUseLine: true,
+	Example:               "sherlock serve --listen :8080",
+	Short:                 "Serve Sherlock's tests over a REST API",
+	Long: `Start an HTTP server exposing Sherlock's tests as a small REST API, so other tools
+can run tests without shelling out and parsing tables.
+
+Endpoints:
+  GET  /healthz       Liveness check
+  POST /v1/dns/test   Run a single test, body: {"type": "a", "host": "example.com", "expected": ["10.0.0.1"], "server": "1.1.1.1"}
+  POST /v1/dns/run    Run every test in a config, body: the config file contents as YAML or JSON
+
+Both test endpoints respond with the structured JSON result of the run.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		serveAPI()
+	},
+}
+
+func serveAPI() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := &http.Server{
+		Addr:              serveListen,
+		Handler:           api.NewServer(new(dns.Client)).Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.ListenAndServe()
+	}()
+	ui.PrintMsgWithStatus("INFO", "magenta", "Serving the API on %s\n", serveListen)
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, http.ErrServerClosed) {
+			ui.PrintErrMsgWithStatus("ERROR", "hiRed", "API server failed: %v\n", err)
+			os.Exit(1)
+		}
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	serveCmd.Flags().StringVarP(&serveListen, "listen", "l", ":8080", "Address to listen on")
+}
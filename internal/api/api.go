@@ -0,0 +1,180 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	cfg "github.com/ch0ppy35/sherlock/internal/config"
+	"github.com/ch0ppy35/sherlock/internal/dns"
+	dtexc "github.com/ch0ppy35/sherlock/internal/dns_test_executor"
+)
+
+// maxBodySize limits how much of a request body is read
+const maxBodySize = 1 << 20
+
+// DNSTestRequest is the body of POST /v1/dns/test, it takes the same fields as `sherlock dns test`
+type DNSTestRequest struct {
+	Type     string   `json:"type"`
+	Host     string   `json:"host"`
+	Expected []string `json:"expected"`
+	Server   string   `json:"server"`
+	ECS      string   `json:"ecs,omitempty"`
+}
+
+// RunResponse is the structured result returned by the test and run endpoints
+type RunResponse struct {
+	Passed  bool         `json:"passed"`
+	Server  string       `json:"server"`
+	Results []TestResult `json:"results"`
+}
+
+// TestResult is the JSON representation of a single test's outcome
+type TestResult struct {
+	Host       string   `json:"host"`
+	Type       string   `json:"type"`
+	Server     string   `json:"server"`
+	Variant    string   `json:"variant,omitempty"`
+	Passed     bool     `json:"passed"`
+	DurationMs int64    `json:"durationMs"`
+	Expected   []string `json:"expected"`
+	Matched    []string `json:"matched"`
+	Missing    []string `json:"missing"`
+	Unexpected []string `json:"unexpected"`
+	Error      string   `json:"error,omitempty"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Server exposes sherlock's DNS tests over HTTP
+type Server struct {
+	Client dns.IDNSClient
+}
+
+func NewServer(client dns.IDNSClient) *Server {
+	return &Server{Client: client}
+}
+
+// Handler returns the routes served by the API
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", s.handleHealthz)
+	mux.HandleFunc("POST /v1/dns/test", s.handleDNSTest)
+	mux.HandleFunc("POST /v1/dns/run", s.handleDNSRun)
+	return mux
+}
+
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (s *Server) handleDNSTest(w http.ResponseWriter, r *http.Request) {
+	var req DNSTestRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxBodySize)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("invalid request body: %v", err)})
+		return
+	}
+
+	if req.Type == "" || len(req.Expected) == 0 || req.Server == "" || req.Host == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "all fields (type, host, expected, server) are required"})
+		return
+	}
+	if _, err := dns.GetQueryTypeFromString(req.Type); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	if dns.IsZoneFileURL(req.Server) {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "zone file servers aren't supported over the API"})
+		return
+	}
+	if req.ECS != "" {
+		if _, err := dns.ParseClientSubnet(req.ECS); err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+			return
+		}
+	}
+
+	config := cfg.DNSRecordsFullTestConfig{
+		DNSServer: req.Server,
+		Tests: []cfg.DNSTestConfig{
+			{Host: req.Host, TestType: req.Type, ExpectedValues: req.Expected, ECS: req.ECS},
+		},
+	}
+	writeJSON(w, http.StatusOK, s.run(config))
+}
+
+func (s *Server) handleDNSRun(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("failed to read request body: %v", err)})
+		return
+	}
+
+	config, err := cfg.ParseDNSRecordsFullTestConfig(data)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	// Secrets are read from the server's environment and files, callers can't choose which
+	if config.TSIG != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "tsig isn't supported over the API"})
+		return
+	}
+	// Zone files would be read from the server's disk
+	if dns.IsZoneFileURL(config.DNSServer) {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "zone file servers aren't supported over the API"})
+		return
+	}
+	writeJSON(w, http.StatusOK, s.run(config))
+}
+
+// run executes the tests in the config and converts the outcome to the API response
+func (s *Server) run(config cfg.DNSRecordsFullTestConfig) RunResponse {
+	executor := dtexc.NewDNSTestExecutor(config, s.Client)
+	// The results are only returned as JSON, the CLI's report would end up in the server's log
+	executor.Output = io.Discard
+	err := executor.RunAllTests()
+
+	resp := RunResponse{
+		Passed:  err == nil,
+		Server:  config.DNSServer,
+		Results: []TestResult{},
+	}
+	for _, result := range executor.TestResults {
+		resp.Results = append(resp.Results, newTestResult(result))
+	}
+	return resp
+}
+
+func newTestResult(result dtexc.TestResult) TestResult {
+	r := TestResult{
+		Host:       result.Host,
+		Type:       result.TestType,
+		Server:     result.Server,
+		Variant:    result.Variant,
+		Passed:     result.Passed,
+		DurationMs: result.Duration.Milliseconds(),
+		Expected:   result.Expected,
+		Matched:    []string{},
+		Missing:    []string{},
+		Unexpected: []string{},
+	}
+	if result.Comparison != nil {
+		r.Matched = result.Comparison.Matched
+		r.Missing = result.Comparison.Missing
+		r.Unexpected = result.Comparison.Unexpected
+	}
+	if result.Err != nil {
+		r.Error = result.Err.Error()
+	}
+	return r
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
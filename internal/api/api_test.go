@@ -0,0 +1,175 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	cfg "github.com/ch0ppy35/sherlock/internal/config"
+	"github.com/ch0ppy35/sherlock/pkg/sherlock/dns/dnstest"
+)
+
+func newTestServer() *httptest.Server {
+	client := dnstest.NewResolver(dnstest.Records("example.com").A("10.0.0.1").Build()...)
+	return httptest.NewServer(NewServer(client).Handler())
+}
+
+func TestHealthz(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/healthz")
+	if err != nil {
+		t.Fatalf("GET /healthz error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("GET /healthz status = %d, expected %d", resp.StatusCode, http.StatusOK)
+	}
+}
+
+func TestDNSEndpoints(t *testing.T) {
+	tests := []struct {
+		name         string
+		path         string
+		body         string
+		wantStatus   int
+		wantResponse *RunResponse
+	}{
+		{
+			name:       "Passing test",
+			path:       "/v1/dns/test",
+			body:       `{"type": "a", "host": "example.com", "expected": ["10.0.0.1"], "server": "8.8.8.8"}`,
+			wantStatus: http.StatusOK,
+			wantResponse: &RunResponse{
+				Passed: true,
+				Server: "8.8.8.8",
+				Results: []TestResult{
+					{Host: "example.com", Type: "a", Server: "8.8.8.8", Passed: true, Expected: []string{"10.0.0.1"}, Matched: []string{"10.0.0.1"}, Missing: []string{}, Unexpected: []string{}},
+				},
+			},
+		},
+		{
+			name:       "Failing test",
+			path:       "/v1/dns/test",
+			body:       `{"type": "a", "host": "example.com", "expected": ["10.0.0.2"], "server": "8.8.8.8"}`,
+			wantStatus: http.StatusOK,
+			wantResponse: &RunResponse{
+				Passed: false,
+				Server: "8.8.8.8",
+				Results: []TestResult{
+					{Host: "example.com", Type: "a", Server: "8.8.8.8", Passed: false, Expected: []string{"10.0.0.2"}, Matched: []string{}, Missing: []string{"10.0.0.2"}, Unexpected: []string{"10.0.0.1"}, Error: "mismatched records found"},
+				},
+			},
+		},
+		{
+			name:       "Test with missing fields",
+			path:       "/v1/dns/test",
+			body:       `{"type": "a", "host": "example.com"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Test with invalid type",
+			path:       "/v1/dns/test",
+			body:       `{"type": "bogus", "host": "example.com", "expected": ["10.0.0.1"], "server": "8.8.8.8"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Test with malformed body",
+			path:       "/v1/dns/test",
+			body:       `{"type":`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Run a YAML config",
+			path:       "/v1/dns/run",
+			body:       "dnsServer: 8.8.8.8\ntests:\n  - host: example.com\n    testType: a\n    expectedValues: [\"10.0.0.1\"]\n",
+			wantStatus: http.StatusOK,
+			wantResponse: &RunResponse{
+				Passed: true,
+				Server: "8.8.8.8",
+				Results: []TestResult{
+					{Host: "example.com", Type: "a", Server: "8.8.8.8", Passed: true, Expected: []string{"10.0.0.1"}, Matched: []string{"10.0.0.1"}, Missing: []string{}, Unexpected: []string{}},
+				},
+			},
+		},
+		{
+			name:       "Run a config with TSIG",
+			path:       "/v1/dns/run",
+			body:       "dnsServer: 8.8.8.8\ntsig:\n  name: sherlock\n  secretEnv: HOME\ntests:\n  - host: example.com\n    testType: a\n    expectedValues: [\"10.0.0.1\"]\n",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Run a config with a zone file server",
+			path:       "/v1/dns/run",
+			body:       "dnsServer: file:///etc/zones/db.example.com\ntests:\n  - host: example.com\n    testType: a\n    expectedValues: [\"10.0.0.1\"]\n",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Run an invalid config",
+			path:       "/v1/dns/run",
+			body:       "dnsServer: 8.8.8.8\n",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer()
+			defer server.Close()
+
+			resp, err := http.Post(server.URL+tt.path, "application/json", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatalf("POST %s error = %v", tt.path, err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("POST %s status = %d, expected %d", tt.path, resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantResponse == nil {
+				return
+			}
+
+			var got RunResponse
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if !reflect.DeepEqual(&got, tt.wantResponse) {
+				t.Errorf("POST %s response = %+v, expected %+v", tt.path, got, tt.wantResponse)
+			}
+		})
+	}
+}
+
+func TestRunIsQuiet(t *testing.T) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create a pipe: %v", err)
+	}
+	stdout, stderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = writer, writer
+	defer func() { os.Stdout, os.Stderr = stdout, stderr }()
+
+	client := dnstest.NewResolver(dnstest.Records("example.com").A("10.0.0.1").Build()...)
+	resp := NewServer(client).run(cfg.DNSRecordsFullTestConfig{
+		DNSServer: "8.8.8.8",
+		Tests:     []cfg.DNSTestConfig{{Host: "example.com", TestType: "a", ExpectedValues: []string{"10.0.0.2"}}},
+	})
+	writer.Close()
+	os.Stdout, os.Stderr = stdout, stderr
+
+	output, _ := io.ReadAll(reader)
+	if len(output) > 0 {
+		t.Errorf("run() printed %q, expected no output", output)
+	}
+	if resp.Passed || len(resp.Results) != 1 {
+		t.Errorf("run() = %+v, expected one failing result", resp)
+	}
+}
@@ -1,24 +1,229 @@
 package config
 
 import (
+	"bytes"
 	"fmt"
+	"maps"
+	"net"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
 
+	"github.com/ch0ppy35/sherlock/internal/dns"
+	"github.com/ch0ppy35/sherlock/internal/mail"
 	"github.com/ch0ppy35/sherlock/internal/ui"
+	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/viper"
 )
 
 type DNSRecordsFullTestConfig struct {
-	DNSServer string          `yaml:"dnsServer"` // Optional
-	Tests     []DNSTestConfig `yaml:"tests"`     // Required
+	DNSServer string          `yaml:"dnsServer,omitempty"` // Optional, a zone file can be used as file://path?origin=zone
+	Tests     []DNSTestConfig `yaml:"tests,omitempty"`     // Required
+	Webhooks  []WebhookConfig `yaml:"webhooks,omitempty"`  // Optional
+	DNSSEC    DNSSECConfig    `yaml:"dnssec,omitempty"`    // Optional
+	TSIG      *TSIGConfig     `yaml:"tsig,omitempty"`      // Optional, signs every query to dnsServer
 }
 
 type DNSTestConfig struct {
-	ExpectedValues []string `yaml:"expectedValues"` // Required
-	Host           string   `yaml:"host"`           // Required
-	TestType       string   `yaml:"testType"`       // Required
+	ExpectedValues []string        `yaml:"expectedValues,omitempty"` // Required, unless minCount or maxCount is set
+	Host           string          `yaml:"host,omitempty"`           // Required
+	TestType       string          `yaml:"testType,omitempty"`       // Required
+	Match          dns.MatchMode   `yaml:"match,omitempty"`          // Optional, one of exact, contains, subsetOf, anyOf, none (default exact)
+	MinCount       *int            `yaml:"minCount,omitempty"`       // Optional
+	MaxCount       *int            `yaml:"maxCount,omitempty"`       // Optional
+	Strict         bool            `yaml:"strict,omitempty"`         // Optional, disables normalizing names and IPs before comparing
+	Ordered        bool            `yaml:"ordered,omitempty"`        // Optional, mx only, asserts the expected values are listed in priority order
+	RawSegments    bool            `yaml:"rawSegments,omitempty"`    // Optional, txt only, compares each character string of a record separately
+	SenderIP       string          `yaml:"senderIP,omitempty"`       // Optional, spf only, the IP whose SPF result is asserted by expectedValues
+	Selector       string          `yaml:"selector,omitempty"`       // Required for dkim tests, the selector of the key
+	DNSSEC         bool            `yaml:"dnssec,omitempty"`         // Optional, validates the DNSSEC chain of trust of the tested records
+	Flags          map[string]bool `yaml:"flags,omitempty"`          // Optional, header flags (aa, ad, ra, tc, cd) that must be set (true) or unset (false)
+	ECS            string          `yaml:"ecs,omitempty"`            // Optional, EDNS client subnet sent with the queries, e.g. 203.0.113.0/24
 }
 
-func (c *DNSRecordsFullTestConfig) validate() error {
+type DNSSECConfig struct {
+	TrustAnchors  []string      `yaml:"trustAnchors,omitempty"`  // Optional, DS or DNSKEY records (default the root zone KSK)
+	ExpiryWarning time.Duration `yaml:"expiryWarning,omitempty"` // Optional, warn when a signature expires sooner than this (default 168h)
+}
+
+// TSIGConfig is the key queries are signed with, the secret is never set inline
+type TSIGConfig struct {
+	Name       string `yaml:"name,omitempty"`       // Required
+	Algorithm  string `yaml:"algorithm,omitempty"`  // Optional, one of hmac-sha1, hmac-sha224, hmac-sha256, hmac-sha384, hmac-sha512 (default hmac-sha256)
+	SecretEnv  string `yaml:"secretEnv,omitempty"`  // Environment variable holding the base64 secret, either this or secretFile is required
+	SecretFile string `yaml:"secretFile,omitempty"` // File holding the base64 secret
+}
+
+// WebhookTemplateFuncs are the functions available to webhook templates besides the builtin ones
+var WebhookTemplateFuncs = template.FuncMap{"join": strings.Join}
+
+type WebhookConfig struct {
+	URL      string `yaml:"url,omitempty"`      // Required
+	Format   string `yaml:"format,omitempty"`   // Optional, one of json, slack, teams, alertmanager (default json)
+	On       string `yaml:"on,omitempty"`       // Optional, one of failure, change (default failure)
+	Template string `yaml:"template,omitempty"` // Optional, Go template used as the body for slack and teams
+}
+
+// Variant tells apart tests of the same host and type by their selector, client subnet and sender IP, e.g.
+// "selector=s1,ecs=203.0.113.0/24". It's empty when none of them is set
+func (t *DNSTestConfig) Variant() string {
+	var parts []string
+	if t.Selector != "" {
+		parts = append(parts, "selector="+t.Selector)
+	}
+	if t.ECS != "" {
+		parts = append(parts, "ecs="+t.ECS)
+	}
+	if t.SenderIP != "" {
+		parts = append(parts, "senderIP="+t.SenderIP)
+	}
+	return strings.Join(parts, ",")
+}
+
+// isCheck reports whether the test is a check built on top of DNS rather than a plain record comparison
+func (t *DNSTestConfig) isCheck() bool {
+	switch strings.ToLower(t.TestType) {
+	case "spf", "dmarc", "dkim", "mta-sts":
+		return true
+	default:
+		return false
+	}
+}
+
+// validateCheck validates the fields specific to checks
+func (t *DNSTestConfig) validateCheck() error {
+	if t.SenderIP != "" && !strings.EqualFold(t.TestType, "spf") {
+		return fmt.Errorf("'senderIP' is only supported for spf tests")
+	}
+
+	if t.Selector != "" && !strings.EqualFold(t.TestType, "dkim") {
+		return fmt.Errorf("'selector' is only supported for dkim tests")
+	}
+
+	switch strings.ToLower(t.TestType) {
+	case "dkim":
+		if t.Selector == "" {
+			return fmt.Errorf("'selector' must be set for dkim tests")
+		}
+		return t.validateTagAssertions()
+	case "dmarc", "mta-sts":
+		return t.validateTagAssertions()
+	case "spf":
+		if t.SenderIP == "" {
+			if len(t.ExpectedValues) > 0 {
+				return fmt.Errorf("'expectedValues' of an spf test are the expected results for 'senderIP', which must be set")
+			}
+			return nil
+		}
+		if net.ParseIP(t.SenderIP) == nil {
+			return fmt.Errorf("'senderIP' '%s' is not a valid IP", t.SenderIP)
+		}
+		if len(t.ExpectedValues) == 0 {
+			return fmt.Errorf("'expectedValues' must list the expected SPF results for 'senderIP'")
+		}
+		for _, val := range t.ExpectedValues {
+			if _, err := mail.ParseSPFResult(val); err != nil {
+				return fmt.Errorf("'expectedValues' is invalid: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// validateTagAssertions checks the expected values of tests that assert on the tags of a record
+func (t *DNSTestConfig) validateTagAssertions() error {
+	for _, val := range t.ExpectedValues {
+		if !strings.Contains(val, "=") {
+			return fmt.Errorf("'expectedValues' of %s tests must be tag=value assertions, got '%s'", strings.ToLower(t.TestType), val)
+		}
+	}
+	return nil
+}
+
+func (d *DNSSECConfig) validate() error {
+	for _, anchor := range d.TrustAnchors {
+		if _, err := dns.ParseTrustAnchor(anchor); err != nil {
+			return fmt.Errorf("'trustAnchors' is invalid: %w", err)
+		}
+	}
+	if d.ExpiryWarning < 0 {
+		return fmt.Errorf("'expiryWarning' can't be negative")
+	}
+	return nil
+}
+
+// validate checks the key's settings, the secret itself is only read when the key is used
+func (t *TSIGConfig) validate() error {
+	if t.Name == "" {
+		return fmt.Errorf("'name' must be set")
+	}
+	if _, err := dns.ParseTSIGAlgorithm(t.Algorithm); err != nil {
+		return fmt.Errorf("'algorithm' is invalid: %w", err)
+	}
+	if (t.SecretEnv == "") == (t.SecretFile == "") {
+		return fmt.Errorf("exactly one of 'secretEnv' or 'secretFile' must be set")
+	}
+	return nil
+}
+
+// Key reads the secret from the environment variable or file and returns the key
+func (t *TSIGConfig) Key() (dns.TSIGKey, error) {
+	var secret string
+	switch {
+	case t.SecretEnv != "":
+		value, ok := os.LookupEnv(t.SecretEnv)
+		if !ok {
+			return dns.TSIGKey{}, fmt.Errorf("environment variable %s isn't set", t.SecretEnv)
+		}
+		secret = value
+	case t.SecretFile != "":
+		data, err := os.ReadFile(t.SecretFile)
+		if err != nil {
+			return dns.TSIGKey{}, fmt.Errorf("failed to read the secret: %w", err)
+		}
+		secret = string(data)
+	default:
+		return dns.TSIGKey{}, fmt.Errorf("one of 'secretEnv' or 'secretFile' must be set")
+	}
+	return dns.NewTSIGKey(t.Name, t.Algorithm, secret)
+}
+
+func (w *WebhookConfig) validate() error {
+	if w.URL == "" {
+		return fmt.Errorf("'url' must be set")
+	}
+
+	if w.Format == "" {
+		w.Format = "json"
+	}
+	switch w.Format {
+	case "json", "slack", "teams", "alertmanager":
+	default:
+		return fmt.Errorf("unsupported format '%s', supported formats: json, slack, teams, alertmanager", w.Format)
+	}
+
+	if w.On == "" {
+		w.On = "failure"
+	}
+	switch w.On {
+	case "failure", "change":
+	default:
+		return fmt.Errorf("unsupported trigger '%s', supported triggers: failure, change", w.On)
+	}
+
+	if w.Template != "" {
+		if _, err := template.New("webhook").Funcs(WebhookTemplateFuncs).Parse(w.Template); err != nil {
+			return fmt.Errorf("'template' is invalid: %w", err)
+		}
+	}
+	return nil
+}
+
+// Validate checks the required fields are set and fills in defaults for the optional ones
+func (c *DNSRecordsFullTestConfig) Validate() error {
 	if len(c.Tests) == 0 {
 		return fmt.Errorf("no tests defined in the configuration")
 	}
@@ -27,9 +232,20 @@ func (c *DNSRecordsFullTestConfig) validate() error {
 		ui.PrintMsgWithStatus("WARN", "hiYellow", "DNS server not set, using Cloudflare as default\n")
 		c.DNSServer = "1.1.1.1"
 	}
+	if dns.IsZoneFileURL(c.DNSServer) {
+		if _, _, err := dns.ParseZoneFileURL(c.DNSServer); err != nil {
+			return fmt.Errorf("'dnsServer' is invalid: %w", err)
+		}
+		if c.TSIG != nil {
+			return fmt.Errorf("tsig can't be used with a zone file 'dnsServer'")
+		}
+	}
 
 	for i, test := range c.Tests {
-		if len(test.ExpectedValues) == 0 {
+		if err := test.validateCheck(); err != nil {
+			return fmt.Errorf("test %d %w", i+1, err)
+		}
+		if len(test.ExpectedValues) == 0 && test.MinCount == nil && test.MaxCount == nil && !test.isCheck() {
 			return fmt.Errorf("test %d 'expectedValues' must be set and contain at least one value", i+1)
 		}
 		if test.Host == "" {
@@ -38,11 +254,157 @@ func (c *DNSRecordsFullTestConfig) validate() error {
 		if test.TestType == "" {
 			return fmt.Errorf("test %d 'testType' must be set", i+1)
 		}
+		for _, val := range test.ExpectedValues {
+			if err := dns.ValidateExpectedValue(val); err != nil {
+				return fmt.Errorf("test %d 'expectedValues' is invalid: %w", i+1, err)
+			}
+		}
+		if strings.EqualFold(test.TestType, "mx") && dns.HasMXPreference(test.ExpectedValues) {
+			for _, val := range test.ExpectedValues {
+				if _, _, hasPref := dns.ParseMXValue(val); !hasPref {
+					return fmt.Errorf("test %d 'expectedValues' must all include a preference when one does, '%s' doesn't", i+1, val)
+				}
+			}
+		}
+		if test.Ordered && !strings.EqualFold(test.TestType, "mx") {
+			return fmt.Errorf("test %d 'ordered' is only supported for mx tests", i+1)
+		}
+		if test.DNSSEC && test.isCheck() {
+			return fmt.Errorf("test %d 'dnssec' is only supported for record tests", i+1)
+		}
+		if len(test.Flags) > 0 && test.isCheck() {
+			return fmt.Errorf("test %d 'flags' is only supported for record tests", i+1)
+		}
+		if test.ECS != "" {
+			if test.isCheck() {
+				return fmt.Errorf("test %d 'ecs' is only supported for record tests", i+1)
+			}
+			if _, err := dns.ParseClientSubnet(test.ECS); err != nil {
+				return fmt.Errorf("test %d 'ecs' is invalid: %w", i+1, err)
+			}
+		}
+		for flag := range test.Flags {
+			if err := dns.ValidateFlag(flag); err != nil {
+				return fmt.Errorf("test %d 'flags' is invalid: %w", i+1, err)
+			}
+		}
+		if test.RawSegments && !strings.EqualFold(test.TestType, "txt") {
+			return fmt.Errorf("test %d 'rawSegments' is only supported for txt tests", i+1)
+		}
+		if _, err := dns.ParseMatchMode(string(test.Match)); err != nil {
+			return fmt.Errorf("test %d 'match' is invalid: %w", i+1, err)
+		}
+		if test.MinCount != nil && test.MaxCount != nil && *test.MinCount > *test.MaxCount {
+			return fmt.Errorf("test %d 'minCount' can't be greater than 'maxCount'", i+1)
+		}
+	}
+
+	if err := c.DNSSEC.validate(); err != nil {
+		return fmt.Errorf("dnssec %w", err)
+	}
+	if c.TSIG != nil {
+		if err := c.TSIG.validate(); err != nil {
+			return fmt.Errorf("tsig %w", err)
+		}
+	}
+
+	for i := range c.Webhooks {
+		if err := c.Webhooks[i].validate(); err != nil {
+			return fmt.Errorf("webhook %d %w", i+1, err)
+		}
 	}
 	return nil
 }
 
+// decodeHook extends viper's default decode hooks so structured {host, pref} MX entries can be used as expected values
+func decodeHook() mapstructure.DecodeHookFunc {
+	return mapstructure.ComposeDecodeHookFunc(
+		mxValueHook,
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+	)
+}
+
+// mxValueHook converts the {host: mail.example.com., pref: 10} expected values of mx tests into the
+// "10 mail.example.com." string form
+func mxValueHook(from reflect.Type, to reflect.Type, data any) (any, error) {
+	if from.Kind() != reflect.Map || to != reflect.TypeOf(DNSTestConfig{}) {
+		return data, nil
+	}
+
+	test, ok := data.(map[string]any)
+	if !ok {
+		return data, nil
+	}
+	// Viper lowercases the keys of the config, but not always of the maps nested in lists
+	var testType, valuesKey string
+	var values []any
+	for key, val := range test {
+		switch strings.ToLower(key) {
+		case "testtype":
+			testType, _ = val.(string)
+		case "expectedvalues":
+			valuesKey = key
+			values, _ = val.([]any)
+		}
+	}
+	if !strings.EqualFold(testType, "mx") || values == nil {
+		return data, nil
+	}
+
+	values = slices.Clone(values)
+	for i, val := range values {
+		entry, ok := val.(map[string]any)
+		if !ok {
+			continue
+		}
+		host, hasHost := entry["host"]
+		pref, hasPref := entry["pref"]
+		if hasHost && hasPref && len(entry) == 2 {
+			values[i] = fmt.Sprintf("%v %v", pref, host)
+		}
+	}
+	test = maps.Clone(test)
+	test[valuesKey] = values
+	return test, nil
+}
+
+// ParseDNSRecordsFullTestConfig decodes and validates a config from YAML (or JSON) data
+func ParseDNSRecordsFullTestConfig(data []byte) (DNSRecordsFullTestConfig, error) {
+	var config DNSRecordsFullTestConfig
+	v := viper.New()
+	v.SetConfigType("yaml")
+
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return DNSRecordsFullTestConfig{}, fmt.Errorf("error reading config: %w", err)
+	}
+
+	if err := v.Unmarshal(&config, viper.DecodeHook(decodeHook())); err != nil {
+		return DNSRecordsFullTestConfig{}, fmt.Errorf("unable to decode into struct: %w", err)
+	}
+
+	if err := config.Validate(); err != nil {
+		return DNSRecordsFullTestConfig{}, fmt.Errorf("validation issue: %w", err)
+	}
+
+	return config, nil
+}
+
 func LoadDNSRecordsFullTestConfig(configFile string) (DNSRecordsFullTestConfig, error) {
+	config, err := ReadDNSRecordsFullTestConfig(configFile)
+	if err != nil {
+		return DNSRecordsFullTestConfig{}, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return DNSRecordsFullTestConfig{}, fmt.Errorf("validation issue: %w", err)
+	}
+
+	return config, nil
+}
+
+// ReadDNSRecordsFullTestConfig decodes a config file without validating it
+func ReadDNSRecordsFullTestConfig(configFile string) (DNSRecordsFullTestConfig, error) {
 	var config DNSRecordsFullTestConfig
 	if configFile == "" {
 		return DNSRecordsFullTestConfig{}, fmt.Errorf("no config file specified")
@@ -55,13 +417,9 @@ func LoadDNSRecordsFullTestConfig(configFile string) (DNSRecordsFullTestConfig,
 		return DNSRecordsFullTestConfig{}, fmt.Errorf("error reading config file: %w", err)
 	}
 
-	if err := viper.Unmarshal(&config); err != nil {
+	if err := viper.Unmarshal(&config, viper.DecodeHook(decodeHook())); err != nil {
 		return DNSRecordsFullTestConfig{}, fmt.Errorf("unable to decode into struct: %w", err)
 	}
 
-	if err := config.validate(); err != nil {
-		return DNSRecordsFullTestConfig{}, fmt.Errorf("validation issue: %w", err)
-	}
-
 	return config, nil
 }
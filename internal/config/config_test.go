@@ -1,11 +1,16 @@
 package config
 
 import (
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestLoadConfig(t *testing.T) {
+	intPtr := func(i int) *int { return &i }
+
 	tests := []struct {
 		name        string
 		configFile  string
@@ -72,6 +77,296 @@ func TestLoadConfig(t *testing.T) {
 			configFile:  "dnstestdata/missing_host.yaml",
 			expectError: true,
 		},
+		{
+			name:       "Valid Webhooks",
+			configFile: "dnstestdata/valid_webhooks.yaml",
+			expected: DNSRecordsFullTestConfig{
+				DNSServer: "8.8.8.8",
+				Tests: []DNSTestConfig{
+					{
+						ExpectedValues: []string{"1.1.1.1"},
+						Host:           "example.com",
+						TestType:       "A",
+					},
+				},
+				Webhooks: []WebhookConfig{
+					{
+						URL:    "http://localhost:8080/hook",
+						Format: "json",
+						On:     "failure",
+					},
+					{
+						URL:    "http://localhost:9093/api/v2/alerts",
+						Format: "alertmanager",
+						On:     "change",
+					},
+					{
+						URL:      "https://hooks.slack.com/services/XXX",
+						Format:   "slack",
+						On:       "failure",
+						Template: "{{ range .Failures }}{{ .Host }} missing {{ join .Missing \", \" }}\n{{ end }}",
+					},
+				},
+			},
+			expectError: false,
+		},
+		{
+			name:        "Invalid Webhook Format",
+			configFile:  "dnstestdata/invalid_webhook_format.yaml",
+			expectError: true,
+		},
+		{
+			name:        "Invalid Webhook Template",
+			configFile:  "dnstestdata/invalid_webhook_template.yaml",
+			expectError: true,
+		},
+		{
+			name:       "Match Modes",
+			configFile: "dnstestdata/match_modes.yaml",
+			expected: DNSRecordsFullTestConfig{
+				DNSServer: "8.8.8.8",
+				Tests: []DNSTestConfig{
+					{
+						ExpectedValues: []string{"1.1.1.1"},
+						Host:           "example.com",
+						TestType:       "A",
+						Match:          "contains",
+						MinCount:       intPtr(2),
+					},
+					{
+						Host:     "example.com",
+						TestType: "AAAA",
+						MaxCount: intPtr(0),
+					},
+				},
+			},
+			expectError: false,
+		},
+		{
+			name:        "Invalid Expected Pattern",
+			configFile:  "dnstestdata/invalid_expected_pattern.yaml",
+			expectError: true,
+		},
+		{
+			name:       "Structured MX Values",
+			configFile: "dnstestdata/mx_structured.yaml",
+			expected: DNSRecordsFullTestConfig{
+				DNSServer: "8.8.8.8",
+				Tests: []DNSTestConfig{
+					{
+						ExpectedValues: []string{"10 mail1.example.com.", "20 mail2.example.com."},
+						Host:           "example.com",
+						TestType:       "mx",
+						Ordered:        true,
+					},
+				},
+			},
+			expectError: false,
+		},
+		{
+			name:        "Structured MX Values In A Non-MX Test",
+			configFile:  "dnstestdata/mx_structured_not_mx.yaml",
+			expectError: true,
+		},
+		{
+			name:       "SPF Tests",
+			configFile: "dnstestdata/spf.yaml",
+			expected: DNSRecordsFullTestConfig{
+				DNSServer: "8.8.8.8",
+				Tests: []DNSTestConfig{
+					{
+						Host:     "example.com",
+						TestType: "spf",
+					},
+					{
+						ExpectedValues: []string{"pass"},
+						Host:           "example.com",
+						TestType:       "spf",
+						SenderIP:       "192.0.2.10",
+					},
+				},
+			},
+			expectError: false,
+		},
+		{
+			name:        "Invalid SPF Result",
+			configFile:  "dnstestdata/invalid_spf_result.yaml",
+			expectError: true,
+		},
+		{
+			name:       "DMARC and DKIM Tests",
+			configFile: "dnstestdata/dmarc_dkim.yaml",
+			expected: DNSRecordsFullTestConfig{
+				DNSServer: "8.8.8.8",
+				Tests: []DNSTestConfig{
+					{
+						ExpectedValues: []string{"p=reject", "adkim=s"},
+						Host:           "example.com",
+						TestType:       "dmarc",
+					},
+					{
+						ExpectedValues: []string{"k=rsa", "bits=2048"},
+						Host:           "example.com",
+						TestType:       "dkim",
+						Selector:       "s1",
+					},
+				},
+			},
+			expectError: false,
+		},
+		{
+			name:        "Missing DKIM Selector",
+			configFile:  "dnstestdata/missing_dkim_selector.yaml",
+			expectError: true,
+		},
+		{
+			name:        "Invalid DMARC Assertion",
+			configFile:  "dnstestdata/invalid_dmarc_assertion.yaml",
+			expectError: true,
+		},
+		{
+			name:       "MTA-STS Test",
+			configFile: "dnstestdata/mta_sts.yaml",
+			expected: DNSRecordsFullTestConfig{
+				DNSServer: "8.8.8.8",
+				Tests: []DNSTestConfig{
+					{
+						ExpectedValues: []string{"mode=enforce"},
+						Host:           "example.com",
+						TestType:       "mta-sts",
+					},
+				},
+			},
+			expectError: false,
+		},
+		{
+			name:       "DNSSEC Settings",
+			configFile: "dnstestdata/dnssec.yaml",
+			expected: DNSRecordsFullTestConfig{
+				DNSServer: "8.8.8.8",
+				DNSSEC: DNSSECConfig{
+					TrustAnchors:  []string{". IN DS 20326 8 2 E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D"},
+					ExpiryWarning: 72 * time.Hour,
+				},
+				Tests: []DNSTestConfig{
+					{
+						ExpectedValues: []string{"192.0.2.1"},
+						Host:           "example.com",
+						TestType:       "a",
+						DNSSEC:         true,
+					},
+				},
+			},
+			expectError: false,
+		},
+		{
+			name:        "Invalid Trust Anchor",
+			configFile:  "dnstestdata/invalid_trust_anchor.yaml",
+			expectError: true,
+		},
+		{
+			name:       "Header Flags",
+			configFile: "dnstestdata/flags.yaml",
+			expected: DNSRecordsFullTestConfig{
+				DNSServer: "8.8.8.8",
+				Tests: []DNSTestConfig{
+					{
+						ExpectedValues: []string{"ns1.example.com."},
+						Host:           "example.com",
+						TestType:       "ns",
+						Flags:          map[string]bool{"aa": true, "tc": false},
+					},
+				},
+			},
+			expectError: false,
+		},
+		{
+			name:        "Invalid Header Flag",
+			configFile:  "dnstestdata/invalid_flag.yaml",
+			expectError: true,
+		},
+		{
+			name:       "EDNS Client Subnet",
+			configFile: "dnstestdata/ecs.yaml",
+			expected: DNSRecordsFullTestConfig{
+				DNSServer: "8.8.8.8",
+				Tests: []DNSTestConfig{
+					{
+						ExpectedValues: []string{"192.0.2.10"},
+						Host:           "www.example.com",
+						TestType:       "a",
+						ECS:            "203.0.113.0/24",
+					},
+					{
+						ExpectedValues: []string{"192.0.2.20"},
+						Host:           "www.example.com",
+						TestType:       "a",
+						ECS:            "2001:db8::/56",
+					},
+				},
+			},
+			expectError: false,
+		},
+		{
+			name:        "Invalid EDNS Client Subnet",
+			configFile:  "dnstestdata/invalid_ecs.yaml",
+			expectError: true,
+		},
+		{
+			name:       "TSIG Key",
+			configFile: "dnstestdata/tsig.yaml",
+			expected: DNSRecordsFullTestConfig{
+				DNSServer: "10.0.0.53",
+				TSIG: &TSIGConfig{
+					Name:      "sherlock-key",
+					Algorithm: "hmac-sha512",
+					SecretEnv: "SHERLOCK_TSIG_SECRET",
+				},
+				Tests: []DNSTestConfig{
+					{
+						ExpectedValues: []string{"10.0.0.10"},
+						Host:           "internal.example.com",
+						TestType:       "a",
+					},
+				},
+			},
+			expectError: false,
+		},
+		{
+			name:        "TSIG Key With Two Secret Sources",
+			configFile:  "dnstestdata/invalid_tsig.yaml",
+			expectError: true,
+		},
+		{
+			name:       "Zone File Server",
+			configFile: "dnstestdata/zone_file_server.yaml",
+			expected: DNSRecordsFullTestConfig{
+				DNSServer: "file://dnstestdata/db.example.com?origin=example.com",
+				Tests: []DNSTestConfig{
+					{
+						ExpectedValues: []string{"192.0.2.1", "192.0.2.2"},
+						Host:           "www.example.com",
+						TestType:       "a",
+					},
+				},
+			},
+			expectError: false,
+		},
+		{
+			name:        "Zone File Server With Unknown Option",
+			configFile:  "dnstestdata/invalid_zone_file_server.yaml",
+			expectError: true,
+		},
+		{
+			name:        "Mixed MX Preferences",
+			configFile:  "dnstestdata/mx_mixed_preferences.yaml",
+			expectError: true,
+		},
+		{
+			name:        "Invalid Match Mode",
+			configFile:  "dnstestdata/invalid_match_mode.yaml",
+			expectError: true,
+		},
 		{
 			name:        "Missing Test Type",
 			configFile:  "dnstestdata/missing_test_type.yaml",
@@ -101,3 +396,126 @@ func TestLoadConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestParseConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		expected    DNSRecordsFullTestConfig
+		expectError bool
+	}{
+		{
+			name: "Valid YAML",
+			data: "dnsServer: 8.8.8.8\ntests:\n  - host: example.com\n    testType: a\n    expectedValues: [\"1.1.1.1\"]\n",
+			expected: DNSRecordsFullTestConfig{
+				DNSServer: "8.8.8.8",
+				Tests: []DNSTestConfig{
+					{
+						ExpectedValues: []string{"1.1.1.1"},
+						Host:           "example.com",
+						TestType:       "a",
+					},
+				},
+			},
+		},
+		{
+			name: "Valid JSON",
+			data: `{"tests": [{"host": "example.com", "testType": "a", "expectedValues": ["1.1.1.1"]}]}`,
+			expected: DNSRecordsFullTestConfig{
+				DNSServer: "1.1.1.1",
+				Tests: []DNSTestConfig{
+					{
+						ExpectedValues: []string{"1.1.1.1"},
+						Host:           "example.com",
+						TestType:       "a",
+					},
+				},
+			},
+		},
+		{
+			name:        "Malformed data",
+			data:        "tests: [",
+			expectError: true,
+		},
+		{
+			name:        "No tests defined",
+			data:        "dnsServer: 8.8.8.8\n",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := ParseDNSRecordsFullTestConfig([]byte(tt.data))
+
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("expected an error but got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("didn't expect an error but got %v", err)
+				return
+			}
+
+			if !reflect.DeepEqual(tt.expected, config) {
+				t.Errorf("expected config %+v, but got %+v", tt.expected, config)
+			}
+		})
+	}
+}
+
+func TestTSIGConfigKey(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "tsig.key")
+	if err := os.WriteFile(secretFile, []byte("c2hlcmxvY2stdGVzdC1zZWNyZXQ=\n"), 0o600); err != nil {
+		t.Fatalf("failed to write the secret file: %v", err)
+	}
+	t.Setenv("SHERLOCK_TEST_TSIG_SECRET", "c2hlcmxvY2stdGVzdC1zZWNyZXQ=")
+
+	tests := []struct {
+		name        string
+		config      TSIGConfig
+		expectError bool
+	}{
+		{name: "Secret from the environment", config: TSIGConfig{Name: "sherlock", SecretEnv: "SHERLOCK_TEST_TSIG_SECRET"}},
+		{name: "Secret from a file", config: TSIGConfig{Name: "sherlock", SecretFile: secretFile}},
+		{name: "Unset environment variable", config: TSIGConfig{Name: "sherlock", SecretEnv: "SHERLOCK_TEST_TSIG_UNSET"}, expectError: true},
+		{name: "Missing file", config: TSIGConfig{Name: "sherlock", SecretFile: secretFile + ".missing"}, expectError: true},
+		{name: "No secret source", config: TSIGConfig{Name: "sherlock"}, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := tt.config.Key()
+			if (err != nil) != tt.expectError {
+				t.Fatalf("Key() error = %v, expectError %v", err, tt.expectError)
+			}
+			if !tt.expectError && (key.Name != "sherlock." || key.Secret != "c2hlcmxvY2stdGVzdC1zZWNyZXQ=") {
+				t.Errorf("Key() = %+v", key)
+			}
+		})
+	}
+}
+
+func TestDNSTestConfigVariant(t *testing.T) {
+	tests := []struct {
+		name     string
+		test     DNSTestConfig
+		expected string
+	}{
+		{name: "Plain test", test: DNSTestConfig{Host: "example.com", TestType: "a"}},
+		{name: "DKIM selector", test: DNSTestConfig{Host: "example.com", TestType: "dkim", Selector: "s1"}, expected: "selector=s1"},
+		{name: "Client subnet", test: DNSTestConfig{Host: "www.example.com", TestType: "a", ECS: "203.0.113.0/24"}, expected: "ecs=203.0.113.0/24"},
+		{name: "Sender IP", test: DNSTestConfig{Host: "example.com", TestType: "spf", SenderIP: "192.0.2.1"}, expected: "senderIP=192.0.2.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.test.Variant(); got != tt.expected {
+				t.Errorf("Variant() = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/ch0ppy35/sherlock/internal/dns"
+	"gopkg.in/yaml.v3"
+)
+
+// GenerateDNSRecordsFullTestConfig builds a config that expects the records each host currently resolves to, one test
+// per host and type. Hosts without records of a type get no test for it, they're returned as "host type" entries.
+// Hosts with a CNAME only get a cname test when cname is one of the types
+func GenerateDNSRecordsFullTestConfig(ctx context.Context, client dns.IDNSClient, dnsServer string, hosts []string, testTypes []string) (DNSRecordsFullTestConfig, []string, error) {
+	config := DNSRecordsFullTestConfig{DNSServer: dnsServer}
+	if len(hosts) == 0 {
+		return config, nil, fmt.Errorf("no hosts to generate tests for")
+	}
+	if len(testTypes) == 0 {
+		return config, nil, fmt.Errorf("no test types to generate tests for")
+	}
+	types := make([]string, 0, len(testTypes))
+	for _, testType := range testTypes {
+		if _, err := dns.GetQueryTypeFromString(testType); err != nil {
+			return config, nil, err
+		}
+		types = append(types, strings.ToLower(testType))
+	}
+
+	var skipped []string
+	for _, host := range hosts {
+		records, err := dns.QueryDNSContext(ctx, host, dnsServer, client)
+		if err != nil {
+			return config, nil, fmt.Errorf("trouble querying %s: %w", host, err)
+		}
+
+		// A name with a CNAME has no other records, answers of other types come from the CNAME's target
+		isCNAME := slices.Contains(types, "cname") && len(records.CNAMERecords) > 0
+		for _, testType := range types {
+			if isCNAME && testType != "cname" {
+				continue
+			}
+			values := generatedValues(records, testType)
+			if len(values) == 0 {
+				skipped = append(skipped, host+" "+testType)
+				continue
+			}
+			config.Tests = append(config.Tests, DNSTestConfig{
+				ExpectedValues: values,
+				Host:           host,
+				TestType:       testType,
+			})
+		}
+	}
+
+	if len(config.Tests) == 0 {
+		return config, skipped, fmt.Errorf("none of the hosts have records of the requested types")
+	}
+	if err := config.Validate(); err != nil {
+		return config, skipped, fmt.Errorf("generated config is invalid: %w", err)
+	}
+	return config, skipped, nil
+}
+
+// generatedValues returns the sorted values of the records of the type, MX records include their preference and
+// are listed in priority order
+func generatedValues(records *dns.DNSRecords, testType string) []string {
+	if testType == "mx" {
+		mxs := append([]dns.MXRecord(nil), records.MXRecords...)
+		sort.Slice(mxs, func(i, j int) bool {
+			if mxs[i].Pref != mxs[j].Pref {
+				return mxs[i].Pref < mxs[j].Pref
+			}
+			return mxs[i].Host < mxs[j].Host
+		})
+		var values []string
+		for _, mx := range mxs {
+			values = append(values, mx.String())
+		}
+		return values
+	}
+
+	values, _ := dns.ExtractRecords(records, testType)
+	values = append([]string(nil), values...)
+	sort.Strings(values)
+	return values
+}
+
+// MarshalDNSRecordsFullTestConfig encodes the config as YAML, leaving out unset options
+func MarshalDNSRecordsFullTestConfig(config DNSRecordsFullTestConfig) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(config); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
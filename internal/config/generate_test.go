@@ -0,0 +1,107 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ch0ppy35/sherlock/pkg/sherlock/dns/dnstest"
+	d "github.com/miekg/dns"
+)
+
+// generateMockClient answers from a list of zone file records, queries for broken.example.com fail
+func generateMockClient(t *testing.T, records ...string) *dnstest.Resolver {
+	t.Helper()
+	var rrs []d.RR
+	for _, record := range records {
+		rr, err := d.NewRR(record)
+		if err != nil {
+			t.Fatalf("failed to parse %q: %v", record, err)
+		}
+		rrs = append(rrs, rr)
+	}
+
+	return dnstest.NewResolver(rrs...).Fail("broken.example.com", fmt.Errorf("connection refused"))
+}
+
+func TestGenerateDNSRecordsFullTestConfig(t *testing.T) {
+	client := generateMockClient(t,
+		"example.com. 300 IN A 192.0.2.2",
+		"example.com. 300 IN A 192.0.2.1",
+		"example.com. 300 IN MX 20 mail2.example.com.",
+		"example.com. 300 IN MX 5 mail1.example.com.",
+		"www.example.com. 300 IN CNAME example.com.",
+	)
+
+	tests := []struct {
+		name            string
+		hosts           []string
+		testTypes       []string
+		expectedTests   []DNSTestConfig
+		expectedSkipped []string
+		expectError     bool
+	}{
+		{
+			name:      "Tests for the current records",
+			hosts:     []string{"example.com", "www.example.com"},
+			testTypes: []string{"A", "cname", "mx"},
+			expectedTests: []DNSTestConfig{
+				{ExpectedValues: []string{"192.0.2.1", "192.0.2.2"}, Host: "example.com", TestType: "a"},
+				{ExpectedValues: []string{"5 mail1.example.com.", "20 mail2.example.com."}, Host: "example.com", TestType: "mx"},
+				{ExpectedValues: []string{"example.com."}, Host: "www.example.com", TestType: "cname"},
+			},
+			expectedSkipped: []string{"example.com cname"},
+		},
+		{
+			name:      "CNAME target's records without a cname test",
+			hosts:     []string{"www.example.com"},
+			testTypes: []string{"a"},
+			expectedTests: []DNSTestConfig{
+				{ExpectedValues: []string{"192.0.2.1", "192.0.2.2"}, Host: "www.example.com", TestType: "a"},
+			},
+		},
+		{name: "Unsupported type", hosts: []string{"example.com"}, testTypes: []string{"srv"}, expectError: true},
+		{name: "No records", hosts: []string{"missing.example.com"}, testTypes: []string{"a"}, expectError: true},
+		{name: "No hosts", testTypes: []string{"a"}, expectError: true},
+		{name: "Query failure", hosts: []string{"broken.example.com"}, testTypes: []string{"a"}, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, skipped, err := GenerateDNSRecordsFullTestConfig(context.Background(), client, "192.0.2.53", tt.hosts, tt.testTypes)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(config.Tests, tt.expectedTests) {
+				t.Errorf("expected tests %+v, got %+v", tt.expectedTests, config.Tests)
+			}
+			if !reflect.DeepEqual(skipped, tt.expectedSkipped) {
+				t.Errorf("expected skipped %v, got %v", tt.expectedSkipped, skipped)
+			}
+
+			// The YAML has to load back into the same config
+			data, err := MarshalDNSRecordsFullTestConfig(config)
+			if err != nil {
+				t.Fatalf("failed to marshal the config: %v", err)
+			}
+			if strings.Contains(string(data), "webhooks") || strings.Contains(string(data), "match") {
+				t.Errorf("expected unset options to be left out, got:\n%s", data)
+			}
+			parsed, err := ParseDNSRecordsFullTestConfig(data)
+			if err != nil {
+				t.Fatalf("failed to parse the generated config: %v\n%s", err, data)
+			}
+			if !reflect.DeepEqual(parsed.Tests, config.Tests) || parsed.DNSServer != config.DNSServer {
+				t.Errorf("expected the config to load back unchanged, got %+v", parsed)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ch0ppy35/sherlock/internal/dns"
+	d "github.com/miekg/dns"
+)
+
+// ImportZoneFile builds a test for every name and type pair of a zone file, expecting exactly the values in the
+// file. The zone is named by its SOA when no origin is set. Pairs that can't be tested are returned with the reason,
+// these are unsupported types, wildcards and names delegated to child zones
+func ImportZoneFile(r io.Reader, origin string, filename string) ([]DNSTestConfig, []string, error) {
+	records, err := dns.ParseZoneFile(r, origin, filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	if origin == "" {
+		if origin, err = dns.ZoneOrigin(records); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse the zone: %w, set the origin", err)
+		}
+	}
+	snapshot, err := dns.NewZoneSnapshot(origin, records)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var cuts []string
+	for _, rr := range snapshot.Records {
+		if rr.Header().Rrtype == d.TypeNS && rr.Header().Name != snapshot.Zone {
+			cuts = append(cuts, rr.Header().Name)
+		}
+	}
+
+	var tests []DNSTestConfig
+	var skipped []string
+	for _, set := range snapshot.RRsets() {
+		if set.Type == d.TypeSOA {
+			continue
+		}
+		testType := strings.ToLower(d.TypeToString[set.Type])
+		if reason := untestable(set.Name, testType, cuts); reason != "" {
+			skipped = append(skipped, set.String()+": "+reason)
+			continue
+		}
+
+		tests = append(tests, DNSTestConfig{
+			ExpectedValues: generatedValues(dns.NewDNSRecords(set.Records), testType),
+			Host:           strings.TrimSuffix(set.Name, "."),
+			TestType:       testType,
+		})
+	}
+	if len(tests) == 0 {
+		return nil, skipped, fmt.Errorf("zone %s has no records that can be tested", snapshot.Zone)
+	}
+	return tests, skipped, nil
+}
+
+// untestable returns why a name and type pair can't be tested, or an empty string when it can. Names at or below a
+// delegation cut are answered by the child zone's servers rather than the records being imported
+func untestable(name string, testType string, cuts []string) string {
+	for _, cut := range cuts {
+		if d.IsSubDomain(cut, name) {
+			return "delegated to a child zone"
+		}
+	}
+	if strings.HasPrefix(name, "*.") {
+		return "wildcard"
+	}
+	if _, err := dns.GetQueryTypeFromString(testType); err != nil {
+		return "unsupported type"
+	}
+	return ""
+}
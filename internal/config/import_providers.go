@@ -0,0 +1,404 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/ch0ppy35/sherlock/internal/dns"
+	d "github.com/miekg/dns"
+	"gopkg.in/yaml.v3"
+)
+
+// octoDNSDefaultTTL is the TTL OctoDNS gives records without one
+const octoDNSDefaultTTL = 3600
+
+// recordSet is a name and type pair read from a DNS provider's export, values are in zone file format
+type recordSet struct {
+	// zone is empty when the export doesn't say which zone the record is in
+	zone   string
+	name   string
+	rrtype string
+	ttl    uint32
+	values []string
+	// alias is the target of a Route53 alias record, which has no values of its own
+	alias string
+	// routed is set for records with a routing policy, only some of their values are returned to each client
+	routed bool
+}
+
+// ImportRoute53 builds tests from the output of `aws route53 list-resource-record-sets`. The zone is named by its SOA
+// record when no origin is set. Records with a routing policy get a test passing on any of their values, alias
+// records are skipped since their values come from the target
+func ImportRoute53(r io.Reader, origin string) ([]DNSTestConfig, []string, error) {
+	var export struct {
+		ResourceRecordSets []struct {
+			Name            string `json:"Name"`
+			Type            string `json:"Type"`
+			TTL             uint32 `json:"TTL"`
+			SetIdentifier   string `json:"SetIdentifier"`
+			ResourceRecords []struct {
+				Value string `json:"Value"`
+			} `json:"ResourceRecords"`
+			AliasTarget *struct {
+				DNSName string `json:"DNSName"`
+			} `json:"AliasTarget"`
+		} `json:"ResourceRecordSets"`
+	}
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse the Route53 export: %w", err)
+	}
+	if export.ResourceRecordSets == nil {
+		return nil, nil, fmt.Errorf("no ResourceRecordSets found, expected the output of `aws route53 list-resource-record-sets`")
+	}
+
+	zone := origin
+	var sets []recordSet
+	for _, rrset := range export.ResourceRecordSets {
+		// Route53 escapes the * of wildcards in octal
+		name := strings.ReplaceAll(rrset.Name, `\052`, "*")
+		set := recordSet{name: name, rrtype: rrset.Type, ttl: rrset.TTL, routed: rrset.SetIdentifier != ""}
+		if rrset.AliasTarget != nil {
+			set.alias = rrset.AliasTarget.DNSName
+		}
+		for _, record := range rrset.ResourceRecords {
+			set.values = append(set.values, record.Value)
+		}
+		if zone == "" && strings.EqualFold(rrset.Type, "SOA") {
+			zone = name
+		}
+		sets = append(sets, set)
+	}
+	for i := range sets {
+		sets[i].zone = zone
+	}
+	return importRecordSets(sets)
+}
+
+// ImportOctoDNS builds tests from an OctoDNS zone file, names in it are relative to the zone
+func ImportOctoDNS(r io.Reader, zone string) ([]DNSTestConfig, []string, error) {
+	if zone == "" {
+		return nil, nil, fmt.Errorf("the zone of an OctoDNS file must be set")
+	}
+	var names map[string]yaml.Node
+	if err := yaml.NewDecoder(r).Decode(&names); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse the OctoDNS zone: %w", err)
+	}
+
+	type octoDNSRecord struct {
+		Type   string    `yaml:"type"`
+		TTL    *uint32   `yaml:"ttl"`
+		Value  yaml.Node `yaml:"value"`
+		Values yaml.Node `yaml:"values"`
+	}
+
+	var sets []recordSet
+	for name, node := range names {
+		// A name holds either a single record or a list of them
+		var records []octoDNSRecord
+		if node.Kind == yaml.SequenceNode {
+			if err := node.Decode(&records); err != nil {
+				return nil, nil, fmt.Errorf("failed to parse the records of '%s': %w", name, err)
+			}
+		} else {
+			var record octoDNSRecord
+			if err := node.Decode(&record); err != nil {
+				return nil, nil, fmt.Errorf("failed to parse the records of '%s': %w", name, err)
+			}
+			records = append(records, record)
+		}
+
+		fqdn := d.Fqdn(zone)
+		if name != "" {
+			fqdn = name + "." + fqdn
+		}
+		for _, record := range records {
+			set := recordSet{zone: zone, name: fqdn, rrtype: record.Type, ttl: octoDNSDefaultTTL}
+			if record.TTL != nil {
+				set.ttl = *record.TTL
+			}
+			// Values of types sherlock can't test aren't read, they may use any structure
+			if _, err := dns.GetQueryTypeFromString(record.Type); err == nil {
+				values, err := octoDNSValues(record.Type, record.Value, record.Values)
+				if err != nil {
+					return nil, nil, fmt.Errorf("invalid %s record for '%s': %w", record.Type, name, err)
+				}
+				set.values = values
+			}
+			sets = append(sets, set)
+		}
+	}
+	return importRecordSets(sets)
+}
+
+// octoDNSValues converts the value or values of an OctoDNS record to zone file format
+func octoDNSValues(rrtype string, value yaml.Node, values yaml.Node) ([]string, error) {
+	var nodes []*yaml.Node
+	switch {
+	case values.Kind == yaml.SequenceNode:
+		nodes = values.Content
+	case values.Kind != 0:
+		nodes = []*yaml.Node{&values}
+	case value.Kind != 0:
+		nodes = []*yaml.Node{&value}
+	default:
+		return nil, fmt.Errorf("no value or values set")
+	}
+
+	var converted []string
+	for _, node := range nodes {
+		switch strings.ToUpper(rrtype) {
+		case "MX":
+			// OctoDNS has used both names for the fields over time
+			var mx struct {
+				Exchange   string  `yaml:"exchange"`
+				Value      string  `yaml:"value"`
+				Preference *uint16 `yaml:"preference"`
+				Priority   *uint16 `yaml:"priority"`
+			}
+			if err := node.Decode(&mx); err != nil {
+				return nil, err
+			}
+			exchange, pref := mx.Exchange, mx.Preference
+			if exchange == "" {
+				exchange = mx.Value
+			}
+			if pref == nil {
+				pref = mx.Priority
+			}
+			if exchange == "" || pref == nil {
+				return nil, fmt.Errorf("MX values need an exchange and a preference")
+			}
+			converted = append(converted, fmt.Sprintf("%d %s", *pref, exchange))
+		case "TXT":
+			var txt string
+			if err := node.Decode(&txt); err != nil {
+				return nil, err
+			}
+			// OctoDNS escapes semicolons, the value is split into character strings like in a zone file
+			converted = append(converted, quoteTXT(strings.ReplaceAll(txt, `\;`, ";")))
+		default:
+			var v string
+			if err := node.Decode(&v); err != nil {
+				return nil, err
+			}
+			converted = append(converted, v)
+		}
+	}
+	return converted, nil
+}
+
+// quoteTXT quotes a TXT value for a zone file, values over 255 bytes are split into several character strings
+func quoteTXT(txt string) string {
+	var segments []string
+	for {
+		segment := txt[:min(len(txt), 255)]
+		txt = txt[len(segment):]
+		segment = strings.ReplaceAll(segment, `\`, `\\`)
+		segments = append(segments, `"`+strings.ReplaceAll(segment, `"`, `\"`)+`"`)
+		if txt == "" {
+			return strings.Join(segments, " ")
+		}
+	}
+}
+
+// terraformModule is a module of the state in `terraform show -json` output
+type terraformModule struct {
+	Resources []struct {
+		Type   string          `json:"type"`
+		Values json.RawMessage `json:"values"`
+	} `json:"resources"`
+	ChildModules []terraformModule `json:"child_modules"`
+}
+
+// ImportTerraform builds tests from the aws_route53_record resources in `terraform show -json` output, of either a
+// state or a plan. Records are matched to the zone of an aws_route53_zone resource or data source in the same state,
+// the origin is used for records in other zones
+func ImportTerraform(r io.Reader, origin string) ([]DNSTestConfig, []string, error) {
+	var show struct {
+		Values *struct {
+			RootModule terraformModule `json:"root_module"`
+		} `json:"values"`
+		PlannedValues *struct {
+			RootModule terraformModule `json:"root_module"`
+		} `json:"planned_values"`
+	}
+	if err := json.NewDecoder(r).Decode(&show); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse the Terraform output: %w", err)
+	}
+	var root terraformModule
+	switch {
+	case show.Values != nil:
+		root = show.Values.RootModule
+	case show.PlannedValues != nil:
+		root = show.PlannedValues.RootModule
+	default:
+		return nil, nil, fmt.Errorf("no values found, expected the output of `terraform show -json`")
+	}
+
+	type terraformRecord struct {
+		Name          string   `json:"name"`
+		FQDN          string   `json:"fqdn"`
+		Type          string   `json:"type"`
+		TTL           *uint32  `json:"ttl"`
+		ZoneID        string   `json:"zone_id"`
+		Records       []string `json:"records"`
+		SetIdentifier string   `json:"set_identifier"`
+		Alias         []struct {
+			Name string `json:"name"`
+		} `json:"alias"`
+	}
+
+	zones := make(map[string]string)
+	var records []terraformRecord
+	modules := []terraformModule{root}
+	for len(modules) > 0 {
+		module := modules[0]
+		modules = append(modules[1:], module.ChildModules...)
+		for _, resource := range module.Resources {
+			switch resource.Type {
+			case "aws_route53_zone":
+				var zone struct {
+					Name   string `json:"name"`
+					ZoneID string `json:"zone_id"`
+				}
+				if err := json.Unmarshal(resource.Values, &zone); err != nil {
+					return nil, nil, fmt.Errorf("failed to parse an aws_route53_zone: %w", err)
+				}
+				zones[zone.ZoneID] = zone.Name
+			case "aws_route53_record":
+				var record terraformRecord
+				if err := json.Unmarshal(resource.Values, &record); err != nil {
+					return nil, nil, fmt.Errorf("failed to parse an aws_route53_record: %w", err)
+				}
+				records = append(records, record)
+			}
+		}
+	}
+
+	var sets []recordSet
+	for _, record := range records {
+		name := record.FQDN
+		if name == "" {
+			name = record.Name
+		}
+		zone, ok := zones[record.ZoneID]
+		if !ok {
+			zone = origin
+		}
+		if zone != "" && !d.IsSubDomain(d.Fqdn(zone), d.Fqdn(name)) {
+			// Plans don't know the fqdn of new records yet, their names may be relative to the zone
+			if record.FQDN == "" {
+				name = name + "." + zone
+			} else {
+				zone = ""
+			}
+		}
+
+		set := recordSet{zone: zone, name: name, rrtype: record.Type, routed: record.SetIdentifier != ""}
+		if record.TTL != nil {
+			set.ttl = *record.TTL
+		}
+		if len(record.Alias) > 0 {
+			set.alias = record.Alias[0].Name
+		}
+		for _, value := range record.Records {
+			// The provider quotes TXT values itself, "" splits long values into several character strings
+			if strings.EqualFold(record.Type, "TXT") {
+				value = `"` + value + `"`
+			}
+			set.values = append(set.values, value)
+		}
+		sets = append(sets, set)
+	}
+	return importRecordSets(sets)
+}
+
+// importRecordSets builds a test for every name and type pair of the record sets, in zone order. Sets sharing a name
+// and type are merged, which happens with routing policies
+func importRecordSets(sets []recordSet) ([]DNSTestConfig, []string, error) {
+	var cuts []string
+	for i := range sets {
+		sets[i].name = d.CanonicalName(sets[i].name)
+		sets[i].rrtype = strings.ToUpper(sets[i].rrtype)
+		if sets[i].zone != "" {
+			sets[i].zone = d.CanonicalName(sets[i].zone)
+			if sets[i].rrtype == "NS" && sets[i].name != sets[i].zone {
+				cuts = append(cuts, sets[i].name)
+			}
+		}
+	}
+	sort.SliceStable(sets, func(i, j int) bool {
+		if c := dns.CompareNames(sets[i].name, sets[j].name); c != 0 {
+			return c < 0
+		}
+		if c := dns.CompareTypes(d.StringToType[sets[i].rrtype], d.StringToType[sets[j].rrtype]); c != 0 {
+			return c < 0
+		}
+		return sets[i].rrtype < sets[j].rrtype
+	})
+
+	var tests []DNSTestConfig
+	var skipped []string
+	for i := 0; i < len(sets); {
+		set := sets[i]
+		merged := []recordSet{set}
+		for i++; i < len(sets) && sets[i].name == set.name && sets[i].rrtype == set.rrtype; i++ {
+			merged = append(merged, sets[i])
+		}
+
+		if set.rrtype == "SOA" {
+			continue
+		}
+		testType := strings.ToLower(set.rrtype)
+		entry := set.name + " " + set.rrtype
+		if reason := untestable(set.name, testType, cuts); reason != "" {
+			skipped = append(skipped, entry+": "+reason)
+			continue
+		}
+
+		var records []d.RR
+		alias, routed := "", false
+		for _, s := range merged {
+			routed = routed || s.routed
+			if s.alias != "" {
+				alias = s.alias
+			}
+			for _, value := range s.values {
+				rr, err := d.NewRR(fmt.Sprintf("%s %d IN %s %s", s.name, s.ttl, s.rrtype, value))
+				if err != nil || rr == nil {
+					return nil, skipped, fmt.Errorf("invalid %s value '%s': %v", entry, value, err)
+				}
+				records = append(records, rr)
+			}
+		}
+		if alias != "" {
+			skipped = append(skipped, entry+": alias to "+alias)
+			continue
+		}
+		if len(records) == 0 {
+			skipped = append(skipped, entry+": no values")
+			continue
+		}
+
+		test := DNSTestConfig{
+			ExpectedValues: slices.Compact(generatedValues(dns.NewDNSRecords(records), testType)),
+			Host:           strings.TrimSuffix(set.name, "."),
+			TestType:       testType,
+		}
+		if routed {
+			// Each client only gets the values of the record set the routing policy picks for it
+			minCount := 1
+			test.Match = dns.MatchSubsetOf
+			test.MinCount = &minCount
+		}
+		tests = append(tests, test)
+	}
+	if len(tests) == 0 {
+		return nil, skipped, fmt.Errorf("no records that can be tested")
+	}
+	return tests, skipped, nil
+}
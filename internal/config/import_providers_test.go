@@ -0,0 +1,138 @@
+package config
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestImportProviders(t *testing.T) {
+	one := 1
+	exampleTests := []DNSTestConfig{
+		{ExpectedValues: []string{"10 mail1.example.com.", "20 mail2.example.com."}, Host: "example.com", TestType: "mx"},
+		{ExpectedValues: []string{"v=spf1 mx -all"}, Host: "example.com", TestType: "txt"},
+	}
+	routed := DNSTestConfig{ExpectedValues: []string{"192.0.2.10", "192.0.2.11"}, Host: "api.example.com", TestType: "a", Match: "subsetOf", MinCount: &one}
+	www := DNSTestConfig{ExpectedValues: []string{"192.0.2.1", "192.0.2.2"}, Host: "www.example.com", TestType: "a"}
+
+	tests := []struct {
+		name            string
+		importer        func(r io.Reader, origin string) ([]DNSTestConfig, []string, error)
+		input           string
+		filename        string
+		origin          string
+		expectedTests   []DNSTestConfig
+		expectedSkipped []string
+		expectError     bool
+	}{
+		{
+			name:     "Route53 export",
+			importer: ImportRoute53,
+			filename: "dnstestdata/route53.json",
+			expectedTests: append(append([]DNSTestConfig{
+				{ExpectedValues: []string{"ns-1.awsdns-01.org.", "ns-2.awsdns-02.com."}, Host: "example.com", TestType: "ns"},
+			}, exampleTests...), routed, www),
+			expectedSkipped: []string{
+				"_sip._tcp.example.com. SRV: unsupported type",
+				"*.apps.example.com. A: wildcard",
+				"cdn.example.com. A: alias to d111111abcdef8.cloudfront.net.",
+				"child.example.com. NS: delegated to a child zone",
+			},
+		},
+		{
+			name:     "OctoDNS zone",
+			importer: ImportOctoDNS,
+			filename: "dnstestdata/example.com.yaml",
+			origin:   "example.com",
+			expectedTests: append(append([]DNSTestConfig{
+				{ExpectedValues: []string{"ns1.example.com.", "ns2.example.net."}, Host: "example.com", TestType: "ns"},
+			}, exampleTests...),
+				DNSTestConfig{ExpectedValues: []string{"v=DKIM1; k=rsa; p=MIGfMA0GCSqGSIb3DQEBAQUAA4GNADCBiQKBgQC"}, Host: "dkim._domainkey.example.com", TestType: "txt"},
+				DNSTestConfig{ExpectedValues: []string{"www.example.com."}, Host: "ftp.example.com", TestType: "cname"},
+				www,
+				DNSTestConfig{ExpectedValues: []string{"2001:db8::1"}, Host: "www.example.com", TestType: "aaaa"},
+			),
+			expectedSkipped: []string{
+				"example.com. ALIAS: unsupported type",
+				"_sip._tcp.example.com. SRV: unsupported type",
+				"*.apps.example.com. A: wildcard",
+				"child.example.com. NS: delegated to a child zone",
+			},
+		},
+		{
+			name:     "Terraform state",
+			importer: ImportTerraform,
+			filename: "dnstestdata/terraform.json",
+			expectedTests: append(append([]DNSTestConfig{}, exampleTests...), routed, www,
+				DNSTestConfig{ExpectedValues: []string{"2001:db8::10"}, Host: "legacy.example.org", TestType: "aaaa"},
+			),
+			expectedSkipped: []string{
+				"cdn.example.com. A: alias to d111111abcdef8.cloudfront.net",
+				"child.example.com. NS: delegated to a child zone",
+			},
+		},
+		{
+			name:     "Terraform plan with relative names",
+			importer: ImportTerraform,
+			origin:   "example.com",
+			input: `{"planned_values": {"root_module": {"resources": [
+				{"type": "aws_route53_record", "values": {"name": "www", "type": "A", "ttl": 300, "records": ["192.0.2.2", "192.0.2.1"]}}
+			]}}}`,
+			expectedTests: []DNSTestConfig{www},
+		},
+		{name: "Not a Route53 export", importer: ImportRoute53, input: `{"Changes": []}`, expectError: true},
+		{name: "Invalid Route53 value", importer: ImportRoute53, input: `{"ResourceRecordSets": [{"Name": "www.example.com.", "Type": "A", "TTL": 300, "ResourceRecords": [{"Value": "192.0.2"}]}]}`, expectError: true},
+		{name: "OctoDNS without a zone", importer: ImportOctoDNS, filename: "dnstestdata/example.com.yaml", expectError: true},
+		{name: "OctoDNS MX without a preference", importer: ImportOctoDNS, origin: "example.com", input: "'':\n  type: MX\n  value:\n    exchange: mail.example.com.\n", expectError: true},
+		{name: "Not Terraform output", importer: ImportTerraform, input: `{"format_version": "1.0"}`, expectError: true},
+		{name: "Only untestable records", importer: ImportTerraform, input: `{"values": {"root_module": {}}}`, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := tt.input
+			if tt.filename != "" {
+				input = readFixture(t, tt.filename)
+			}
+			got, skipped, err := tt.importer(strings.NewReader(input), tt.origin)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.expectedTests) {
+				t.Errorf("expected tests %+v, got %+v", tt.expectedTests, got)
+			}
+			if !reflect.DeepEqual(skipped, tt.expectedSkipped) {
+				t.Errorf("expected skipped %v, got %v", tt.expectedSkipped, skipped)
+			}
+		})
+	}
+}
+
+func TestQuoteTXT(t *testing.T) {
+	long := strings.Repeat("a", 300)
+	tests := []struct {
+		name     string
+		txt      string
+		expected string
+	}{
+		{name: "Short", txt: "v=spf1 -all", expected: `"v=spf1 -all"`},
+		{name: "Quotes and backslashes", txt: `say "hi" \o/`, expected: `"say \"hi\" \\o/"`},
+		{name: "Empty", txt: "", expected: `""`},
+		{name: "Long", txt: long, expected: `"` + long[:255] + `" "` + long[255:] + `"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := quoteTXT(tt.txt); got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestImportZoneFile(t *testing.T) {
+	expectedTests := []DNSTestConfig{
+		{ExpectedValues: []string{"ns1.example.com.", "ns2.example.net."}, Host: "example.com", TestType: "ns"},
+		{ExpectedValues: []string{"10 mail1.example.com.", "20 mail2.example.com."}, Host: "example.com", TestType: "mx"},
+		{ExpectedValues: []string{"v=spf1 mx -all"}, Host: "example.com", TestType: "txt"},
+		{ExpectedValues: []string{"www.example.com."}, Host: "ftp.example.com", TestType: "cname"},
+		{ExpectedValues: []string{"192.0.2.25"}, Host: "mail1.example.com", TestType: "a"},
+		{ExpectedValues: []string{"192.0.2.26"}, Host: "mail2.example.com", TestType: "a"},
+		{ExpectedValues: []string{"192.0.2.53"}, Host: "ns1.example.com", TestType: "a"},
+		{ExpectedValues: []string{"192.0.2.1", "192.0.2.2"}, Host: "www.example.com", TestType: "a"},
+		{ExpectedValues: []string{"2001:db8::1"}, Host: "www.example.com", TestType: "aaaa"},
+	}
+	expectedSkipped := []string{
+		"_sip._tcp.example.com. SRV: unsupported type",
+		"*.apps.example.com. A: wildcard",
+		"child.example.com. NS: delegated to a child zone",
+		"ns1.child.example.com. A: delegated to a child zone",
+	}
+
+	tests := []struct {
+		name        string
+		zone        string
+		origin      string
+		filename    string
+		expectError bool
+	}{
+		{name: "Zone file with origin", origin: "example.com", filename: "dnstestdata/db.example.com"},
+		{name: "Origin from $ORIGIN", zone: "$ORIGIN example.com.\n" + readFixture(t, "dnstestdata/db.example.com")},
+		{name: "Relative names without an origin", zone: readFixture(t, "dnstestdata/db.example.com"), expectError: true},
+		{name: "Only untestable records", origin: "example.com", zone: "@ 300 IN SOA ns1 hostmaster 1 7200 3600 1209600 300\n_sip._tcp 300 IN SRV 10 60 5060 sip", expectError: true},
+		{name: "Missing SOA", origin: "example.com", zone: "www 300 IN A 192.0.2.1", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			zone := tt.zone
+			if tt.filename != "" {
+				zone = readFixture(t, tt.filename)
+			}
+			got, skipped, err := ImportZoneFile(strings.NewReader(zone), tt.origin, tt.filename)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, expectedTests) {
+				t.Errorf("expected tests %+v, got %+v", expectedTests, got)
+			}
+			if !reflect.DeepEqual(skipped, expectedSkipped) {
+				t.Errorf("expected skipped %v, got %v", expectedSkipped, skipped)
+			}
+		})
+	}
+}
+
+func readFixture(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+	return string(data)
+}
@@ -0,0 +1,239 @@
+package config
+
+import (
+	"fmt"
+	"net/netip"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/ch0ppy35/sherlock/internal/dns"
+	d "github.com/miekg/dns"
+)
+
+const (
+	// LintError is a mistake that makes a test fail, or keeps the config from running at all
+	LintError = "error"
+	// LintWarning is likely a mistake, but the tests can still pass
+	LintWarning = "warning"
+)
+
+// LintIssue is a likely mistake in a config
+type LintIssue struct {
+	Test     int // Number of the test, counting from 1, or 0 for the config as a whole
+	Severity string
+	Message  string
+}
+
+// String returns the message, prefixed with the test it's about
+func (i LintIssue) String() string {
+	if i.Test == 0 {
+		return i.Message
+	}
+	return fmt.Sprintf("test %d %s", i.Test, i.Message)
+}
+
+// Lint looks for mistakes that make tests fail or pass for the wrong reason, beyond what Validate checks: unknown
+// test types, duplicate tests, tests that can't pass together, other tests alongside a CNAME, invalid IPs and names
+// missing their trailing dot. Validate's own error is included, the issues are sorted by test
+func Lint(config DNSRecordsFullTestConfig) []LintIssue {
+	var issues []LintIssue
+	if err := config.Validate(); err != nil {
+		issues = append(issues, LintIssue{Severity: LintError, Message: err.Error()})
+	}
+
+	for i, test := range config.Tests {
+		issues = append(issues, lintTest(i+1, test)...)
+	}
+
+	// Pairs are only compared within the same host
+	for j := range config.Tests {
+		for i := 0; i < j; i++ {
+			a, b := config.Tests[i], config.Tests[j]
+			if lintHost(a.Host) != lintHost(b.Host) {
+				continue
+			}
+			if issue, ok := lintPair(i+1, a, j+1, b); ok {
+				issues = append(issues, issue)
+			}
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		return issues[i].Test < issues[j].Test
+	})
+	return issues
+}
+
+// lintTest checks a single test's type and expected values
+func lintTest(n int, test DNSTestConfig) []LintIssue {
+	if test.TestType == "" {
+		return nil
+	}
+	qtype, err := dns.GetQueryTypeFromString(test.TestType)
+	if err != nil {
+		if test.isCheck() {
+			return nil
+		}
+		return []LintIssue{{Test: n, Severity: LintError, Message: fmt.Sprintf("'testType' '%s' is unknown, supported types: a, aaaa, cname, mx, txt, ns, spf, dmarc, dkim, mta-sts", test.TestType)}}
+	}
+
+	var issues []LintIssue
+	for _, val := range test.ExpectedValues {
+		// Invalid patterns are already reported by Validate
+		if dns.IsPattern(val) || dns.ValidateExpectedValue(val) != nil {
+			continue
+		}
+		switch qtype {
+		case d.TypeA, d.TypeAAAA:
+			addr, err := netip.ParseAddr(strings.TrimSpace(val))
+			switch {
+			case qtype == d.TypeA && (err != nil || !addr.Unmap().Is4()):
+				issues = append(issues, LintIssue{Test: n, Severity: LintError, Message: fmt.Sprintf("'expectedValues' has '%s', which isn't an IPv4 address", val)})
+			case qtype == d.TypeAAAA && (err != nil || !addr.Is6()):
+				issues = append(issues, LintIssue{Test: n, Severity: LintError, Message: fmt.Sprintf("'expectedValues' has '%s', which isn't an IPv6 address", val)})
+			}
+		case d.TypeCNAME, d.TypeNS, d.TypeMX:
+			name, _, _ := dns.ParseMXValue(val)
+			if strings.HasSuffix(name, ".") {
+				continue
+			}
+			// Names are normalized to FQDNs before comparing, except in strict mode
+			if test.Strict {
+				issues = append(issues, LintIssue{Test: n, Severity: LintError, Message: fmt.Sprintf("'expectedValues' has '%s' without a trailing dot, which never matches with 'strict' set", val)})
+			} else {
+				issues = append(issues, LintIssue{Test: n, Severity: LintWarning, Message: fmt.Sprintf("'expectedValues' has '%s' without a trailing dot", val)})
+			}
+		}
+	}
+	return issues
+}
+
+// lintPair checks two tests of the same host, the issue is reported on the later test b
+func lintPair(i int, a DNSTestConfig, j int, b DNSTestConfig) (LintIssue, bool) {
+	typeA, typeB := strings.ToLower(a.TestType), strings.ToLower(b.TestType)
+	if typeA == typeB && reflect.DeepEqual(lintComparable(a), lintComparable(b)) {
+		return LintIssue{Test: j, Severity: LintWarning, Message: fmt.Sprintf("duplicates test %d", i)}, true
+	}
+
+	_, errA := dns.GetQueryTypeFromString(typeA)
+	_, errB := dns.GetQueryTypeFromString(typeB)
+	if errA != nil || errB != nil || a.ECS != b.ECS {
+		return LintIssue{}, false
+	}
+
+	if typeA != typeB {
+		// Resolvers answer queries of other types for a name with a CNAME from the CNAME's target
+		cname, other := a, b
+		if typeB == "cname" {
+			cname, other = b, a
+		}
+		if strings.ToLower(cname.TestType) == "cname" && cname.expectsRecords() && other.expectsRecords() {
+			return LintIssue{Test: j, Severity: LintError, Message: fmt.Sprintf("and test %d expect cname and %s records for the same name, a name with a CNAME can't have other records so the %s records come from the CNAME's target",
+				i, strings.ToLower(other.TestType), strings.ToLower(other.TestType))}, true
+		}
+		return LintIssue{}, false
+	}
+
+	if a.RawSegments != b.RawSegments {
+		return LintIssue{}, false
+	}
+	// MX values with and without a preference are compared by host only
+	stripPref := typeA == "mx" && dns.HasMXPreference(a.ExpectedValues) != dns.HasMXPreference(b.ExpectedValues)
+	if reason := lintContradiction(a, b, stripPref); reason != "" {
+		return LintIssue{Test: j, Severity: LintError, Message: fmt.Sprintf("contradicts test %d, %s", i, reason)}, true
+	}
+	if reason := lintContradiction(b, a, stripPref); reason != "" {
+		return LintIssue{Test: j, Severity: LintError, Message: fmt.Sprintf("contradicts test %d, %s", i, reason)}, true
+	}
+	return LintIssue{}, false
+}
+
+// lintContradiction returns why a record of test a can't be returned when test b passes, or an empty string
+func lintContradiction(a DNSTestConfig, b DNSTestConfig, stripPref bool) string {
+	modeA, errA := dns.ParseMatchMode(string(a.Match))
+	modeB, errB := dns.ParseMatchMode(string(b.Match))
+	if errA != nil || errB != nil {
+		return ""
+	}
+
+	if b.MaxCount != nil && *b.MaxCount == 0 && a.expectsRecords() {
+		return fmt.Sprintf("one expects %s records and the other none", strings.ToLower(a.TestType))
+	}
+	if modeA != dns.MatchExact && modeA != dns.MatchContains {
+		return ""
+	}
+
+	required, _ := a.literalValues(stripPref)
+	allowed, hasPatterns := b.literalValues(stripPref)
+	for _, val := range required {
+		switch {
+		case modeB == dns.MatchNone && slices.Contains(allowed, val):
+			return fmt.Sprintf("'%s' is expected by one and must be absent in the other", val)
+		case (modeB == dns.MatchExact || modeB == dns.MatchSubsetOf) && !hasPatterns && !slices.Contains(allowed, val):
+			return fmt.Sprintf("'%s' is expected by one but not allowed by the other", val)
+		}
+	}
+	return ""
+}
+
+// expectsRecords reports whether the test passes only when records are returned
+func (t *DNSTestConfig) expectsRecords() bool {
+	if t.MaxCount != nil && *t.MaxCount == 0 {
+		return false
+	}
+	if t.MinCount != nil && *t.MinCount > 0 {
+		return true
+	}
+	mode, err := dns.ParseMatchMode(string(t.Match))
+	if err != nil {
+		return false
+	}
+	switch mode {
+	case dns.MatchExact, dns.MatchContains, dns.MatchAnyOf:
+		return len(t.ExpectedValues) > 0
+	default:
+		return false
+	}
+}
+
+// literalValues returns the expected values that aren't patterns in the form they're compared in, and whether any
+// of them are patterns
+func (t *DNSTestConfig) literalValues(stripPref bool) ([]string, bool) {
+	qtype, _ := dns.GetQueryTypeFromString(t.TestType)
+	var values []string
+	hasPatterns := false
+	for _, val := range t.ExpectedValues {
+		if dns.IsPattern(val) {
+			hasPatterns = true
+			continue
+		}
+		if stripPref {
+			val, _, _ = dns.ParseMXValue(val)
+		}
+		if !t.Strict {
+			val = dns.NormalizeValue(qtype, val)
+		}
+		values = append(values, val)
+	}
+	return values, hasPatterns
+}
+
+// lintComparable returns the test in a form where equivalent tests are equal
+func lintComparable(t DNSTestConfig) DNSTestConfig {
+	t.Host = lintHost(t.Host)
+	t.TestType = strings.ToLower(t.TestType)
+	if mode, err := dns.ParseMatchMode(string(t.Match)); err == nil {
+		t.Match = mode
+	}
+	if !t.Ordered {
+		t.ExpectedValues = slices.Clone(t.ExpectedValues)
+		sort.Strings(t.ExpectedValues)
+	}
+	return t
+}
+
+func lintHost(host string) string {
+	return strings.ToLower(d.Fqdn(host))
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	zero, two := 0, 2
+	a := func(values ...string) DNSTestConfig {
+		return DNSTestConfig{Host: "example.com", TestType: "a", ExpectedValues: values}
+	}
+
+	tests := []struct {
+		name     string
+		tests    []DNSTestConfig
+		expected []string
+	}{
+		{
+			name: "Clean config",
+			tests: []DNSTestConfig{
+				a("192.0.2.1"),
+				{Host: "example.com", TestType: "aaaa", ExpectedValues: []string{"2001:db8::1"}},
+				{Host: "example.com", TestType: "mx", ExpectedValues: []string{"10 mail.example.com."}},
+				{Host: "www.example.com", TestType: "cname", ExpectedValues: []string{"example.com."}},
+				{Host: "www.example.com", TestType: "aaaa", MaxCount: &zero},
+				{Host: "example.com", TestType: "spf", SenderIP: "192.0.2.1", ExpectedValues: []string{"pass"}},
+				{Host: "example.com", TestType: "a", ExpectedValues: []string{"192.0.2.1"}, ECS: "203.0.113.0/24"},
+			},
+		},
+		{
+			name: "Unknown type",
+			tests: []DNSTestConfig{
+				{Host: "example.com", TestType: "srv", ExpectedValues: []string{"10 60 5060 sip.example.com."}},
+			},
+			expected: []string{"error: test 1 'testType' 'srv' is unknown, supported types: a, aaaa, cname, mx, txt, ns, spf, dmarc, dkim, mta-sts"},
+		},
+		{
+			name: "Invalid IPs",
+			tests: []DNSTestConfig{
+				a("192.0.2.1", "192.0.2.256", "2001:db8::1", "cidr:192.0.2.0/24", "cidr:192.0.2.0/33"),
+				{Host: "example.com", TestType: "aaaa", ExpectedValues: []string{"192.0.2.1"}},
+			},
+			expected: []string{
+				"error: test 1 'expectedValues' is invalid: invalid CIDR in expected value 'cidr:192.0.2.0/33': netip.ParsePrefix(\"192.0.2.0/33\"): prefix length out of range",
+				"error: test 1 'expectedValues' has '192.0.2.256', which isn't an IPv4 address",
+				"error: test 1 'expectedValues' has '2001:db8::1', which isn't an IPv4 address",
+				"error: test 2 'expectedValues' has '192.0.2.1', which isn't an IPv6 address",
+			},
+		},
+		{
+			name: "Missing trailing dots",
+			tests: []DNSTestConfig{
+				{Host: "example.com", TestType: "mx", ExpectedValues: []string{"10 mail.example.com", "20 backup.example.com."}},
+				{Host: "example.com", TestType: "ns", ExpectedValues: []string{"ns1.example.com"}, Strict: true},
+				{Host: "example.com", TestType: "txt", ExpectedValues: []string{"v=spf1 -all"}},
+			},
+			expected: []string{
+				"warning: test 1 'expectedValues' has '10 mail.example.com' without a trailing dot",
+				"error: test 2 'expectedValues' has 'ns1.example.com' without a trailing dot, which never matches with 'strict' set",
+			},
+		},
+		{
+			name: "Duplicates",
+			tests: []DNSTestConfig{
+				a("192.0.2.1", "192.0.2.2"),
+				{Host: "EXAMPLE.com.", TestType: "A", ExpectedValues: []string{"192.0.2.2", "192.0.2.1"}, Match: "exact"},
+			},
+			expected: []string{"warning: test 2 duplicates test 1"},
+		},
+		{
+			name: "Contradictions",
+			tests: []DNSTestConfig{
+				a("192.0.2.1", "192.0.2.2"),
+				a("192.0.2.3"),
+				{Host: "example.com", TestType: "a", ExpectedValues: []string{"192.0.2.1"}, Match: "none"},
+				{Host: "example.com", TestType: "a", MaxCount: &zero},
+			},
+			expected: []string{
+				"error: test 2 contradicts test 1, '192.0.2.1' is expected by one but not allowed by the other",
+				"error: test 3 contradicts test 1, '192.0.2.1' is expected by one and must be absent in the other",
+				"error: test 4 contradicts test 1, one expects a records and the other none",
+				"error: test 4 contradicts test 2, one expects a records and the other none",
+			},
+		},
+		{
+			name: "Compatible expectations",
+			tests: []DNSTestConfig{
+				a("192.0.2.1", "192.0.2.2"),
+				{Host: "example.com", TestType: "a", ExpectedValues: []string{"192.0.2.1"}, Match: "contains"},
+				{Host: "example.com", TestType: "a", ExpectedValues: []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"}, Match: "subsetOf"},
+				{Host: "example.com", TestType: "a", ExpectedValues: []string{"cidr:192.0.2.0/24"}, Match: "subsetOf", MinCount: &two},
+				{Host: "example.com", TestType: "mx", ExpectedValues: []string{"10 mail.example.com."}},
+				{Host: "example.com", TestType: "mx", ExpectedValues: []string{"MAIL.example.com."}},
+			},
+		},
+		{
+			name: "CNAME alongside other records",
+			tests: []DNSTestConfig{
+				{Host: "www.example.com", TestType: "cname", ExpectedValues: []string{"example.com."}},
+				{Host: "www.example.com", TestType: "a", ExpectedValues: []string{"192.0.2.1"}},
+				{Host: "www.example.com", TestType: "txt", MinCount: &two},
+			},
+			expected: []string{
+				"error: test 2 and test 1 expect cname and a records for the same name, a name with a CNAME can't have other records so the a records come from the CNAME's target",
+				"error: test 3 and test 1 expect cname and txt records for the same name, a name with a CNAME can't have other records so the txt records come from the CNAME's target",
+			},
+		},
+		{
+			name:     "Validation error",
+			tests:    []DNSTestConfig{{Host: "example.com", TestType: "a"}},
+			expected: []string{"error: test 1 'expectedValues' must be set and contain at least one value"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, issue := range Lint(DNSRecordsFullTestConfig{DNSServer: "192.0.2.53", Tests: tt.tests}) {
+				got = append(got, issue.Severity+": "+issue.String())
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected issues %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
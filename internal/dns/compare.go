@@ -2,52 +2,251 @@ package dns
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"strings"
 
 	"github.com/jedib0t/go-pretty/v6/table"
 )
 
+// MatchMode controls how the expected records are compared against the actual records
+type MatchMode string
+
+const (
+	// MatchExact requires the actual records to be exactly the expected records
+	MatchExact MatchMode = "exact"
+	// MatchContains requires every expected record to be present, extra records are allowed
+	MatchContains MatchMode = "contains"
+	// MatchSubsetOf requires every actual record to be one of the expected records, missing records are allowed
+	MatchSubsetOf MatchMode = "subsetOf"
+	// MatchAnyOf requires at least one of the expected records to be present
+	MatchAnyOf MatchMode = "anyOf"
+	// MatchNone requires none of the expected records to be present
+	MatchNone MatchMode = "none"
+)
+
+// ParseMatchMode maps the string match mode to a MatchMode, an empty string is the exact mode
+func ParseMatchMode(mode string) (MatchMode, error) {
+	switch strings.ToLower(mode) {
+	case "", "exact":
+		return MatchExact, nil
+	case "contains":
+		return MatchContains, nil
+	case "subsetof":
+		return MatchSubsetOf, nil
+	case "anyof":
+		return MatchAnyOf, nil
+	case "none":
+		return MatchNone, nil
+	default:
+		return "", fmt.Errorf("unsupported match mode, supported modes: exact, contains, subsetOf, anyOf, none")
+	}
+}
+
+// CompareOptions tweaks how records are compared, the zero value is an exact comparison
+type CompareOptions struct {
+	Match    MatchMode
+	MinCount *int
+	MaxCount *int
+	// RecordType is the test type of the records, used to normalize the values before comparing them
+	RecordType string
+	// Strict disables normalization so values must match byte for byte
+	Strict bool
+}
+
+// RecordComparison holds the outcome of comparing expected and actual DNS records
+type RecordComparison struct {
+	Matched    []string
+	Unexpected []string
+	Missing    []string
+	// MatchedBy maps matched records to the cidr:, re: or glob: pattern they matched
+	MatchedBy map[string]string
+	Mode      MatchMode
+	Passed    bool
+	// Reasons explains why the comparison passed or failed under the chosen mode
+	Reasons []string
+}
+
 // CompareRecords compares expected and actual DNS records, printing the results in a formatted table and returning an error if mismatches are found
-func CompareRecords(expected []string, actual []string) error {
-	expectedMap := make(map[string]struct{}, len(expected))
-	for _, val := range expected {
-		expectedMap[val] = struct{}{}
+func CompareRecords(expected []string, actual []string, opts CompareOptions) error {
+	return DiffRecords(expected, actual, opts).Report()
+}
+
+// DiffRecords sorts the actual DNS records into matched and unexpected ones, collects the expected records that are missing
+// and decides whether the comparison passes under the match mode. Expected values may be cidr:, re: or glob: patterns.
+// Unless opts.Strict is set, values are normalized for opts.RecordType before they are compared
+func DiffRecords(expected []string, actual []string, opts CompareOptions) *RecordComparison {
+	c := &RecordComparison{
+		Matched:    []string{},
+		Unexpected: []string{},
+		Missing:    []string{},
+		MatchedBy:  map[string]string{},
 	}
 
-	actualMap := make(map[string]struct{}, len(actual))
-	for _, val := range actual {
-		actualMap[val] = struct{}{}
+	normalize := func(val string) string { return val }
+	if qtype, err := GetQueryTypeFromString(opts.RecordType); err == nil && !opts.Strict {
+		normalize = func(val string) string { return NormalizeValue(qtype, val) }
 	}
 
-	matchedRecords := []string{}
-	unexpectedRecords := []string{}
-	missingRecords := []string{}
+	matchers := make([]valueMatcher, 0, len(expected))
+	for _, val := range expected {
+		matcher, err := newValueMatcher(val)
+		if err != nil {
+			c.Reasons = append(c.Reasons, err.Error())
+			return c
+		}
+		if literal, ok := matcher.(literalMatcher); ok {
+			matcher = literalMatcher(normalize(string(literal)))
+		}
+		matchers = append(matchers, matcher)
+	}
 
+	used := make([]bool, len(expected))
 	for _, val := range actual {
-		if _, found := expectedMap[val]; !found {
-			unexpectedRecords = append(unexpectedRecords, val)
+		found := false
+		for i, matcher := range matchers {
+			if matcher.Match(normalize(val)) {
+				if !found && matcher.IsPattern() {
+					c.MatchedBy[val] = expected[i]
+				}
+				found = true
+				used[i] = true
+			}
+		}
+		if found {
+			c.Matched = append(c.Matched, val)
 		} else {
-			matchedRecords = append(matchedRecords, val)
+			c.Unexpected = append(c.Unexpected, val)
 		}
 	}
 
-	for _, val := range expected {
-		if _, found := actualMap[val]; !found {
-			missingRecords = append(missingRecords, val)
+	for i, val := range expected {
+		if !used[i] {
+			c.Missing = append(c.Missing, val)
+		}
+	}
+
+	c.evaluate(len(expected), len(actual), opts)
+	return c
+}
+
+// evaluate applies the match mode and count bounds to the comparison of the expected and actual records
+func (c *RecordComparison) evaluate(expected int, count int, opts CompareOptions) {
+	mode, err := ParseMatchMode(string(opts.Match))
+	if err != nil {
+		c.Reasons = append(c.Reasons, err.Error())
+		return
+	}
+	c.Mode = mode
+	c.Passed = true
+
+	fail := func(format string, a ...any) {
+		c.Passed = false
+		c.Reasons = append(c.Reasons, fmt.Sprintf(format, a...))
+	}
+
+	switch mode {
+	case MatchExact:
+		if len(c.Missing) > 0 {
+			fail("%d expected record(s) missing", len(c.Missing))
+		}
+		if len(c.Unexpected) > 0 {
+			fail("%d unexpected record(s) found", len(c.Unexpected))
+		}
+		if c.Passed {
+			c.Reasons = append(c.Reasons, "all expected records present and nothing unexpected")
+		}
+	case MatchContains:
+		if len(c.Missing) > 0 {
+			fail("%d expected record(s) missing", len(c.Missing))
+		} else {
+			c.Reasons = append(c.Reasons, "all expected records present")
+		}
+	case MatchSubsetOf:
+		if len(c.Unexpected) > 0 {
+			fail("%d record(s) outside the expected set", len(c.Unexpected))
+		} else {
+			c.Reasons = append(c.Reasons, "every record is in the expected set")
+		}
+	case MatchAnyOf:
+		if present := expected - len(c.Missing); present == 0 {
+			fail("none of the %d expected records are present", expected)
+		} else {
+			c.Reasons = append(c.Reasons, fmt.Sprintf("%d of the %d expected records present", present, expected))
+		}
+	case MatchNone:
+		if len(c.Matched) > 0 {
+			fail("%d record(s) present that should be absent", len(c.Matched))
+		} else {
+			c.Reasons = append(c.Reasons, "none of the listed records are present")
 		}
 	}
 
-	printDNSComparisonTable(matchedRecords, unexpectedRecords, missingRecords)
+	if opts.MinCount != nil && count < *opts.MinCount {
+		fail("found %d record(s), fewer than minCount %d", count, *opts.MinCount)
+	}
+	if opts.MaxCount != nil && count > *opts.MaxCount {
+		fail("found %d record(s), more than maxCount %d", count, *opts.MaxCount)
+	}
+}
 
-	if len(unexpectedRecords) > 0 || len(missingRecords) > 0 {
+// NewRecordComparison returns an empty, passing comparison for checks that add their own reasons
+func NewRecordComparison() *RecordComparison {
+	return &RecordComparison{
+		Matched:    []string{},
+		Unexpected: []string{},
+		Missing:    []string{},
+		MatchedBy:  map[string]string{},
+		Mode:       MatchExact,
+		Passed:     true,
+	}
+}
+
+// Note adds a reason to the comparison without changing its outcome
+func (c *RecordComparison) Note(reason string) {
+	c.Reasons = append(c.Reasons, reason)
+}
+
+// Fail marks the comparison as failed for an additional reason found outside of the record comparison
+func (c *RecordComparison) Fail(reason string) {
+	c.Passed = false
+	c.Reasons = append(c.Reasons, reason)
+}
+
+// Report prints the comparison in a formatted table and returns an error if the comparison failed
+func (c *RecordComparison) Report() error {
+	return c.ReportTo(os.Stdout)
+}
+
+// ReportTo writes the comparison as a formatted table to output and returns an error if the comparison failed
+func (c *RecordComparison) ReportTo(output io.Writer) error {
+	matched := make([]string, 0, len(c.Matched))
+	for _, record := range c.Matched {
+		if pattern, ok := c.MatchedBy[record]; ok {
+			record = fmt.Sprintf("%s (%s)", record, pattern)
+		}
+		matched = append(matched, record)
+	}
+	printDNSComparisonTable(output, matched, c.Unexpected, c.Missing, c.result())
+
+	if !c.Passed {
 		return fmt.Errorf("mismatched records found")
 	}
 	return nil
 }
 
-func printDNSComparisonTable(matched, unexpected, missing []string) {
+// result summarizes the outcome of the comparison for the table
+func (c *RecordComparison) result() string {
+	status := "FAIL"
+	if c.Passed {
+		status = "PASS"
+	}
+	return fmt.Sprintf("%s (%s): %s", status, c.Mode, strings.Join(c.Reasons, "; "))
+}
+
+func printDNSComparisonTable(output io.Writer, matched, unexpected, missing []string, result string) {
 	t := table.NewWriter()
-	t.SetOutputMirror(os.Stdout)
+	t.SetOutputMirror(output)
 	t.SetStyle(table.StyleRounded)
 	t.AppendHeader(table.Row{"Type", "Record"})
 
@@ -78,5 +277,8 @@ func printDNSComparisonTable(matched, unexpected, missing []string) {
 		t.AppendRows([]table.Row{{"Missing", "None"}})
 	}
 
+	t.AppendSeparator()
+	t.AppendRows([]table.Row{{"Result", result}})
+
 	t.Render()
 }
@@ -72,9 +72,158 @@ func TestCompareRecords(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if err := CompareRecords(tt.args.expected, tt.args.actual); (err != nil) != tt.wantErr {
+			if err := CompareRecords(tt.args.expected, tt.args.actual, CompareOptions{}); (err != nil) != tt.wantErr {
 				t.Errorf("CompareRecords() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
 }
+
+func TestDiffRecords(t *testing.T) {
+	intPtr := func(i int) *int { return &i }
+
+	tests := []struct {
+		name       string
+		expected   []string
+		actual     []string
+		opts       CompareOptions
+		wantPassed bool
+		wantMode   MatchMode
+		wantReason string
+	}{
+		{
+			name:       "Default mode is exact",
+			expected:   []string{"10.0.0.1"},
+			actual:     []string{"10.0.0.1", "10.0.0.2"},
+			wantPassed: false,
+			wantMode:   MatchExact,
+		},
+		{
+			name:       "Contains allows extra records",
+			expected:   []string{"10.0.0.1"},
+			actual:     []string{"10.0.0.1", "10.0.0.2"},
+			opts:       CompareOptions{Match: "contains"},
+			wantPassed: true,
+			wantMode:   MatchContains,
+		},
+		{
+			name:       "Contains fails on missing records",
+			expected:   []string{"10.0.0.1", "10.0.0.3"},
+			actual:     []string{"10.0.0.1", "10.0.0.2"},
+			opts:       CompareOptions{Match: "contains"},
+			wantPassed: false,
+			wantMode:   MatchContains,
+		},
+		{
+			name:       "SubsetOf allows missing records",
+			expected:   []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"},
+			actual:     []string{"10.0.0.1"},
+			opts:       CompareOptions{Match: "subsetOf"},
+			wantPassed: true,
+			wantMode:   MatchSubsetOf,
+		},
+		{
+			name:       "SubsetOf fails on unexpected records",
+			expected:   []string{"10.0.0.1"},
+			actual:     []string{"10.0.0.1", "10.0.0.9"},
+			opts:       CompareOptions{Match: "subsetOf"},
+			wantPassed: false,
+			wantMode:   MatchSubsetOf,
+		},
+		{
+			name:       "AnyOf passes with a single match",
+			expected:   []string{"10.0.0.1", "10.0.0.2"},
+			actual:     []string{"10.0.0.2", "10.0.0.9"},
+			opts:       CompareOptions{Match: "anyOf"},
+			wantPassed: true,
+			wantMode:   MatchAnyOf,
+			wantReason: "1 of the 2 expected records present",
+		},
+		{
+			name:       "AnyOf counts expected values rather than matched records",
+			expected:   []string{"cidr:10.0.0.0/24", "10.0.1.1"},
+			actual:     []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"},
+			opts:       CompareOptions{Match: MatchAnyOf},
+			wantPassed: true,
+			wantMode:   MatchAnyOf,
+			wantReason: "1 of the 2 expected records present",
+		},
+		{
+			name:       "AnyOf fails with no matches",
+			expected:   []string{"10.0.0.1", "10.0.0.2"},
+			actual:     []string{"10.0.0.9"},
+			opts:       CompareOptions{Match: "anyOf"},
+			wantPassed: false,
+			wantMode:   MatchAnyOf,
+			wantReason: "none of the 2 expected records are present",
+		},
+		{
+			name:       "None passes when the records are absent",
+			expected:   []string{"10.0.0.1"},
+			actual:     []string{"10.0.0.9"},
+			opts:       CompareOptions{Match: "none"},
+			wantPassed: true,
+			wantMode:   MatchNone,
+		},
+		{
+			name:       "None fails when a record is present",
+			expected:   []string{"10.0.0.1"},
+			actual:     []string{"10.0.0.1"},
+			opts:       CompareOptions{Match: "NONE"},
+			wantPassed: false,
+			wantMode:   MatchNone,
+		},
+		{
+			name:       "MinCount not reached",
+			expected:   []string{"10.0.0.1"},
+			actual:     []string{"10.0.0.1"},
+			opts:       CompareOptions{Match: "contains", MinCount: intPtr(2)},
+			wantPassed: false,
+			wantMode:   MatchContains,
+		},
+		{
+			name:       "MaxCount exceeded",
+			expected:   []string{},
+			actual:     []string{"10.0.0.1", "10.0.0.2"},
+			opts:       CompareOptions{Match: "subsetOf", MaxCount: intPtr(1)},
+			wantPassed: false,
+			wantMode:   MatchSubsetOf,
+		},
+		{
+			name:       "Counts within bounds",
+			expected:   []string{},
+			actual:     []string{"10.0.0.1", "10.0.0.2"},
+			opts:       CompareOptions{Match: "contains", MinCount: intPtr(1), MaxCount: intPtr(3)},
+			wantPassed: true,
+			wantMode:   MatchContains,
+		},
+		{
+			name:       "Unknown match mode",
+			expected:   []string{"10.0.0.1"},
+			actual:     []string{"10.0.0.1"},
+			opts:       CompareOptions{Match: "mostly"},
+			wantPassed: false,
+			wantMode:   "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DiffRecords(tt.expected, tt.actual, tt.opts)
+			if got.Passed != tt.wantPassed {
+				t.Errorf("DiffRecords() passed = %v, want %v (reasons: %v)", got.Passed, tt.wantPassed, got.Reasons)
+			}
+			if got.Mode != tt.wantMode {
+				t.Errorf("DiffRecords() mode = %v, want %v", got.Mode, tt.wantMode)
+			}
+			if len(got.Reasons) == 0 {
+				t.Errorf("DiffRecords() gave no reasons for the outcome")
+			} else if tt.wantReason != "" && got.Reasons[0] != tt.wantReason {
+				t.Errorf("DiffRecords() reason = %q, want %q", got.Reasons[0], tt.wantReason)
+			}
+			if err := got.Report(); (err != nil) == tt.wantPassed {
+				t.Errorf("Report() error = %v, passed %v", err, tt.wantPassed)
+			}
+		})
+	}
+}
@@ -1,20 +1,36 @@
 package dns
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"strings"
 	"time"
 
 	"github.com/miekg/dns"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("github.com/ch0ppy35/sherlock/internal/dns")
+
 type DNSRecords struct {
 	ARecords     []string
 	AAAARecords  []string
 	CNAMERecords []string
 	MXRecords    []MXRecord
 	TXTRecords   []string
-	NSRecords    []string
+	// TXTSegments holds the raw character strings of each TXT record, TXTRecords holds them joined
+	TXTSegments [][]string
+	NSRecords   []string
+	// Flags holds the header flags of the response to each query type
+	Flags map[uint16]ResponseFlags
+	// ClientSubnetScopes holds the EDNS client subnet scope prefix length returned for each query type, if any
+	ClientSubnetScopes map[uint16]uint8
+	// Durations holds how long the query of each type took
+	Durations map[uint16]time.Duration
 }
 
 type MXRecord struct {
@@ -22,19 +38,14 @@ type MXRecord struct {
 	Pref uint16
 }
 
-// IDNSClient is a small 'github.com/miekg/dns.client' implementation for easy testing
+// QueryOption adjusts a query message before it's sent
+type QueryOption func(msg *dns.Msg)
+
+// IDNSClient is the part of 'github.com/miekg/dns.client' used to send queries, so other clients can be swapped in
 type IDNSClient interface {
 	Exchange(msg *dns.Msg, server string) (*dns.Msg, time.Duration, error)
 }
 
-type MockIDNSClient struct {
-	MockExchange func(*dns.Msg, string) (*dns.Msg, time.Duration, error)
-}
-
-func (m *MockIDNSClient) Exchange(msg *dns.Msg, server string) (*dns.Msg, time.Duration, error) {
-	return m.MockExchange(msg, server)
-}
-
 func (r *DNSRecords) addARecord(rr dns.RR) {
 	if a, ok := rr.(*dns.A); ok {
 		r.ARecords = append(r.ARecords, a.A.String())
@@ -64,7 +75,9 @@ func (r *DNSRecords) addMXRecord(rr dns.RR) {
 
 func (r *DNSRecords) addTXTRecord(rr dns.RR) {
 	if txt, ok := rr.(*dns.TXT); ok {
-		r.TXTRecords = append(r.TXTRecords, txt.Txt...)
+		// Long values such as DKIM keys are split into 255 byte strings, but they're still a single record
+		r.TXTRecords = append(r.TXTRecords, strings.Join(txt.Txt, ""))
+		r.TXTSegments = append(r.TXTSegments, txt.Txt)
 	}
 }
 
@@ -74,25 +87,62 @@ func (r *DNSRecords) addNSRecord(rr dns.RR) {
 	}
 }
 
-// QueryDNS fetches DNS records of various types for a given domain
-func QueryDNS(domain string, dnsServer string, client IDNSClient) (*DNSRecords, error) {
+// setters maps the supported record types to the functions adding them to the records
+func (r *DNSRecords) setters() map[uint16]func(rr dns.RR) {
+	return map[uint16]func(rr dns.RR){
+		dns.TypeA:     r.addARecord,
+		dns.TypeAAAA:  r.addAAAARecord,
+		dns.TypeCNAME: r.addCNAMERecord,
+		dns.TypeMX:    r.addMXRecord,
+		dns.TypeTXT:   r.addTXTRecord,
+		dns.TypeNS:    r.addNSRecord,
+	}
+}
+
+// NewDNSRecords collects the records of the supported types the same way QueryDNS collects answers, others are ignored
+func NewDNSRecords(rrs []dns.RR) *DNSRecords {
 	records := &DNSRecords{}
-	server := dnsServer + ":53"
+	setters := records.setters()
+	for _, rr := range rrs {
+		if setter, ok := setters[rr.Header().Rrtype]; ok {
+			setter(rr)
+		}
+	}
+	return records
+}
 
-	// Map of DNS record types to their corresponding handler functions
-	queryTypes := map[uint16]func(rr dns.RR){
-		dns.TypeA:     records.addARecord,
-		dns.TypeAAAA:  records.addAAAARecord,
-		dns.TypeCNAME: records.addCNAMERecord,
-		dns.TypeMX:    records.addMXRecord,
-		dns.TypeTXT:   records.addTXTRecord,
-		dns.TypeNS:    records.addNSRecord,
+// ServerAddress adds the default port 53 to a DNS server that doesn't include one, e.g. 1.1.1.1 or 2606:4700::1111
+func ServerAddress(dnsServer string) string {
+	if _, _, err := net.SplitHostPort(dnsServer); err == nil {
+		return dnsServer
 	}
+	return net.JoinHostPort(strings.Trim(dnsServer, "[]"), "53")
+}
+
+// QueryDNS fetches DNS records of various types for a given domain
+func QueryDNS(domain string, dnsServer string, client IDNSClient) (*DNSRecords, error) {
+	return QueryDNSContext(context.Background(), domain, dnsServer, client)
+}
 
-	for qtype, setter := range queryTypes {
-		if err := QueryDNSRecord(client, domain, server, qtype, setter); err != nil {
+// QueryDNSContext is QueryDNS with a context used to trace the individual queries, the options are applied to every query
+func QueryDNSContext(ctx context.Context, domain string, dnsServer string, client IDNSClient, opts ...QueryOption) (*DNSRecords, error) {
+	records := &DNSRecords{Flags: make(map[uint16]ResponseFlags), Durations: make(map[uint16]time.Duration)}
+	server := ServerAddress(dnsServer)
+
+	for qtype, setter := range records.setters() {
+		start := time.Now()
+		resp, err := queryDNSRecord(ctx, client, domain, server, qtype, setter, opts)
+		if err != nil {
 			return nil, fmt.Errorf("failed to query DNS records: %w", err)
 		}
+		records.Durations[qtype] = time.Since(start)
+		records.Flags[qtype] = newResponseFlags(resp)
+		if scope, ok := clientSubnetScope(resp); ok {
+			if records.ClientSubnetScopes == nil {
+				records.ClientSubnetScopes = make(map[uint16]uint8)
+			}
+			records.ClientSubnetScopes[qtype] = scope
+		}
 	}
 
 	return records, nil
@@ -100,18 +150,67 @@ func QueryDNS(domain string, dnsServer string, client IDNSClient) (*DNSRecords,
 
 // QueryDNSRecord queries a specific DNS record type and processes the results using a setter function
 func QueryDNSRecord(client IDNSClient, domain string, server string, qtype uint16, setter func(dns.RR)) error {
+	return QueryDNSRecordContext(context.Background(), client, domain, server, qtype, setter)
+}
+
+// QueryDNSRecordContext is QueryDNSRecord with a context, the exchange is recorded as a span
+func QueryDNSRecordContext(ctx context.Context, client IDNSClient, domain string, server string, qtype uint16, setter func(dns.RR)) error {
+	_, err := QueryDNSRecordWithFlags(ctx, client, domain, server, qtype, setter)
+	return err
+}
+
+// QueryDNSRecordWithFlags is QueryDNSRecordContext that also returns the header flags of the response
+func QueryDNSRecordWithFlags(ctx context.Context, client IDNSClient, domain string, server string, qtype uint16, setter func(dns.RR), opts ...QueryOption) (ResponseFlags, error) {
+	resp, err := queryDNSRecord(ctx, client, domain, server, qtype, setter, opts)
+	if err != nil {
+		return ResponseFlags{}, err
+	}
+	return newResponseFlags(resp), nil
+}
+
+// queryDNSRecord sends the query and passes the answers to the setter
+func queryDNSRecord(ctx context.Context, client IDNSClient, domain string, server string, qtype uint16, setter func(dns.RR), opts []QueryOption) (*dns.Msg, error) {
 	msg := new(dns.Msg)
 	msg.SetQuestion(dns.Fqdn(domain), qtype)
-	resp, _, err := client.Exchange(msg, server)
+	for _, opt := range opts {
+		opt(msg)
+	}
+
+	resp, err := ExchangeContext(ctx, client, msg, server)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	for _, answer := range resp.Answer {
 		setter(answer)
 	}
 
-	return nil
+	return resp, nil
+}
+
+// ExchangeContext sends a prepared message and records the exchange as a span
+func ExchangeContext(ctx context.Context, client IDNSClient, msg *dns.Msg, server string) (*dns.Msg, error) {
+	question := msg.Question[0]
+	_, span := tracer.Start(ctx, "dns.exchange", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("dns.question.name", question.Name),
+		attribute.String("dns.question.type", dns.TypeToString[question.Qtype]),
+		attribute.String("server.address", server),
+	))
+	defer span.End()
+
+	resp, rtt, err := client.Exchange(msg, server)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	span.SetAttributes(
+		attribute.String("dns.response.rcode", dns.RcodeToString[resp.Rcode]),
+		attribute.Int("dns.response.answer_count", len(resp.Answer)),
+		attribute.Float64("dns.rtt_ms", float64(rtt)/float64(time.Millisecond)),
+	)
+	return resp, nil
 }
 
 // QueryAndExtract handles the DNS query and extracts the relevant records
@@ -188,3 +287,28 @@ func ExtractRecords[T uint16 | string](records *DNSRecords, qtype T) ([]string,
 	}
 	return []string{}, nil
 }
+
+// ExtractComparableRecords extracts the records for the test type in the form they should be compared with the
+// expected values in, MX records include their preference when the expected values do and TXT records are
+// split back into their raw segments when rawSegments is set
+func ExtractComparableRecords(records *DNSRecords, testType string, expected []string, rawSegments bool) ([]string, error) {
+	values, err := ExtractRecords(records, testType)
+	if err != nil {
+		return values, err
+	}
+
+	if strings.EqualFold(testType, "txt") && rawSegments {
+		values = []string{}
+		for _, segments := range records.TXTSegments {
+			values = append(values, segments...)
+		}
+	}
+
+	if strings.EqualFold(testType, "mx") && HasMXPreference(expected) {
+		values = []string{}
+		for _, mx := range records.MXRecords {
+			values = append(values, mx.String())
+		}
+	}
+	return values, nil
+}
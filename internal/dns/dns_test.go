@@ -10,6 +10,15 @@ import (
 	"github.com/miekg/dns"
 )
 
+// MockIDNSClient answers queries with a function, code outside of this package tests with dnstest instead
+type MockIDNSClient struct {
+	MockExchange func(*dns.Msg, string) (*dns.Msg, time.Duration, error)
+}
+
+func (m *MockIDNSClient) Exchange(msg *dns.Msg, server string) (*dns.Msg, time.Duration, error) {
+	return m.MockExchange(msg, server)
+}
+
 func TestQueryDNSRecord(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -90,6 +99,15 @@ func TestQueryDNSRecord(t *testing.T) {
 	}
 }
 
+// noResponseFlags returns unset flags for every type queried by QueryDNS
+func noResponseFlags() map[uint16]ResponseFlags {
+	flags := make(map[uint16]ResponseFlags)
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA, dns.TypeCNAME, dns.TypeMX, dns.TypeTXT, dns.TypeNS} {
+		flags[qtype] = ResponseFlags{}
+	}
+	return flags
+}
+
 func TestQueryDNS(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -113,6 +131,24 @@ func TestQueryDNS(t *testing.T) {
 				ARecords: []string{"10.0.0.1"},
 			},
 		},
+		{
+			name:   "Response flags are kept",
+			domain: "example.com",
+			mockResponses: map[uint16]*dns.Msg{
+				dns.TypeA: {
+					MsgHdr: dns.MsgHdr{Response: true, Authoritative: true, RecursionDesired: true},
+					Answer: []dns.RR{
+						&dns.A{Hdr: dns.RR_Header{Name: "example.com."}, A: net.ParseIP("10.0.0.1")},
+					},
+				},
+			},
+			expected: &DNSRecords{
+				ARecords: []string{"10.0.0.1"},
+				Flags: map[uint16]ResponseFlags{
+					dns.TypeA: {Response: true, Authoritative: true, RecursionDesired: true},
+				},
+			},
+		},
 		{
 			name:   "Valid AAAA record query",
 			domain: "example.com",
@@ -168,7 +204,23 @@ func TestQueryDNS(t *testing.T) {
 				},
 			},
 			expected: &DNSRecords{
-				TXTRecords: []string{"v=spf1 include:_spf.example.com ~all"},
+				TXTRecords:  []string{"v=spf1 include:_spf.example.com ~all"},
+				TXTSegments: [][]string{{"v=spf1 include:_spf.example.com ~all"}},
+			},
+		},
+		{
+			name:   "Multi-string TXT record query",
+			domain: "selector._domainkey.example.com",
+			mockResponses: map[uint16]*dns.Msg{
+				dns.TypeTXT: {
+					Answer: []dns.RR{
+						&dns.TXT{Hdr: dns.RR_Header{Name: "selector._domainkey.example.com."}, Txt: []string{"v=DKIM1; k=rsa; p=MIIBIjAN", "BgkqhkiG9w0BAQEFAAOC"}},
+					},
+				},
+			},
+			expected: &DNSRecords{
+				TXTRecords:  []string{"v=DKIM1; k=rsa; p=MIIBIjANBgkqhkiG9w0BAQEFAAOC"},
+				TXTSegments: [][]string{{"v=DKIM1; k=rsa; p=MIIBIjAN", "BgkqhkiG9w0BAQEFAAOC"}},
 			},
 		},
 		{
@@ -221,6 +273,25 @@ func TestQueryDNS(t *testing.T) {
 				return
 			}
 
+			// Every query type has flags, those the test doesn't set are expected to be unset
+			if tt.expected != nil {
+				flags := tt.expected.Flags
+				tt.expected.Flags = noResponseFlags()
+				for qtype, f := range flags {
+					tt.expected.Flags[qtype] = f
+				}
+			}
+
+			// Durations vary between runs, only their presence is checked
+			if records != nil {
+				for qtype := range records.Flags {
+					if _, ok := records.Durations[qtype]; !ok {
+						t.Errorf("QueryDNS() has no duration for type %d", qtype)
+					}
+				}
+				records.Durations = nil
+			}
+
 			if !reflect.DeepEqual(records, tt.expected) {
 				t.Errorf("QueryDNS() = %v, expected %v", records, tt.expected)
 			}
@@ -603,3 +674,38 @@ func TestExtractRecords(t *testing.T) {
 		})
 	}
 }
+
+func TestExtractComparableRecordsTXT(t *testing.T) {
+	records := &DNSRecords{
+		TXTRecords:  []string{"part one part two", "single"},
+		TXTSegments: [][]string{{"part one ", "part two"}, {"single"}},
+	}
+
+	tests := []struct {
+		name        string
+		rawSegments bool
+		want        []string
+	}{
+		{
+			name: "Joined records",
+			want: []string{"part one part two", "single"},
+		},
+		{
+			name:        "Raw segments",
+			rawSegments: true,
+			want:        []string{"part one ", "part two", "single"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExtractComparableRecords(records, "txt", nil, tt.rawSegments)
+			if err != nil {
+				t.Fatalf("ExtractComparableRecords() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExtractComparableRecords() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
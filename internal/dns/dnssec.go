@@ -0,0 +1,345 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// RootTrustAnchor is the DS record of the root zone's KSK-2017
+const RootTrustAnchor = ". IN DS 20326 8 2 E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D"
+
+// DefaultSignatureExpiryWarning is how close to expiring a signature has to be before it's reported
+const DefaultSignatureExpiryWarning = 7 * 24 * time.Hour
+
+// ParseTrustAnchor parses a DS or DNSKEY record in zone file format
+func ParseTrustAnchor(anchor string) (dns.RR, error) {
+	rr, err := dns.NewRR(anchor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trust anchor '%s': %w", anchor, err)
+	}
+	switch rr.(type) {
+	case *dns.DS, *dns.DNSKEY:
+		return rr, nil
+	default:
+		return nil, fmt.Errorf("invalid trust anchor '%s', must be a DS or DNSKEY record", anchor)
+	}
+}
+
+// DNSSECValidator validates the chain of trust from its trust anchors down to an RRset
+type DNSSECValidator struct {
+	Client        IDNSClient
+	Server        string
+	TrustAnchors  []dns.RR
+	ExpiryWarning time.Duration
+	Now           func() time.Time
+}
+
+// NewDNSSECValidator creates a validator, the root trust anchor and the default expiry warning are used when unset
+func NewDNSSECValidator(client IDNSClient, dnsServer string, anchors []string, expiryWarning time.Duration) (*DNSSECValidator, error) {
+	if len(anchors) == 0 {
+		anchors = []string{RootTrustAnchor}
+	}
+	if expiryWarning <= 0 {
+		expiryWarning = DefaultSignatureExpiryWarning
+	}
+
+	v := &DNSSECValidator{Client: client, Server: ServerAddress(dnsServer), ExpiryWarning: expiryWarning, Now: time.Now}
+	for _, anchor := range anchors {
+		rr, err := ParseTrustAnchor(anchor)
+		if err != nil {
+			return nil, err
+		}
+		v.TrustAnchors = append(v.TrustAnchors, rr)
+	}
+	return v, nil
+}
+
+// DNSSECResult is the outcome of a successful validation
+type DNSSECResult struct {
+	// Chain lists the zones whose keys were validated, from the trust anchor down
+	Chain []string
+	// Records holds the RRSIG, DNSKEY and DS records that were used
+	Records []dns.RR
+	// Warnings lists the signatures that expire within the warning threshold
+	Warnings []string
+	// Expires is when the first signature used expires
+	Expires time.Time
+}
+
+// signedRRset is an RRset from an answer along with the signatures covering it
+type signedRRset struct {
+	name   string
+	rrtype uint16
+	rrs    []dns.RR
+	sigs   []*dns.RRSIG
+}
+
+func (s *signedRRset) String() string {
+	return s.name + " " + dns.TypeToString[s.rrtype]
+}
+
+// dnssecValidation holds the state of a single validation, zone keys are only validated once
+type dnssecValidation struct {
+	ctx       context.Context
+	validator *DNSSECValidator
+	now       time.Time
+	result    *DNSSECResult
+	keys      map[string][]*dns.DNSKEY
+}
+
+// Validate queries the RRsets answering name and qtype with the DO bit set and validates each of them
+func (v *DNSSECValidator) Validate(ctx context.Context, name string, qtype uint16) (*DNSSECResult, error) {
+	e := &dnssecValidation{
+		ctx:       ctx,
+		validator: v,
+		now:       v.Now(),
+		result:    &DNSSECResult{},
+		keys:      make(map[string][]*dns.DNSKEY),
+	}
+
+	rrsets, err := e.query(name, qtype)
+	if err != nil {
+		return nil, err
+	}
+	if len(rrsets) == 0 {
+		return nil, fmt.Errorf("no %s records found for %s", dns.TypeToString[qtype], dns.Fqdn(name))
+	}
+
+	// A CNAME chain gives several RRsets, each of them has to be valid
+	for _, rrset := range rrsets {
+		if len(rrset.sigs) == 0 {
+			return nil, fmt.Errorf("%s is unsigned", rrset)
+		}
+		keys, err := e.zoneKeys(rrset.sigs[0].SignerName)
+		if err != nil {
+			return nil, err
+		}
+		if err := e.verify(rrset, keys); err != nil {
+			return nil, err
+		}
+	}
+	return e.result, nil
+}
+
+// query sends a query with the DO and CD bits set, so a validating resolver hands back bogus data for inspection
+func (e *dnssecValidation) query(name string, qtype uint16) ([]*signedRRset, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(name), qtype)
+	msg.SetEdns0(4096, true)
+	msg.CheckingDisabled = true
+
+	resp, err := ExchangeContext(e.ctx, e.validator.Client, msg, e.validator.Server)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s %s: %w", dns.Fqdn(name), dns.TypeToString[qtype], err)
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		return nil, fmt.Errorf("query for %s %s returned %s", dns.Fqdn(name), dns.TypeToString[qtype], dns.RcodeToString[resp.Rcode])
+	}
+	return groupRRsets(resp.Answer), nil
+}
+
+// groupRRsets splits an answer into RRsets and attaches the signatures covering each of them
+func groupRRsets(answer []dns.RR) []*signedRRset {
+	var rrsets []*signedRRset
+	find := func(name string, rrtype uint16) *signedRRset {
+		for _, rrset := range rrsets {
+			if rrset.rrtype == rrtype && strings.EqualFold(rrset.name, name) {
+				return rrset
+			}
+		}
+		rrset := &signedRRset{name: dns.CanonicalName(name), rrtype: rrtype}
+		rrsets = append(rrsets, rrset)
+		return rrset
+	}
+
+	for _, rr := range answer {
+		if sig, ok := rr.(*dns.RRSIG); ok {
+			rrset := find(sig.Header().Name, sig.TypeCovered)
+			rrset.sigs = append(rrset.sigs, sig)
+			continue
+		}
+		rrset := find(rr.Header().Name, rr.Header().Rrtype)
+		rrset.rrs = append(rrset.rrs, rr)
+	}
+
+	// Drop signatures without the RRset they cover
+	valid := rrsets[:0]
+	for _, rrset := range rrsets {
+		if len(rrset.rrs) > 0 {
+			valid = append(valid, rrset)
+		}
+	}
+	return valid
+}
+
+// findRRset returns the RRset of a type owned by name from an answer
+func findRRset(rrsets []*signedRRset, name string, rrtype uint16) *signedRRset {
+	for _, rrset := range rrsets {
+		if rrset.rrtype == rrtype && strings.EqualFold(rrset.name, name) {
+			return rrset
+		}
+	}
+	return nil
+}
+
+// zoneKeys returns the DNSKEYs of a zone once its DNSKEY RRset is validated against a trust anchor or the DS
+// records in the parent zone, which are validated first
+func (e *dnssecValidation) zoneKeys(zone string) ([]*dns.DNSKEY, error) {
+	zone = dns.CanonicalName(zone)
+	if keys, ok := e.keys[zone]; ok {
+		return keys, nil
+	}
+
+	rrsets, err := e.query(zone, dns.TypeDNSKEY)
+	if err != nil {
+		return nil, err
+	}
+	keySet := findRRset(rrsets, zone, dns.TypeDNSKEY)
+	if keySet == nil {
+		return nil, fmt.Errorf("no DNSKEY records found for %s", zone)
+	}
+	var keys []*dns.DNSKEY
+	for _, rr := range keySet.rrs {
+		keys = append(keys, rr.(*dns.DNSKEY))
+	}
+
+	var trusted []*dns.DNSKEY
+	if anchors := e.validator.anchorsFor(zone); len(anchors) > 0 {
+		trusted = matchKeys(keys, anchors)
+		if len(trusted) == 0 {
+			return nil, fmt.Errorf("no DNSKEY of %s matches the trust anchor", zone)
+		}
+	} else {
+		ds, err := e.delegation(zone)
+		if err != nil {
+			return nil, err
+		}
+		trusted = matchKeys(keys, ds)
+		if len(trusted) == 0 {
+			return nil, fmt.Errorf("no DNSKEY of %s matches its DS records, the chain of trust is broken", zone)
+		}
+	}
+
+	if err := e.verify(keySet, trusted); err != nil {
+		return nil, err
+	}
+	e.result.Records = append(e.result.Records, keySet.rrs...)
+	e.result.Chain = append(e.result.Chain, zone)
+	e.keys[zone] = keys
+	return keys, nil
+}
+
+// delegation returns the DS records of a zone after validating them with the keys of the parent zone
+func (e *dnssecValidation) delegation(zone string) ([]dns.RR, error) {
+	if zone == "." {
+		return nil, fmt.Errorf("no trust anchor for the root zone")
+	}
+
+	rrsets, err := e.query(zone, dns.TypeDS)
+	if err != nil {
+		return nil, err
+	}
+	dsSet := findRRset(rrsets, zone, dns.TypeDS)
+	if dsSet == nil {
+		return nil, fmt.Errorf("no DS records for %s in its parent zone, the chain of trust is broken", zone)
+	}
+	if len(dsSet.sigs) == 0 {
+		return nil, fmt.Errorf("%s is unsigned", dsSet)
+	}
+
+	parent := dns.CanonicalName(dsSet.sigs[0].SignerName)
+	if parent == zone || !dns.IsSubDomain(parent, zone) {
+		return nil, fmt.Errorf("%s is signed by %s, which isn't a parent zone", dsSet, parent)
+	}
+	parentKeys, err := e.zoneKeys(parent)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.verify(dsSet, parentKeys); err != nil {
+		return nil, err
+	}
+	e.result.Records = append(e.result.Records, dsSet.rrs...)
+	return dsSet.rrs, nil
+}
+
+// verify checks that one of the signatures of the RRset was made by one of the keys and is currently valid
+func (e *dnssecValidation) verify(rrset *signedRRset, keys []*dns.DNSKEY) error {
+	lastErr := fmt.Errorf("no signature made by a trusted key")
+	for _, sig := range rrset.sigs {
+		for _, key := range keys {
+			if key.KeyTag() != sig.KeyTag || key.Algorithm != sig.Algorithm {
+				continue
+			}
+			if err := sig.Verify(key, rrset.rrs); err != nil {
+				lastErr = err
+				continue
+			}
+			if !sig.ValidityPeriod(e.now) {
+				lastErr = fmt.Errorf("signature by key %d is only valid from %s to %s",
+					sig.KeyTag, dns.TimeToString(sig.Inception), dns.TimeToString(sig.Expiration))
+				continue
+			}
+			e.noteSignature(rrset, sig)
+			return nil
+		}
+	}
+	return fmt.Errorf("%s failed validation: %w", rrset, lastErr)
+}
+
+// noteSignature records a signature used for the validation and warns when it expires soon
+func (e *dnssecValidation) noteSignature(rrset *signedRRset, sig *dns.RRSIG) {
+	e.result.Records = append(e.result.Records, sig)
+
+	expires := time.Unix(int64(sig.Expiration), 0).UTC()
+	if e.result.Expires.IsZero() || expires.Before(e.result.Expires) {
+		e.result.Expires = expires
+	}
+	if remaining := expires.Sub(e.now); remaining < e.validator.ExpiryWarning {
+		e.result.Warnings = append(e.result.Warnings, fmt.Sprintf("signature over %s by key %d expires in %s (%s)",
+			rrset, sig.KeyTag, remaining.Round(time.Minute), expires.Format(time.RFC3339)))
+	}
+}
+
+// anchorsFor returns the trust anchors owned by the zone
+func (v *DNSSECValidator) anchorsFor(zone string) []dns.RR {
+	var anchors []dns.RR
+	for _, anchor := range v.TrustAnchors {
+		if strings.EqualFold(dns.CanonicalName(anchor.Header().Name), zone) {
+			anchors = append(anchors, anchor)
+		}
+	}
+	return anchors
+}
+
+// matchKeys returns the keys that match one of the DS or DNSKEY records
+func matchKeys(keys []*dns.DNSKEY, anchors []dns.RR) []*dns.DNSKEY {
+	var matched []*dns.DNSKEY
+	for _, key := range keys {
+		for _, anchor := range anchors {
+			if keyMatches(key, anchor) {
+				matched = append(matched, key)
+				break
+			}
+		}
+	}
+	return matched
+}
+
+func keyMatches(key *dns.DNSKEY, anchor dns.RR) bool {
+	switch a := anchor.(type) {
+	case *dns.DS:
+		if key.KeyTag() != a.KeyTag || key.Algorithm != a.Algorithm {
+			return false
+		}
+		ds := key.ToDS(a.DigestType)
+		return ds != nil && strings.EqualFold(ds.Digest, a.Digest)
+	case *dns.DNSKEY:
+		return key.Algorithm == a.Algorithm && key.Flags == a.Flags && key.PublicKey == a.PublicKey
+	default:
+		return false
+	}
+}
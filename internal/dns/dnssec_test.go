@@ -0,0 +1,242 @@
+package dns
+
+import (
+	"context"
+	"crypto"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+type testZone struct {
+	name string
+	key  *dns.DNSKEY
+	priv crypto.Signer
+}
+
+func newTestZone(t *testing.T, name string) *testZone {
+	t.Helper()
+	key := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: name, Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags:     257,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+	}
+	priv, err := key.Generate(256)
+	if err != nil {
+		t.Fatalf("failed to generate key for %s: %v", name, err)
+	}
+	return &testZone{name: name, key: key, priv: priv.(crypto.Signer)}
+}
+
+func (z *testZone) sign(t *testing.T, rrs []dns.RR, inception, expiration time.Time) []dns.RR {
+	t.Helper()
+	sig := &dns.RRSIG{
+		Hdr:        dns.RR_Header{Name: rrs[0].Header().Name, Rrtype: dns.TypeRRSIG, Class: dns.ClassINET, Ttl: rrs[0].Header().Ttl},
+		KeyTag:     z.key.KeyTag(),
+		SignerName: z.name,
+		Algorithm:  z.key.Algorithm,
+		Inception:  uint32(inception.Unix()),
+		Expiration: uint32(expiration.Unix()),
+	}
+	if err := sig.Sign(z.priv, rrs); err != nil {
+		t.Fatalf("failed to sign %s: %v", rrs[0].Header().Name, err)
+	}
+	return append(rrs, sig)
+}
+
+func (z *testZone) ds() *dns.DS {
+	return z.key.ToDS(dns.SHA256)
+}
+
+// testHierarchy is a signed ., com. and example.com. with knobs to break the chain of trust
+type testHierarchy struct {
+	root, com, example *testZone
+	now                time.Time
+	answerExpiration   time.Time
+	wrongDS            bool
+	missingDS          bool
+	unsignedAnswer     bool
+}
+
+func newTestHierarchy(t *testing.T, now time.Time) *testHierarchy {
+	return &testHierarchy{
+		root:             newTestZone(t, "."),
+		com:              newTestZone(t, "com."),
+		example:          newTestZone(t, "example.com."),
+		now:              now,
+		answerExpiration: now.Add(30 * 24 * time.Hour),
+	}
+}
+
+func (h *testHierarchy) records(t *testing.T) []dns.RR {
+	inception, expiration := h.now.Add(-time.Hour), h.now.Add(30*24*time.Hour)
+	var records []dns.RR
+	for _, zone := range []*testZone{h.root, h.com, h.example} {
+		records = append(records, zone.sign(t, []dns.RR{zone.key}, inception, expiration)...)
+	}
+
+	comDS := h.com.ds()
+	if h.wrongDS {
+		comDS = newTestZone(t, "com.").ds()
+	}
+	records = append(records, h.root.sign(t, []dns.RR{comDS}, inception, expiration)...)
+	if !h.missingDS {
+		records = append(records, h.com.sign(t, []dns.RR{h.example.ds()}, inception, expiration)...)
+	}
+
+	answer := []dns.RR{&dns.A{
+		Hdr: dns.RR_Header{Name: "example.com.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 300},
+		A:   net.ParseIP("192.0.2.1"),
+	}}
+	if !h.unsignedAnswer {
+		answer = h.example.sign(t, answer, h.now.Add(-2*time.Hour), h.answerExpiration)
+	}
+	return append(records, answer...)
+}
+
+// zoneMockClient answers with the records owned by the question name of the question type and their signatures
+func zoneMockClient(records []dns.RR) *MockIDNSClient {
+	return &MockIDNSClient{
+		MockExchange: func(msg *dns.Msg, server string) (*dns.Msg, time.Duration, error) {
+			q := msg.Question[0]
+			resp := new(dns.Msg)
+			resp.SetReply(msg)
+			for _, rr := range records {
+				if !strings.EqualFold(rr.Header().Name, q.Name) {
+					continue
+				}
+				if sig, ok := rr.(*dns.RRSIG); (ok && sig.TypeCovered == q.Qtype) || rr.Header().Rrtype == q.Qtype {
+					resp.Answer = append(resp.Answer, rr)
+				}
+			}
+			return resp, 0, nil
+		},
+	}
+}
+
+func TestDNSSECValidate(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		setup         func(h *testHierarchy)
+		anchor        func(h *testHierarchy) string
+		qtype         uint16
+		expectedChain []string
+		warnings      int
+		expectError   bool
+	}{
+		{
+			name:          "Valid chain from the root",
+			expectedChain: []string{".", "com.", "example.com."},
+		},
+		{
+			name:          "Trust anchor below the root",
+			anchor:        func(h *testHierarchy) string { return h.example.key.String() },
+			expectedChain: []string{"example.com."},
+		},
+		{
+			name:          "Signature expiring soon",
+			setup:         func(h *testHierarchy) { h.answerExpiration = now.Add(48 * time.Hour) },
+			expectedChain: []string{".", "com.", "example.com."},
+			warnings:      1,
+		},
+		{
+			name:        "Expired signature",
+			setup:       func(h *testHierarchy) { h.answerExpiration = now.Add(-time.Minute) },
+			expectError: true,
+		},
+		{
+			name:        "DS doesn't match the child's key",
+			setup:       func(h *testHierarchy) { h.wrongDS = true },
+			expectError: true,
+		},
+		{
+			name:        "Missing DS",
+			setup:       func(h *testHierarchy) { h.missingDS = true },
+			expectError: true,
+		},
+		{
+			name:        "Unsigned answer",
+			setup:       func(h *testHierarchy) { h.unsignedAnswer = true },
+			expectError: true,
+		},
+		{
+			name:        "Trust anchor doesn't match",
+			anchor:      func(h *testHierarchy) string { return newTestZone(t, ".").ds().String() },
+			expectError: true,
+		},
+		{
+			name:        "No records",
+			qtype:       dns.TypeAAAA,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHierarchy(t, now)
+			if tt.setup != nil {
+				tt.setup(h)
+			}
+			anchor := h.root.ds().String()
+			if tt.anchor != nil {
+				anchor = tt.anchor(h)
+			}
+			qtype := tt.qtype
+			if qtype == 0 {
+				qtype = dns.TypeA
+			}
+
+			validator, err := NewDNSSECValidator(zoneMockClient(h.records(t)), "8.8.8.8", []string{anchor}, 0)
+			if err != nil {
+				t.Fatalf("NewDNSSECValidator() error = %v", err)
+			}
+			validator.Now = func() time.Time { return now }
+
+			result, err := validator.Validate(context.Background(), "example.com", qtype)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("expected an error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("didn't expect an error but got %v", err)
+			}
+			if !reflect.DeepEqual(result.Chain, tt.expectedChain) {
+				t.Errorf("Chain = %v, want %v", result.Chain, tt.expectedChain)
+			}
+			if len(result.Warnings) != tt.warnings {
+				t.Errorf("Warnings = %v, want %d", result.Warnings, tt.warnings)
+			}
+		})
+	}
+}
+
+func TestParseTrustAnchor(t *testing.T) {
+	tests := []struct {
+		name        string
+		anchor      string
+		expectError bool
+	}{
+		{name: "Root DS", anchor: RootTrustAnchor},
+		{name: "DNSKEY", anchor: "example.com. IN DNSKEY 257 3 13 mdsswUyr3DPW132mOi8V9xESWE8jTo0dxCjjnopKl+GqJxpVXckHAeF+KkxLbxILfDLUT0rAK9iUzy1L53eKGQ=="},
+		{name: "Not a key record", anchor: "example.com. IN A 192.0.2.1", expectError: true},
+		{name: "Malformed", anchor: "not a record", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseTrustAnchor(tt.anchor)
+			if (err != nil) != tt.expectError {
+				t.Errorf("ParseTrustAnchor() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
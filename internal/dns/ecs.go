@@ -0,0 +1,64 @@
+package dns
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// ParseClientSubnet parses an EDNS client subnet such as 203.0.113.0/24, a bare IP is a /32 or /128
+func ParseClientSubnet(subnet string) (netip.Prefix, error) {
+	if !strings.Contains(subnet, "/") {
+		addr, err := netip.ParseAddr(subnet)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid client subnet '%s': %w", subnet, err)
+		}
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+
+	prefix, err := netip.ParsePrefix(subnet)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid client subnet '%s': %w", subnet, err)
+	}
+	return prefix.Masked(), nil
+}
+
+// WithClientSubnet attaches an EDNS0 client subnet option to the query so the server answers as it would for a
+// client in that subnet
+func WithClientSubnet(prefix netip.Prefix) QueryOption {
+	return func(msg *dns.Msg) {
+		opt := msg.IsEdns0()
+		if opt == nil {
+			msg.SetEdns0(4096, false)
+			opt = msg.IsEdns0()
+		}
+
+		family := uint16(1)
+		if prefix.Addr().Is6() {
+			family = 2
+		}
+		opt.Option = append(opt.Option, &dns.EDNS0_SUBNET{
+			Code:          dns.EDNS0SUBNET,
+			Family:        family,
+			SourceNetmask: uint8(prefix.Bits()),
+			Address:       net.IP(prefix.Masked().Addr().AsSlice()),
+		})
+	}
+}
+
+// clientSubnetScope returns the scope prefix length of the client subnet option in a response
+func clientSubnetScope(msg *dns.Msg) (uint8, bool) {
+	opt := msg.IsEdns0()
+	if opt == nil {
+		return 0, false
+	}
+	for _, option := range opt.Option {
+		if subnet, ok := option.(*dns.EDNS0_SUBNET); ok {
+			return subnet.SourceScope, true
+		}
+	}
+	return 0, false
+}
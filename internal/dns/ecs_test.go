@@ -0,0 +1,116 @@
+package dns
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestParseClientSubnet(t *testing.T) {
+	tests := []struct {
+		name        string
+		subnet      string
+		expected    netip.Prefix
+		expectError bool
+	}{
+		{name: "IPv4 prefix", subnet: "203.0.113.0/24", expected: netip.MustParsePrefix("203.0.113.0/24")},
+		{name: "Prefix is masked", subnet: "203.0.113.77/24", expected: netip.MustParsePrefix("203.0.113.0/24")},
+		{name: "IPv6 prefix", subnet: "2001:db8::/56", expected: netip.MustParsePrefix("2001:db8::/56")},
+		{name: "Bare IPv4", subnet: "198.51.100.7", expected: netip.MustParsePrefix("198.51.100.7/32")},
+		{name: "Bare IPv6", subnet: "2001:db8::1", expected: netip.MustParsePrefix("2001:db8::1/128")},
+		{name: "Invalid prefix", subnet: "203.0.113.0/33", expectError: true},
+		{name: "Invalid address", subnet: "europe", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefix, err := ParseClientSubnet(tt.subnet)
+			if (err != nil) != tt.expectError {
+				t.Fatalf("ParseClientSubnet() error = %v, expectError %v", err, tt.expectError)
+			}
+			if !tt.expectError && prefix != tt.expected {
+				t.Errorf("ParseClientSubnet() = %v, want %v", prefix, tt.expected)
+			}
+		})
+	}
+}
+
+func TestQueryDNSClientSubnet(t *testing.T) {
+	// The mock answers like a GeoDNS server, clients in 203.0.113.0/24 get a regional answer with a /20 scope
+	client := &MockIDNSClient{
+		MockExchange: func(msg *dns.Msg, server string) (*dns.Msg, time.Duration, error) {
+			resp := new(dns.Msg)
+			resp.SetReply(msg)
+			if msg.Question[0].Qtype != dns.TypeA {
+				return resp, 0, nil
+			}
+
+			answer := "192.0.2.1"
+			if opt := msg.IsEdns0(); opt != nil {
+				for _, option := range opt.Option {
+					subnet, ok := option.(*dns.EDNS0_SUBNET)
+					if !ok {
+						continue
+					}
+					if subnet.Family == 1 && subnet.SourceNetmask == 24 && subnet.Address.Equal(net.ParseIP("203.0.113.0")) {
+						answer = "198.51.100.1"
+					}
+					scoped := *subnet
+					scoped.SourceScope = 20
+					resp.SetEdns0(4096, false)
+					resp.IsEdns0().Option = append(resp.IsEdns0().Option, &scoped)
+				}
+			}
+			resp.Answer = append(resp.Answer, &dns.A{
+				Hdr: dns.RR_Header{Name: msg.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET},
+				A:   net.ParseIP(answer),
+			})
+			return resp, 0, nil
+		},
+	}
+
+	tests := []struct {
+		name        string
+		opts        []QueryOption
+		expectedA   string
+		expectScope bool
+	}{
+		{name: "Without a client subnet", expectedA: "192.0.2.1"},
+		{
+			name:        "Client in the region",
+			opts:        []QueryOption{WithClientSubnet(netip.MustParsePrefix("203.0.113.0/24"))},
+			expectedA:   "198.51.100.1",
+			expectScope: true,
+		},
+		{
+			name:        "Client outside the region",
+			opts:        []QueryOption{WithClientSubnet(netip.MustParsePrefix("2001:db8::/56"))},
+			expectedA:   "192.0.2.1",
+			expectScope: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := QueryDNSContext(context.Background(), "example.com", "8.8.8.8", client, tt.opts...)
+			if err != nil {
+				t.Fatalf("QueryDNSContext() error = %v", err)
+			}
+			if len(records.ARecords) != 1 || records.ARecords[0] != tt.expectedA {
+				t.Errorf("ARecords = %v, want [%v]", records.ARecords, tt.expectedA)
+			}
+
+			scope, ok := records.ClientSubnetScopes[dns.TypeA]
+			if ok != tt.expectScope {
+				t.Fatalf("scope returned = %v, want %v", ok, tt.expectScope)
+			}
+			if ok && scope != 20 {
+				t.Errorf("scope = /%d, want /20", scope)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package dns
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// ResponseFlags are the header flags of a response
+type ResponseFlags struct {
+	Response           bool
+	Authoritative      bool
+	Truncated          bool
+	RecursionDesired   bool
+	RecursionAvailable bool
+	AuthenticatedData  bool
+	CheckingDisabled   bool
+}
+
+func newResponseFlags(msg *dns.Msg) ResponseFlags {
+	return ResponseFlags{
+		Response:           msg.Response,
+		Authoritative:      msg.Authoritative,
+		Truncated:          msg.Truncated,
+		RecursionDesired:   msg.RecursionDesired,
+		RecursionAvailable: msg.RecursionAvailable,
+		AuthenticatedData:  msg.AuthenticatedData,
+		CheckingDisabled:   msg.CheckingDisabled,
+	}
+}
+
+// String lists the set flags the way dig does, e.g. "qr rd ra"
+func (f ResponseFlags) String() string {
+	var set []string
+	for _, flag := range []struct {
+		name string
+		set  bool
+	}{
+		{"qr", f.Response},
+		{"aa", f.Authoritative},
+		{"tc", f.Truncated},
+		{"rd", f.RecursionDesired},
+		{"ra", f.RecursionAvailable},
+		{"ad", f.AuthenticatedData},
+		{"cd", f.CheckingDisabled},
+	} {
+		if flag.set {
+			set = append(set, flag.name)
+		}
+	}
+	if len(set) == 0 {
+		return "none"
+	}
+	return strings.Join(set, " ")
+}
+
+// Flag reports whether an assertable flag is set, the supported flags are aa, ad, ra, tc and cd
+func (f ResponseFlags) Flag(name string) (bool, error) {
+	switch strings.ToLower(name) {
+	case "aa":
+		return f.Authoritative, nil
+	case "ad":
+		return f.AuthenticatedData, nil
+	case "ra":
+		return f.RecursionAvailable, nil
+	case "tc":
+		return f.Truncated, nil
+	case "cd":
+		return f.CheckingDisabled, nil
+	default:
+		return false, fmt.Errorf("unsupported flag '%s', supported flags: aa, ad, ra, tc, cd", name)
+	}
+}
+
+// WithAuthenticatedData sets the AD bit on the query as dig does, so validating resolvers report whether the answer
+// was authenticated
+func WithAuthenticatedData() QueryOption {
+	return func(msg *dns.Msg) {
+		msg.AuthenticatedData = true
+	}
+}
+
+// ValidateFlag checks the flag can be asserted
+func ValidateFlag(name string) error {
+	_, err := ResponseFlags{}.Flag(name)
+	return err
+}
+
+// CheckFlags compares the flags of a response to the expected set (true) or unset (false) flags
+func CheckFlags(expected map[string]bool, flags ResponseFlags) error {
+	names := make([]string, 0, len(expected))
+	for name := range expected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var mismatched []string
+	for _, name := range names {
+		set, err := flags.Flag(name)
+		if err != nil {
+			return err
+		}
+		if set != expected[name] {
+			state := "set"
+			if !expected[name] {
+				state = "unset"
+			}
+			mismatched = append(mismatched, fmt.Sprintf("%s expected %s", strings.ToLower(name), state))
+		}
+	}
+	if len(mismatched) > 0 {
+		return fmt.Errorf("header flags mismatch: %s (response flags: %s)", strings.Join(mismatched, ", "), flags)
+	}
+	return nil
+}
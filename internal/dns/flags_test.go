@@ -0,0 +1,94 @@
+package dns
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestResponseFlagsString(t *testing.T) {
+	tests := []struct {
+		name     string
+		flags    ResponseFlags
+		expected string
+	}{
+		{name: "No flags", flags: ResponseFlags{}, expected: "none"},
+		{name: "Recursive answer", flags: ResponseFlags{Response: true, RecursionDesired: true, RecursionAvailable: true, AuthenticatedData: true}, expected: "qr rd ra ad"},
+		{name: "Authoritative answer", flags: ResponseFlags{Response: true, Authoritative: true}, expected: "qr aa"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.flags.String(); got != tt.expected {
+				t.Errorf("String() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCheckFlags(t *testing.T) {
+	flags := ResponseFlags{Response: true, Authoritative: true, RecursionDesired: true}
+
+	tests := []struct {
+		name          string
+		expected      map[string]bool
+		expectedError string
+	}{
+		{name: "Set flag", expected: map[string]bool{"aa": true}},
+		{name: "Unset flags", expected: map[string]bool{"tc": false, "ra": false, "AD": false}},
+		{
+			name:          "Mismatched flags",
+			expected:      map[string]bool{"aa": false, "ad": true},
+			expectedError: "header flags mismatch: aa expected unset, ad expected set (response flags: qr aa rd)",
+		},
+		{
+			name:          "Unsupported flag",
+			expected:      map[string]bool{"qr": true},
+			expectedError: "unsupported flag 'qr', supported flags: aa, ad, ra, tc, cd",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckFlags(tt.expected, flags)
+			if tt.expectedError == "" {
+				if err != nil {
+					t.Errorf("CheckFlags() unexpected error = %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.expectedError {
+				t.Errorf("CheckFlags() error = %v, want %v", err, tt.expectedError)
+			}
+		})
+	}
+}
+
+func TestWithAuthenticatedData(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []QueryOption
+		expected bool
+	}{
+		{name: "AD bit unset by default"},
+		{name: "AD bit set by the option", opts: []QueryOption{WithAuthenticatedData()}, expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &MockIDNSClient{
+				MockExchange: func(msg *dns.Msg, server string) (*dns.Msg, time.Duration, error) {
+					if msg.AuthenticatedData != tt.expected {
+						t.Errorf("query AD bit = %v, expected %v", msg.AuthenticatedData, tt.expected)
+					}
+					return new(dns.Msg).SetReply(msg), 0, nil
+				},
+			}
+			if _, err := QueryDNSContext(context.Background(), "example.com", "8.8.8.8", client, tt.opts...); err != nil {
+				t.Errorf("QueryDNSContext() unexpected error = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package dns
+
+import (
+	"fmt"
+	"net/netip"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// valueMatcher matches actual DNS record values against a single expected value
+type valueMatcher interface {
+	Match(value string) bool
+	// IsPattern reports whether the expected value is a pattern rather than a literal value
+	IsPattern() bool
+}
+
+type literalMatcher string
+
+func (m literalMatcher) Match(value string) bool { return string(m) == value }
+func (m literalMatcher) IsPattern() bool         { return false }
+
+type cidrMatcher netip.Prefix
+
+func (m cidrMatcher) Match(value string) bool {
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return false
+	}
+	return netip.Prefix(m).Contains(addr.Unmap())
+}
+func (m cidrMatcher) IsPattern() bool { return true }
+
+type regexMatcher struct{ re *regexp.Regexp }
+
+func (m regexMatcher) Match(value string) bool { return m.re.MatchString(value) }
+func (m regexMatcher) IsPattern() bool         { return true }
+
+type globMatcher string
+
+func (m globMatcher) Match(value string) bool {
+	matched, _ := path.Match(string(m), value)
+	return matched
+}
+func (m globMatcher) IsPattern() bool { return true }
+
+// newValueMatcher parses an expected value, values prefixed with cidr:, re: or glob: are patterns,
+// anything else must match exactly
+func newValueMatcher(expected string) (valueMatcher, error) {
+	prefix, pattern, found := strings.Cut(expected, ":")
+	if !found {
+		return literalMatcher(expected), nil
+	}
+
+	switch prefix {
+	case "cidr":
+		prefix, err := netip.ParsePrefix(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR in expected value '%s': %v", expected, err)
+		}
+		return cidrMatcher(prefix.Masked()), nil
+	case "re":
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex in expected value '%s': %v", expected, err)
+		}
+		return regexMatcher{re: re}, nil
+	case "glob":
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob in expected value '%s': %v", expected, err)
+		}
+		return globMatcher(pattern), nil
+	default:
+		return literalMatcher(expected), nil
+	}
+}
+
+// ValidateExpectedValue checks that an expected value is a literal or a well formed cidr:, re: or glob: pattern
+func ValidateExpectedValue(expected string) error {
+	_, err := newValueMatcher(expected)
+	return err
+}
+
+// IsPattern reports whether an expected value is a cidr:, re: or glob: pattern rather than a literal
+func IsPattern(expected string) bool {
+	m, err := newValueMatcher(expected)
+	return err == nil && m.IsPattern()
+}
@@ -0,0 +1,146 @@
+package dns
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewValueMatcher(t *testing.T) {
+	tests := []struct {
+		name        string
+		expected    string
+		matches     []string
+		mismatches  []string
+		wantPattern bool
+		wantErr     bool
+	}{
+		{
+			name:       "Literal value",
+			expected:   "10.0.0.1",
+			matches:    []string{"10.0.0.1"},
+			mismatches: []string{"10.0.0.10"},
+		},
+		{
+			name:       "IPv6 literal is not a pattern",
+			expected:   "2001:db8::1",
+			matches:    []string{"2001:db8::1"},
+			mismatches: []string{"2001:db8::2"},
+		},
+		{
+			name:        "CIDR pattern",
+			expected:    "cidr:10.0.0.0/16",
+			matches:     []string{"10.0.0.1", "10.0.255.254"},
+			mismatches:  []string{"10.1.0.1", "not-an-ip", "2001:db8::1"},
+			wantPattern: true,
+		},
+		{
+			name:        "IPv6 CIDR pattern",
+			expected:    "cidr:2001:db8::/32",
+			matches:     []string{"2001:db8::1"},
+			mismatches:  []string{"2001:db9::1", "10.0.0.1"},
+			wantPattern: true,
+		},
+		{
+			name:        "Regex pattern",
+			expected:    `re:^s-[0-9a-f]+\.server\.transfer\..*`,
+			matches:     []string{"s-12345678900f0000a.server.transfer.us-east-1.amazonaws.com."},
+			mismatches:  []string{"x-1.server.transfer.us-east-1.amazonaws.com."},
+			wantPattern: true,
+		},
+		{
+			name:        "Glob pattern",
+			expected:    "glob:*.amazonaws.com.",
+			matches:     []string{"s-1.server.transfer.us-east-1.amazonaws.com."},
+			mismatches:  []string{"amazonaws.com.", "example.com."},
+			wantPattern: true,
+		},
+		{
+			name:     "Invalid CIDR",
+			expected: "cidr:10.0.0.0/33",
+			wantErr:  true,
+		},
+		{
+			name:     "Invalid regex",
+			expected: "re:[",
+			wantErr:  true,
+		},
+		{
+			name:     "Invalid glob",
+			expected: "glob:[",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matcher, err := newValueMatcher(tt.expected)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newValueMatcher() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if matcher.IsPattern() != tt.wantPattern {
+				t.Errorf("IsPattern() = %v, want %v", matcher.IsPattern(), tt.wantPattern)
+			}
+			for _, value := range tt.matches {
+				if !matcher.Match(value) {
+					t.Errorf("Match(%q) = false, want true", value)
+				}
+			}
+			for _, value := range tt.mismatches {
+				if matcher.Match(value) {
+					t.Errorf("Match(%q) = true, want false", value)
+				}
+			}
+		})
+	}
+}
+
+func TestDiffRecordsPatterns(t *testing.T) {
+	got := DiffRecords(
+		[]string{"cidr:10.0.0.0/16", "10.2.0.1", "glob:*.example.com."},
+		[]string{"10.0.1.5", "10.0.2.7", "10.1.0.1"},
+		CompareOptions{},
+	)
+
+	if want := []string{"10.0.1.5", "10.0.2.7"}; !reflect.DeepEqual(got.Matched, want) {
+		t.Errorf("DiffRecords() matched = %v, want %v", got.Matched, want)
+	}
+	if want := []string{"10.1.0.1"}; !reflect.DeepEqual(got.Unexpected, want) {
+		t.Errorf("DiffRecords() unexpected = %v, want %v", got.Unexpected, want)
+	}
+	if want := []string{"10.2.0.1", "glob:*.example.com."}; !reflect.DeepEqual(got.Missing, want) {
+		t.Errorf("DiffRecords() missing = %v, want %v", got.Missing, want)
+	}
+	if want := map[string]string{"10.0.1.5": "cidr:10.0.0.0/16", "10.0.2.7": "cidr:10.0.0.0/16"}; !reflect.DeepEqual(got.MatchedBy, want) {
+		t.Errorf("DiffRecords() matchedBy = %v, want %v", got.MatchedBy, want)
+	}
+	if got.Passed {
+		t.Errorf("DiffRecords() passed = true, want false")
+	}
+
+	invalid := DiffRecords([]string{"re:["}, []string{"10.0.0.1"}, CompareOptions{Match: "contains"})
+	if invalid.Passed {
+		t.Errorf("DiffRecords() with an invalid pattern passed = true, want false")
+	}
+}
+
+func TestIsPattern(t *testing.T) {
+	tests := map[string]bool{
+		"cidr:10.0.0.0/16":   true,
+		"re:^mail[0-9]+\\.":  true,
+		"glob:*.example.com": true,
+		"10.0.0.1":           false,
+		"v=DKIM1; k=rsa":     false,
+		"2001:db8::1":        false,
+		"cidr:10.0.0.0/33":   false,
+	}
+
+	for value, want := range tests {
+		if got := IsPattern(value); got != want {
+			t.Errorf("IsPattern(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package dns
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// String formats the MX record the same way as an expected value with a preference, e.g. "10 mail.example.com."
+func (mx MXRecord) String() string {
+	return fmt.Sprintf("%d %s", mx.Pref, mx.Host)
+}
+
+// ParseMXValue splits an expected MX value into its host and optional preference, "10 mail.example.com." or "mail.example.com."
+func ParseMXValue(value string) (host string, pref uint16, hasPref bool) {
+	fields := strings.Fields(value)
+	if len(fields) == 2 {
+		if p, err := strconv.ParseUint(fields[0], 10, 16); err == nil {
+			return fields[1], uint16(p), true
+		}
+	}
+	return strings.TrimSpace(value), 0, false
+}
+
+// HasMXPreference reports whether any of the expected MX values includes a preference
+func HasMXPreference(values []string) bool {
+	for _, value := range values {
+		if _, _, hasPref := ParseMXValue(value); hasPref {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckMXOrder verifies the preferences of the MX records rank the hosts in the order they are listed in
+// the expected values, the first being the most preferred. Hosts that aren't in the records are skipped
+func CheckMXOrder(expected []string, records []MXRecord, strict bool) error {
+	normalize := func(host string) string { return host }
+	if !strict {
+		normalize = normalizeName
+	}
+
+	prefs := make(map[string]uint16, len(records))
+	for _, mx := range records {
+		prefs[normalize(mx.Host)] = mx.Pref
+	}
+
+	var prevHost string
+	var prevPref uint16
+	for _, value := range expected {
+		host, _, _ := ParseMXValue(value)
+		pref, found := prefs[normalize(host)]
+		if !found {
+			continue
+		}
+		if prevHost != "" && pref < prevPref {
+			return fmt.Errorf("%s (preference %d) is preferred over %s (preference %d)", host, pref, prevHost, prevPref)
+		}
+		prevHost, prevPref = host, pref
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package dns
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMXValue(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		wantHost    string
+		wantPref    uint16
+		wantHasPref bool
+	}{
+		{
+			name:        "Host with preference",
+			value:       "10 mail.example.com.",
+			wantHost:    "mail.example.com.",
+			wantPref:    10,
+			wantHasPref: true,
+		},
+		{
+			name:     "Host only",
+			value:    "mail.example.com.",
+			wantHost: "mail.example.com.",
+		},
+		{
+			name:     "Preference out of range",
+			value:    "70000 mail.example.com.",
+			wantHost: "70000 mail.example.com.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host, pref, hasPref := ParseMXValue(tt.value)
+			if host != tt.wantHost || pref != tt.wantPref || hasPref != tt.wantHasPref {
+				t.Errorf("ParseMXValue() = (%v, %v, %v), want (%v, %v, %v)", host, pref, hasPref, tt.wantHost, tt.wantPref, tt.wantHasPref)
+			}
+		})
+	}
+}
+
+func TestExtractComparableRecords(t *testing.T) {
+	records := &DNSRecords{
+		MXRecords: []MXRecord{
+			{Host: "mail1.example.com.", Pref: 10},
+			{Host: "mail2.example.com.", Pref: 20},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		expected []string
+		want     []string
+	}{
+		{
+			name:     "Expected hosts only",
+			expected: []string{"mail1.example.com."},
+			want:     []string{"mail1.example.com.", "mail2.example.com."},
+		},
+		{
+			name:     "Expected hosts with preferences",
+			expected: []string{"10 mail1.example.com."},
+			want:     []string{"10 mail1.example.com.", "20 mail2.example.com."},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExtractComparableRecords(records, "MX", tt.expected, false)
+			if err != nil {
+				t.Fatalf("ExtractComparableRecords() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExtractComparableRecords() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareMXPreferences(t *testing.T) {
+	actual := []string{"10 mail1.example.com.", "20 mail2.example.com."}
+
+	if err := CompareRecords([]string{"10 Mail1.example.com", "20 mail2.example.com."}, actual, CompareOptions{RecordType: "mx"}); err != nil {
+		t.Errorf("CompareRecords() with matching preferences error = %v", err)
+	}
+	if err := CompareRecords([]string{"20 mail1.example.com.", "10 mail2.example.com."}, actual, CompareOptions{RecordType: "mx"}); err == nil {
+		t.Errorf("CompareRecords() with swapped preferences expected an error")
+	}
+}
+
+func TestCheckMXOrder(t *testing.T) {
+	records := []MXRecord{
+		{Host: "mail1.example.com.", Pref: 10},
+		{Host: "mail2.example.com.", Pref: 20},
+		{Host: "mail3.example.com.", Pref: 20},
+	}
+
+	tests := []struct {
+		name     string
+		expected []string
+		strict   bool
+		wantErr  bool
+	}{
+		{
+			name:     "Listed in priority order",
+			expected: []string{"mail1.example.com.", "mail2.example.com.", "mail3.example.com."},
+		},
+		{
+			name:     "Equal preferences in either order",
+			expected: []string{"mail1.example.com.", "mail3.example.com.", "mail2.example.com."},
+		},
+		{
+			name:     "Backup listed first",
+			expected: []string{"mail2.example.com.", "mail1.example.com."},
+			wantErr:  true,
+		},
+		{
+			name:     "Values with preferences and mixed case",
+			expected: []string{"20 MAIL2.example.com", "10 mail1.example.com"},
+			wantErr:  true,
+		},
+		{
+			name:     "Strict mode skips hosts that don't match exactly",
+			expected: []string{"MAIL2.example.com.", "mail1.example.com."},
+			strict:   true,
+		},
+		{
+			name:     "Hosts missing from the records are skipped",
+			expected: []string{"mail1.example.com.", "mail9.example.com.", "mail2.example.com."},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CheckMXOrder(tt.expected, records, tt.strict); (err != nil) != tt.wantErr {
+				t.Errorf("CheckMXOrder() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package dns
+
+import (
+	"net/netip"
+	"strings"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/idna"
+)
+
+// NormalizeValue puts a record value in a canonical form for its record type so equivalent values compare
+// as equal: IPs are formatted canonically, names are folded to lower case punycode FQDNs
+func NormalizeValue(qtype uint16, value string) string {
+	value = strings.TrimSpace(value)
+
+	switch qtype {
+	case dns.TypeA, dns.TypeAAAA:
+		return normalizeIP(qtype, value)
+	case dns.TypeCNAME, dns.TypeNS:
+		return normalizeName(value)
+	case dns.TypeMX:
+		if host, pref, hasPref := ParseMXValue(value); hasPref {
+			return MXRecord{Host: normalizeName(host), Pref: pref}.String()
+		}
+		return normalizeName(value)
+	default:
+		return value
+	}
+}
+
+func normalizeIP(qtype uint16, value string) string {
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return value
+	}
+	if qtype == dns.TypeA {
+		addr = addr.Unmap()
+	}
+	return addr.String()
+}
+
+func normalizeName(value string) string {
+	name := strings.TrimSuffix(value, ".")
+	if name == "" {
+		return "."
+	}
+	if ascii, err := idna.Lookup.ToASCII(name); err == nil {
+		name = ascii
+	}
+	return dns.Fqdn(strings.ToLower(name))
+}
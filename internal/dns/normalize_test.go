@@ -0,0 +1,119 @@
+package dns
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestNormalizeValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		qtype uint16
+		value string
+		want  string
+	}{
+		{
+			name:  "IPv4 address",
+			qtype: dns.TypeA,
+			value: " 10.0.0.1 ",
+			want:  "10.0.0.1",
+		},
+		{
+			name:  "IPv4-mapped IPv6 address in an A record",
+			qtype: dns.TypeA,
+			value: "::ffff:10.0.0.1",
+			want:  "10.0.0.1",
+		},
+		{
+			name:  "Expanded IPv6 address",
+			qtype: dns.TypeAAAA,
+			value: "2001:0DB8:0000:0000:0000:0000:0000:0001",
+			want:  "2001:db8::1",
+		},
+		{
+			name:  "Invalid IP is left alone",
+			qtype: dns.TypeAAAA,
+			value: "not-an-ip",
+			want:  "not-an-ip",
+		},
+		{
+			name:  "Mixed case name without a trailing dot",
+			qtype: dns.TypeCNAME,
+			value: "Foo.Example.com",
+			want:  "foo.example.com.",
+		},
+		{
+			name:  "Name with a trailing dot",
+			qtype: dns.TypeNS,
+			value: "ns1.example.com.",
+			want:  "ns1.example.com.",
+		},
+		{
+			name:  "Internationalized name",
+			qtype: dns.TypeMX,
+			value: "mail.bücher.example",
+			want:  "mail.xn--bcher-kva.example.",
+		},
+		{
+			name:  "TXT values are untouched",
+			qtype: dns.TypeTXT,
+			value: "Some Text.",
+			want:  "Some Text.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeValue(tt.qtype, tt.value); got != tt.want {
+				t.Errorf("NormalizeValue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareRecordsNormalization(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected []string
+		actual   []string
+		opts     CompareOptions
+		wantErr  bool
+	}{
+		{
+			name:     "Names differing in case and trailing dot",
+			expected: []string{"Foo.example.com"},
+			actual:   []string{"foo.example.com."},
+			opts:     CompareOptions{RecordType: "cname"},
+			wantErr:  false,
+		},
+		{
+			name:     "IPv6 written differently",
+			expected: []string{"2001:db8:0:0::1"},
+			actual:   []string{"2001:db8::1"},
+			opts:     CompareOptions{RecordType: "aaaa"},
+			wantErr:  false,
+		},
+		{
+			name:     "Strict mode compares byte for byte",
+			expected: []string{"Foo.example.com"},
+			actual:   []string{"foo.example.com."},
+			opts:     CompareOptions{RecordType: "cname", Strict: true},
+			wantErr:  true,
+		},
+		{
+			name:     "Unknown record type is not normalized",
+			expected: []string{"Foo.example.com"},
+			actual:   []string{"foo.example.com."},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CompareRecords(tt.expected, tt.actual, tt.opts); (err != nil) != tt.wantErr {
+				t.Errorf("CompareRecords() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
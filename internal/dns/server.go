@@ -0,0 +1,121 @@
+package dns
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// serverUDPSize is the EDNS buffer size the server advertises
+const serverUDPSize = 1232
+
+// ServerOptions injects faults into the responses of a Server
+type ServerOptions struct {
+	// Latency delays every response
+	Latency time.Duration
+	// ServFailRate is the fraction of queries answered with SERVFAIL, between 0 and 1
+	ServFailRate float64
+	// Truncate sets TC on every UDP response and leaves out the records, TCP responses are left whole
+	Truncate bool
+}
+
+// Server is an authoritative DNS server answering from zones in memory over UDP and TCP, for rehearsing configs
+// locally and end-to-end tests
+type Server struct {
+	// Addr is the address the server listens on once started
+	Addr    string
+	zones   *ZoneClient
+	options ServerOptions
+	random  func() float64
+	servers []*dns.Server
+}
+
+// NewServer validates the options and returns a server for the zones
+func NewServer(zones *ZoneClient, options ServerOptions) (*Server, error) {
+	if options.Latency < 0 {
+		return nil, fmt.Errorf("latency can't be negative")
+	}
+	if options.ServFailRate < 0 || options.ServFailRate > 1 {
+		return nil, fmt.Errorf("SERVFAIL rate must be between 0 and 1, got %v", options.ServFailRate)
+	}
+	return &Server{zones: zones, options: options, random: rand.Float64}, nil
+}
+
+// Start listens on the address over UDP and TCP, a port of 0 picks a free port for both
+func (s *Server) Start(addr string) error {
+	pc, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	listener, err := net.Listen("tcp", pc.LocalAddr().String())
+	if err != nil {
+		pc.Close()
+		return err
+	}
+	s.Addr = pc.LocalAddr().String()
+
+	s.servers = []*dns.Server{
+		{PacketConn: pc, Handler: s},
+		{Listener: listener, Handler: s},
+	}
+	started := make(chan error, len(s.servers))
+	for _, server := range s.servers {
+		server.NotifyStartedFunc = func() { started <- nil }
+		go func() {
+			if err := server.ActivateAndServe(); err != nil {
+				started <- err
+			}
+		}()
+	}
+	for range s.servers {
+		if err := <-started; err != nil {
+			s.Shutdown()
+			return err
+		}
+	}
+	return nil
+}
+
+// Shutdown stops listening, queries in flight are still answered
+func (s *Server) Shutdown() error {
+	var errs []error
+	for _, server := range s.servers {
+		if err := server.Shutdown(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Server) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	if s.options.Latency > 0 {
+		time.Sleep(s.options.Latency)
+	}
+
+	var resp *dns.Msg
+	if s.options.ServFailRate > 0 && s.random() < s.options.ServFailRate {
+		resp = new(dns.Msg)
+		resp.SetRcode(r, dns.RcodeServerFailure)
+	} else {
+		resp = s.zones.Resolve(r)
+	}
+
+	size := dns.MinMsgSize
+	if opt := r.IsEdns0(); opt != nil {
+		size = int(min(opt.UDPSize(), serverUDPSize))
+		resp.SetEdns0(serverUDPSize, false)
+	}
+	if _, udp := w.RemoteAddr().(*net.UDPAddr); udp {
+		if s.options.Truncate {
+			resp.Truncated = true
+			resp.Answer, resp.Ns = nil, nil
+		} else {
+			resp.Truncate(size)
+		}
+	}
+	_ = w.WriteMsg(resp)
+}
@@ -0,0 +1,144 @@
+package dns
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestServerAddress(t *testing.T) {
+	tests := []struct {
+		server   string
+		expected string
+	}{
+		{server: "1.1.1.1", expected: "1.1.1.1:53"},
+		{server: "127.0.0.1:5353", expected: "127.0.0.1:5353"},
+		{server: "ns1.example.com", expected: "ns1.example.com:53"},
+		{server: "2606:4700::1111", expected: "[2606:4700::1111]:53"},
+		{server: "[2606:4700::1111]", expected: "[2606:4700::1111]:53"},
+		{server: "[::1]:5353", expected: "[::1]:5353"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.server, func(t *testing.T) {
+			if got := ServerAddress(tt.server); got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func startTestServer(t *testing.T, options ServerOptions) string {
+	t.Helper()
+	zone := testClientZone
+	for i := 0; i < 12; i++ {
+		zone += fmt.Sprintf("big IN TXT \"record %d padded out to make the answer larger than 512 bytes but under 1232\"\n", i)
+	}
+	snapshot, err := NewZoneSnapshot("example.com.", parseTestRecords(t, zone))
+	if err != nil {
+		t.Fatalf("failed to load the zone: %v", err)
+	}
+
+	server, err := NewServer(NewZoneClient(snapshot), options)
+	if err != nil {
+		t.Fatalf("failed to create the server: %v", err)
+	}
+	if err := server.Start("127.0.0.1:0"); err != nil {
+		t.Fatalf("failed to start the server: %v", err)
+	}
+	t.Cleanup(func() { _ = server.Shutdown() })
+	return server.Addr
+}
+
+func TestServer(t *testing.T) {
+	tests := []struct {
+		name        string
+		options     ServerOptions
+		net         string
+		qname       string
+		qtype       uint16
+		edns        bool
+		rcode       int
+		truncated   bool
+		answers     int
+		minDuration time.Duration
+	}{
+		{name: "UDP answer", qname: "mail.example.com.", qtype: dns.TypeA, answers: 1},
+		{name: "TCP answer", net: "tcp", qname: "mail.example.com.", qtype: dns.TypeA, answers: 1},
+		{name: "NXDOMAIN", qname: "missing.example.com.", qtype: dns.TypeA, rcode: dns.RcodeNameError},
+		{name: "Large answer truncated over UDP", qname: "big.example.com.", qtype: dns.TypeTXT, truncated: true, answers: -1},
+		{name: "Large answer over UDP with EDNS", qname: "big.example.com.", qtype: dns.TypeTXT, edns: true, answers: 12},
+		{name: "Large answer over TCP", net: "tcp", qname: "big.example.com.", qtype: dns.TypeTXT, answers: 12},
+		{name: "Forced truncation", options: ServerOptions{Truncate: true}, qname: "mail.example.com.", qtype: dns.TypeA, truncated: true},
+		{name: "Forced truncation isn't applied to TCP", options: ServerOptions{Truncate: true}, net: "tcp", qname: "mail.example.com.", qtype: dns.TypeA, answers: 1},
+		{name: "SERVFAIL", options: ServerOptions{ServFailRate: 1}, qname: "mail.example.com.", qtype: dns.TypeA, rcode: dns.RcodeServerFailure},
+		{name: "Latency", options: ServerOptions{Latency: 50 * time.Millisecond}, qname: "mail.example.com.", qtype: dns.TypeA, answers: 1, minDuration: 50 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := startTestServer(t, tt.options)
+			client := &dns.Client{Net: tt.net}
+			msg := new(dns.Msg)
+			msg.SetQuestion(tt.qname, tt.qtype)
+			if tt.edns {
+				msg.SetEdns0(4096, false)
+			}
+
+			start := time.Now()
+			resp, _, err := client.Exchange(msg, addr)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if time.Since(start) < tt.minDuration {
+				t.Errorf("expected the response to take at least %v", tt.minDuration)
+			}
+			if resp.Rcode != tt.rcode {
+				t.Errorf("expected rcode %s, got %s", dns.RcodeToString[tt.rcode], dns.RcodeToString[resp.Rcode])
+			}
+			if resp.Truncated != tt.truncated {
+				t.Errorf("expected truncated %v, got %v", tt.truncated, resp.Truncated)
+			}
+			// -1 leaves the number of records that still fit unchecked
+			if tt.answers >= 0 && len(resp.Answer) != tt.answers {
+				t.Errorf("expected %d answers, got %d", tt.answers, len(resp.Answer))
+			}
+			if tt.edns && resp.IsEdns0() == nil {
+				t.Errorf("expected an EDNS option in the response")
+			}
+		})
+	}
+}
+
+func TestNewServerOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		options ServerOptions
+	}{
+		{name: "Negative latency", options: ServerOptions{Latency: -time.Second}},
+		{name: "SERVFAIL rate above 1", options: ServerOptions{ServFailRate: 1.5}},
+		{name: "Negative SERVFAIL rate", options: ServerOptions{ServFailRate: -0.1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewServer(NewZoneClient(), tt.options); err == nil {
+				t.Errorf("expected an error, got nil")
+			}
+		})
+	}
+}
+
+func TestQueryDNSServerPort(t *testing.T) {
+	addr := startTestServer(t, ServerOptions{})
+	records, err := QueryDNS("www.example.com", addr, new(dns.Client))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(records.CNAMERecords, ",") != "example.com." || strings.Join(records.ARecords, ",") != "192.0.2.1" {
+		t.Errorf("unexpected records %+v", records)
+	}
+}
@@ -0,0 +1,91 @@
+package dns
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// tsigFudge is the allowed clock skew between sherlock and the server, in seconds
+const tsigFudge = 300
+
+// TSIGKey is a shared secret used to sign queries and verify responses
+type TSIGKey struct {
+	Name      string
+	Algorithm string
+	// Secret is the base64 encoded key
+	Secret string
+}
+
+// ParseTSIGAlgorithm maps an algorithm name such as hmac-sha256 to its TSIG algorithm name
+func ParseTSIGAlgorithm(algorithm string) (string, error) {
+	switch strings.TrimSuffix(strings.ToLower(algorithm), ".") {
+	case "", "hmac-sha256":
+		return dns.HmacSHA256, nil
+	case "hmac-sha1":
+		return dns.HmacSHA1, nil
+	case "hmac-sha224":
+		return dns.HmacSHA224, nil
+	case "hmac-sha384":
+		return dns.HmacSHA384, nil
+	case "hmac-sha512":
+		return dns.HmacSHA512, nil
+	default:
+		return "", fmt.Errorf("unsupported TSIG algorithm '%s', supported algorithms: hmac-sha1, hmac-sha224, hmac-sha256, hmac-sha384, hmac-sha512", algorithm)
+	}
+}
+
+// NewTSIGKey validates the key and normalizes its name and algorithm
+func NewTSIGKey(name string, algorithm string, secret string) (TSIGKey, error) {
+	if name == "" {
+		return TSIGKey{}, fmt.Errorf("TSIG key name must be set")
+	}
+	alg, err := ParseTSIGAlgorithm(algorithm)
+	if err != nil {
+		return TSIGKey{}, err
+	}
+	secret = strings.TrimSpace(secret)
+	if secret == "" {
+		return TSIGKey{}, fmt.Errorf("TSIG secret for %s is empty", name)
+	}
+	if _, err := base64.StdEncoding.DecodeString(secret); err != nil {
+		return TSIGKey{}, fmt.Errorf("TSIG secret for %s isn't valid base64: %w", name, err)
+	}
+	return TSIGKey{Name: dns.CanonicalName(name), Algorithm: alg, Secret: secret}, nil
+}
+
+// TSIGClient signs every query with a TSIG key and requires a valid signature on every response
+type TSIGClient struct {
+	Client *dns.Client
+	Key    TSIGKey
+}
+
+// NewTSIGClient adds the key to the client, which verifies the signatures of responses to signed queries
+func NewTSIGClient(client *dns.Client, key TSIGKey) *TSIGClient {
+	if client.TsigSecret == nil {
+		client.TsigSecret = make(map[string]string)
+	}
+	client.TsigSecret[key.Name] = key.Secret
+	return &TSIGClient{Client: client, Key: key}
+}
+
+func (c *TSIGClient) Exchange(msg *dns.Msg, server string) (*dns.Msg, time.Duration, error) {
+	msg.SetTsig(c.Key.Name, c.Key.Algorithm, tsigFudge, time.Now().Unix())
+	resp, rtt, err := c.Client.Exchange(msg, server)
+	if err != nil {
+		return nil, rtt, fmt.Errorf("TSIG exchange with %s failed: %w", server, err)
+	}
+
+	// The client only verifies signatures that are present, an unsigned response has to be rejected here
+	tsig := resp.IsTsig()
+	if tsig == nil {
+		return nil, rtt, fmt.Errorf("response from %s isn't signed with TSIG key %s (rcode %s)", server, c.Key.Name, dns.RcodeToString[resp.Rcode])
+	}
+	if tsig.Error != dns.RcodeSuccess {
+		return nil, rtt, fmt.Errorf("%s rejected TSIG key %s: %s", server, c.Key.Name, dns.RcodeToString[int(tsig.Error)])
+	}
+	return resp, rtt, nil
+}
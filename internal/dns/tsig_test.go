@@ -0,0 +1,134 @@
+package dns
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const testTSIGSecret = "c2hlcmxvY2stdGVzdC1zZWNyZXQ="
+
+// startTSIGServer runs a server that only answers queries signed with the key,
+// queries for unsigned.example.com. get an unsigned answer regardless
+func startTSIGServer(t *testing.T, key TSIGKey) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	started := make(chan struct{})
+	server := &dns.Server{
+		PacketConn:        pc,
+		TsigSecret:        map[string]string{key.Name: key.Secret},
+		NotifyStartedFunc: func() { close(started) },
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+			resp := new(dns.Msg)
+			resp.SetReply(r)
+			tsig := r.IsTsig()
+			if tsig == nil || w.TsigStatus() != nil {
+				resp.Rcode = dns.RcodeNotAuth
+				_ = w.WriteMsg(resp)
+				return
+			}
+			resp.Answer = append(resp.Answer, &dns.A{
+				Hdr: dns.RR_Header{Name: r.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+				A:   net.ParseIP("192.0.2.1"),
+			})
+			if r.Question[0].Name != "unsigned.example.com." {
+				resp.SetTsig(tsig.Hdr.Name, tsig.Algorithm, 300, time.Now().Unix())
+			}
+			_ = w.WriteMsg(resp)
+		}),
+	}
+	go func() { _ = server.ActivateAndServe() }()
+	<-started
+	t.Cleanup(func() { _ = server.Shutdown() })
+	return pc.LocalAddr().String()
+}
+
+func TestNewTSIGKey(t *testing.T) {
+	tests := []struct {
+		name        string
+		keyName     string
+		algorithm   string
+		secret      string
+		expected    TSIGKey
+		expectError bool
+	}{
+		{
+			name:     "Default algorithm",
+			keyName:  "sherlock",
+			secret:   testTSIGSecret + "\n",
+			expected: TSIGKey{Name: "sherlock.", Algorithm: dns.HmacSHA256, Secret: testTSIGSecret},
+		},
+		{
+			name:      "Explicit algorithm",
+			keyName:   "Sherlock.Example.",
+			algorithm: "HMAC-SHA512",
+			secret:    testTSIGSecret,
+			expected:  TSIGKey{Name: "sherlock.example.", Algorithm: dns.HmacSHA512, Secret: testTSIGSecret},
+		},
+		{name: "Missing name", secret: testTSIGSecret, expectError: true},
+		{name: "Unsupported algorithm", keyName: "sherlock", algorithm: "hmac-md5", secret: testTSIGSecret, expectError: true},
+		{name: "Empty secret", keyName: "sherlock", secret: " ", expectError: true},
+		{name: "Invalid secret", keyName: "sherlock", secret: "not base64!", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := NewTSIGKey(tt.keyName, tt.algorithm, tt.secret)
+			if (err != nil) != tt.expectError {
+				t.Fatalf("NewTSIGKey() error = %v, expectError %v", err, tt.expectError)
+			}
+			if !tt.expectError && key != tt.expected {
+				t.Errorf("NewTSIGKey() = %+v, want %+v", key, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTSIGClientExchange(t *testing.T) {
+	serverKey, _ := NewTSIGKey("sherlock", "hmac-sha256", testTSIGSecret)
+	addr := startTSIGServer(t, serverKey)
+
+	wrongSecret, _ := NewTSIGKey("sherlock", "hmac-sha256", "d3Jvbmctc2VjcmV0")
+	unknownKey, _ := NewTSIGKey("other", "hmac-sha256", testTSIGSecret)
+
+	tests := []struct {
+		name          string
+		key           TSIGKey
+		host          string
+		expectedError string
+	}{
+		{name: "Signed query and response", key: serverKey, host: "example.com."},
+		{name: "Wrong secret", key: wrongSecret, host: "example.com.", expectedError: "isn't signed"},
+		{name: "Unknown key", key: unknownKey, host: "example.com.", expectedError: "isn't signed"},
+		{name: "Unsigned response", key: serverKey, host: "unsigned.example.com.", expectedError: "isn't signed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewTSIGClient(&dns.Client{Timeout: 2 * time.Second}, tt.key)
+			msg := new(dns.Msg)
+			msg.SetQuestion(tt.host, dns.TypeA)
+
+			resp, _, err := client.Exchange(msg, addr)
+			if tt.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+					t.Errorf("Exchange() error = %v, want it to contain %q", err, tt.expectedError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Exchange() unexpected error = %v", err)
+			}
+			if len(resp.Answer) != 1 {
+				t.Errorf("expected one answer, got %v", resp.Answer)
+			}
+		})
+	}
+}
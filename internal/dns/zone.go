@@ -0,0 +1,291 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ZoneSnapshot is every record of a zone in a normalized, sorted order
+type ZoneSnapshot struct {
+	Zone    string
+	Records []dns.RR
+}
+
+// RRset is the records sharing an owner name and type
+type RRset struct {
+	Name    string
+	Type    uint16
+	Records []dns.RR
+}
+
+// RRsetChange is an RRset whose records differ between two snapshots
+type RRsetChange struct {
+	Old RRset
+	New RRset
+}
+
+// ZoneDiff is the difference between two snapshots of a zone
+type ZoneDiff struct {
+	Added   []RRset
+	Removed []RRset
+	Changed []RRsetChange
+}
+
+// Empty reports whether the snapshots hold the same records
+func (d ZoneDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// String returns the type and owner name of the RRset, e.g. "www.example.com. A"
+func (s RRset) String() string {
+	return s.Name + " " + dns.TypeToString[s.Type]
+}
+
+// TransferZone pulls a full copy of the zone from the server with AXFR, the transfer is signed when a key is set
+func TransferZone(ctx context.Context, zone string, dnsServer string, key *TSIGKey) (*ZoneSnapshot, error) {
+	zone = dns.CanonicalName(zone)
+	server := ServerAddress(dnsServer)
+	_, span := tracer.Start(ctx, "dns.transfer", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("dns.question.name", zone),
+		attribute.String("dns.question.type", "AXFR"),
+		attribute.String("server.address", server),
+	))
+	defer span.End()
+
+	records, err := transferZone(zone, server, key)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("dns.response.answer_count", len(records)))
+
+	return NewZoneSnapshot(zone, records)
+}
+
+func transferZone(zone string, server string, key *TSIGKey) ([]dns.RR, error) {
+	msg := new(dns.Msg)
+	msg.SetAxfr(zone)
+	transfer := new(dns.Transfer)
+	if key != nil {
+		transfer.TsigSecret = map[string]string{key.Name: key.Secret}
+		msg.SetTsig(key.Name, key.Algorithm, tsigFudge, time.Now().Unix())
+	}
+
+	envelopes, err := transfer.In(msg, server)
+	if err != nil {
+		return nil, fmt.Errorf("AXFR of %s from %s failed: %w", zone, server, err)
+	}
+
+	// The channel has to be drained even after an error, otherwise the transfer goroutine never exits
+	var records []dns.RR
+	var transferErr error
+	for envelope := range envelopes {
+		if envelope.Error != nil {
+			if transferErr == nil {
+				transferErr = envelope.Error
+			}
+			continue
+		}
+		records = append(records, envelope.RR...)
+	}
+	if transferErr != nil {
+		return nil, fmt.Errorf("AXFR of %s from %s failed: %w", zone, server, transferErr)
+	}
+	return records, nil
+}
+
+// NewZoneSnapshot normalizes the records of the zone, owner names are lowercased, duplicates such as the SOA
+// closing a transfer are dropped and the records are sorted by name, type and value
+func NewZoneSnapshot(zone string, records []dns.RR) (*ZoneSnapshot, error) {
+	zone = dns.CanonicalName(zone)
+	seen := make(map[string]bool)
+	snapshot := &ZoneSnapshot{Zone: zone}
+	hasSOA := false
+
+	for _, rr := range records {
+		rr = dns.Copy(rr)
+		hdr := rr.Header()
+		hdr.Name = dns.CanonicalName(hdr.Name)
+		if !dns.IsSubDomain(zone, hdr.Name) {
+			return nil, fmt.Errorf("record %s is outside of zone %s", hdr.Name, zone)
+		}
+		if hdr.Rrtype == dns.TypeSOA && hdr.Name == zone {
+			hasSOA = true
+		}
+
+		key := rr.String()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		snapshot.Records = append(snapshot.Records, rr)
+	}
+	if !hasSOA {
+		return nil, fmt.Errorf("zone %s has no SOA record", zone)
+	}
+
+	sort.SliceStable(snapshot.Records, func(i, j int) bool {
+		return compareRecords(snapshot.Records[i], snapshot.Records[j]) < 0
+	})
+	return snapshot, nil
+}
+
+// compareRecords orders records by owner name with the labels compared from the root down, so names are grouped
+// under their parents, then by type with the SOA first and then by their presentation form
+func compareRecords(a dns.RR, b dns.RR) int {
+	if c := CompareNames(a.Header().Name, b.Header().Name); c != 0 {
+		return c
+	}
+	if c := CompareTypes(a.Header().Rrtype, b.Header().Rrtype); c != 0 {
+		return c
+	}
+	return strings.Compare(a.String(), b.String())
+}
+
+// CompareNames orders names in zone order, with the labels compared from the root down
+func CompareNames(a string, b string) int {
+	labelsA, labelsB := dns.SplitDomainName(a), dns.SplitDomainName(b)
+	for i := 1; i <= len(labelsA) && i <= len(labelsB); i++ {
+		if c := strings.Compare(labelsA[len(labelsA)-i], labelsB[len(labelsB)-i]); c != 0 {
+			return c
+		}
+	}
+	return len(labelsA) - len(labelsB)
+}
+
+// CompareTypes orders record types with the SOA first, then by type number
+func CompareTypes(a uint16, b uint16) int {
+	switch {
+	case a == b:
+		return 0
+	case a == dns.TypeSOA:
+		return -1
+	case b == dns.TypeSOA:
+		return 1
+	default:
+		return int(a) - int(b)
+	}
+}
+
+// WriteTo writes the snapshot in zone file format with one record per line
+func (s *ZoneSnapshot) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "; sherlock snapshot of %s, %d records\n", s.Zone, len(s.Records))
+	for _, rr := range s.Records {
+		b.WriteString(rr.String() + "\n")
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// ParseZoneFile parses a zone file, relative names are completed with the origin. $INCLUDE is only
+// allowed when the file name is set, included paths are relative to it
+func ParseZoneFile(r io.Reader, origin string, filename string) ([]dns.RR, error) {
+	if origin != "" {
+		origin = dns.Fqdn(origin)
+	}
+	parser := dns.NewZoneParser(r, origin, filename)
+	parser.SetIncludeAllowed(filename != "")
+
+	var records []dns.RR
+	for rr, ok := parser.Next(); ok; rr, ok = parser.Next() {
+		records = append(records, rr)
+	}
+	if err := parser.Err(); err != nil {
+		return nil, fmt.Errorf("failed to parse the zone: %w", err)
+	}
+	return records, nil
+}
+
+// ZoneOrigin returns the owner name of the first SOA record, which names the zone
+func ZoneOrigin(records []dns.RR) (string, error) {
+	for _, rr := range records {
+		if rr.Header().Rrtype == dns.TypeSOA {
+			return rr.Header().Name, nil
+		}
+	}
+	return "", fmt.Errorf("no SOA record found")
+}
+
+// ReadZoneSnapshot parses a snapshot, or any zone file with fully qualified names, the zone is named by its SOA
+func ReadZoneSnapshot(r io.Reader) (*ZoneSnapshot, error) {
+	records, err := ParseZoneFile(r, "", "")
+	if err != nil {
+		return nil, err
+	}
+	zone, err := ZoneOrigin(records)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the zone: %w", err)
+	}
+	return NewZoneSnapshot(zone, records)
+}
+
+// RRsets groups the records of the snapshot by owner name and type, in snapshot order
+func (s *ZoneSnapshot) RRsets() []RRset {
+	var sets []RRset
+	for _, rr := range s.Records {
+		hdr := rr.Header()
+		if n := len(sets); n > 0 && sets[n-1].Name == hdr.Name && sets[n-1].Type == hdr.Rrtype {
+			sets[n-1].Records = append(sets[n-1].Records, rr)
+			continue
+		}
+		sets = append(sets, RRset{Name: hdr.Name, Type: hdr.Rrtype, Records: []dns.RR{rr}})
+	}
+	return sets
+}
+
+// DiffZones compares two snapshots RRset by RRset, an RRset is changed when any of its records or TTLs differ
+func DiffZones(before *ZoneSnapshot, after *ZoneSnapshot) ZoneDiff {
+	type key struct {
+		name  string
+		rtype uint16
+	}
+	oldSets := make(map[key]RRset)
+	for _, set := range before.RRsets() {
+		oldSets[key{set.Name, set.Type}] = set
+	}
+
+	var diff ZoneDiff
+	for _, set := range after.RRsets() {
+		k := key{set.Name, set.Type}
+		previous, ok := oldSets[k]
+		if !ok {
+			diff.Added = append(diff.Added, set)
+			continue
+		}
+		delete(oldSets, k)
+		if !sameRecords(previous.Records, set.Records) {
+			diff.Changed = append(diff.Changed, RRsetChange{Old: previous, New: set})
+		}
+	}
+	// Walk the old snapshot again so removals keep its order
+	for _, set := range before.RRsets() {
+		if _, ok := oldSets[key{set.Name, set.Type}]; ok {
+			diff.Removed = append(diff.Removed, set)
+		}
+	}
+	return diff
+}
+
+// sameRecords compares two sorted RRsets
+func sameRecords(a []dns.RR, b []dns.RR) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].String() != b[i].String() {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,224 @@
+package dns
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+const testZoneFile = `
+www.Example.com. 300 IN A 192.0.2.2
+example.com. 3600 IN SOA ns1.example.com. hostmaster.example.com. 1 7200 3600 1209600 300
+www.example.com. 300 IN A 192.0.2.1
+example.com. 3600 IN NS ns1.example.com.
+mail.example.com. 300 IN A 192.0.2.25
+example.com. 3600 IN MX 10 mail.example.com.
+example.com. 3600 IN SOA ns1.example.com. hostmaster.example.com. 1 7200 3600 1209600 300
+`
+
+func parseTestRecords(t *testing.T, zone string) []dns.RR {
+	t.Helper()
+	var records []dns.RR
+	parser := dns.NewZoneParser(strings.NewReader(zone), "", "")
+	for rr, ok := parser.Next(); ok; rr, ok = parser.Next() {
+		records = append(records, rr)
+	}
+	if err := parser.Err(); err != nil {
+		t.Fatalf("failed to parse the test zone: %v", err)
+	}
+	return records
+}
+
+func mustSnapshot(t *testing.T, zone string) *ZoneSnapshot {
+	t.Helper()
+	snapshot, err := ReadZoneSnapshot(strings.NewReader(zone))
+	if err != nil {
+		t.Fatalf("failed to read the snapshot: %v", err)
+	}
+	return snapshot
+}
+
+// startAXFRServer runs a TCP server that transfers the records, when a key is set the request has to be signed with it
+func startAXFRServer(t *testing.T, records []dns.RR, key *TSIGKey) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	started := make(chan struct{})
+	server := &dns.Server{
+		Listener:          listener,
+		NotifyStartedFunc: func() { close(started) },
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+			if key != nil && (r.IsTsig() == nil || w.TsigStatus() != nil) {
+				resp := new(dns.Msg)
+				resp.SetRcode(r, dns.RcodeRefused)
+				_ = w.WriteMsg(resp)
+				return
+			}
+			envelopes := make(chan *dns.Envelope)
+			transfer := new(dns.Transfer)
+			go func() {
+				// Split the records over two messages like servers do for larger zones
+				envelopes <- &dns.Envelope{RR: records[:2]}
+				envelopes <- &dns.Envelope{RR: records[2:]}
+				close(envelopes)
+			}()
+			_ = transfer.Out(w, r, envelopes)
+		}),
+	}
+	if key != nil {
+		server.TsigSecret = map[string]string{key.Name: key.Secret}
+	}
+	go func() { _ = server.ActivateAndServe() }()
+	<-started
+	t.Cleanup(func() { _ = server.Shutdown() })
+	return listener.Addr().String()
+}
+
+func TestNewZoneSnapshot(t *testing.T) {
+	snapshot, err := NewZoneSnapshot("Example.com", parseTestRecords(t, testZoneFile))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{
+		"example.com.\t3600\tIN\tSOA\tns1.example.com. hostmaster.example.com. 1 7200 3600 1209600 300",
+		"example.com.\t3600\tIN\tNS\tns1.example.com.",
+		"example.com.\t3600\tIN\tMX\t10 mail.example.com.",
+		"mail.example.com.\t300\tIN\tA\t192.0.2.25",
+		"www.example.com.\t300\tIN\tA\t192.0.2.1",
+		"www.example.com.\t300\tIN\tA\t192.0.2.2",
+	}
+	if snapshot.Zone != "example.com." {
+		t.Errorf("expected zone example.com., got %s", snapshot.Zone)
+	}
+	if len(snapshot.Records) != len(expected) {
+		t.Fatalf("expected %d records, got %d: %v", len(expected), len(snapshot.Records), snapshot.Records)
+	}
+	for i, rr := range snapshot.Records {
+		if rr.String() != expected[i] {
+			t.Errorf("record %d: expected %q, got %q", i, expected[i], rr.String())
+		}
+	}
+
+	var buf bytes.Buffer
+	if _, err := snapshot.WriteTo(&buf); err != nil {
+		t.Fatalf("failed to write the snapshot: %v", err)
+	}
+	reread, err := ReadZoneSnapshot(&buf)
+	if err != nil {
+		t.Fatalf("failed to read the snapshot back: %v", err)
+	}
+	if diff := DiffZones(snapshot, reread); !diff.Empty() {
+		t.Errorf("expected the snapshot to read back unchanged, got %+v", diff)
+	}
+}
+
+func TestNewZoneSnapshotErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		zone string
+	}{
+		{name: "Missing SOA", zone: "www.example.com. 300 IN A 192.0.2.1"},
+		{name: "Record outside of the zone", zone: "example.com. 3600 IN SOA ns1.example.com. hostmaster.example.com. 1 7200 3600 1209600 300\nwww.example.org. 300 IN A 192.0.2.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewZoneSnapshot("example.com.", parseTestRecords(t, tt.zone)); err == nil {
+				t.Errorf("expected an error, got nil")
+			}
+		})
+	}
+}
+
+func TestDiffZones(t *testing.T) {
+	before := mustSnapshot(t, testZoneFile)
+	after := mustSnapshot(t, `
+example.com. 3600 IN SOA ns1.example.com. hostmaster.example.com. 2 7200 3600 1209600 300
+example.com. 3600 IN NS ns1.example.com.
+www.example.com. 300 IN A 192.0.2.1
+www.example.com. 300 IN A 192.0.2.2
+www.example.com. 300 IN AAAA 2001:db8::1
+mail.example.com. 600 IN A 192.0.2.25
+`)
+
+	diff := DiffZones(before, after)
+	sets := func(sets []RRset) []string {
+		var names []string
+		for _, set := range sets {
+			names = append(names, set.String())
+		}
+		return names
+	}
+
+	if got := sets(diff.Added); len(got) != 1 || got[0] != "www.example.com. AAAA" {
+		t.Errorf("unexpected added RRsets: %v", got)
+	}
+	if got := sets(diff.Removed); len(got) != 1 || got[0] != "example.com. MX" {
+		t.Errorf("unexpected removed RRsets: %v", got)
+	}
+	var changed []string
+	for _, change := range diff.Changed {
+		changed = append(changed, change.New.String())
+	}
+	if len(changed) != 2 || changed[0] != "example.com. SOA" || changed[1] != "mail.example.com. A" {
+		t.Errorf("unexpected changed RRsets: %v", changed)
+	}
+	if diff.Empty() {
+		t.Errorf("expected the diff not to be empty")
+	}
+}
+
+func TestTransferZone(t *testing.T) {
+	key, err := NewTSIGKey("transfer", "", testTSIGSecret)
+	if err != nil {
+		t.Fatalf("failed to create the key: %v", err)
+	}
+	otherKey, err := NewTSIGKey("transfer", "", "b3RoZXItc2VjcmV0")
+	if err != nil {
+		t.Fatalf("failed to create the key: %v", err)
+	}
+	records := parseTestRecords(t, `
+example.com. 3600 IN SOA ns1.example.com. hostmaster.example.com. 1 7200 3600 1209600 300
+example.com. 3600 IN NS ns1.example.com.
+www.example.com. 300 IN A 192.0.2.1
+example.com. 3600 IN SOA ns1.example.com. hostmaster.example.com. 1 7200 3600 1209600 300
+`)
+
+	tests := []struct {
+		name        string
+		serverKey   *TSIGKey
+		clientKey   *TSIGKey
+		expectError bool
+	}{
+		{name: "Unsigned transfer"},
+		{name: "Signed transfer", serverKey: &key, clientKey: &key},
+		{name: "Unsigned transfer refused", serverKey: &key, expectError: true},
+		{name: "Wrong key", serverKey: &key, clientKey: &otherKey, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := startAXFRServer(t, records, tt.serverKey)
+			got, err := transferZone("example.com.", addr, tt.clientKey)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(got) != len(records) {
+				t.Errorf("expected %d records, got %d", len(records), len(got))
+			}
+		})
+	}
+}
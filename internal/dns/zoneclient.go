@@ -0,0 +1,240 @@
+package dns
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// ZoneFileScheme prefixes a DNS server that is a local zone file, e.g. file://zones/db.example.com?origin=example.com
+const ZoneFileScheme = "file://"
+
+// maxCNAMEChain is the number of CNAMEs followed before giving up on a chain
+const maxCNAMEChain = 8
+
+// ZoneClient answers queries from zones in memory the way their authoritative server would, so tests can run
+// without any network access
+type ZoneClient struct {
+	zones []*zoneIndex
+}
+
+// zoneIndex is a zone's records by owner name and type
+type zoneIndex struct {
+	name    string
+	soa     dns.RR
+	records map[string]map[uint16][]dns.RR
+	// cuts are the names delegated to child zones
+	cuts []string
+}
+
+// IsZoneFileURL reports whether the DNS server is a local zone file
+func IsZoneFileURL(dnsServer string) bool {
+	return strings.HasPrefix(dnsServer, ZoneFileScheme)
+}
+
+// ParseZoneFileURL splits a file:// DNS server into the path of the zone file and its optional origin
+func ParseZoneFileURL(dnsServer string) (path string, origin string, err error) {
+	if !IsZoneFileURL(dnsServer) {
+		return "", "", fmt.Errorf("'%s' isn't a %s URL", dnsServer, ZoneFileScheme)
+	}
+	path, query, _ := strings.Cut(strings.TrimPrefix(dnsServer, ZoneFileScheme), "?")
+	if path == "" {
+		return "", "", fmt.Errorf("'%s' has no zone file path", dnsServer)
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return "", "", fmt.Errorf("'%s' has an invalid query: %w", dnsServer, err)
+	}
+	for key := range values {
+		if key != "origin" {
+			return "", "", fmt.Errorf("'%s' has an unknown option '%s', only origin is supported", dnsServer, key)
+		}
+	}
+	return path, values.Get("origin"), nil
+}
+
+// LoadZoneFile reads and normalizes a zone file, the zone is named by its SOA when no origin is set
+func LoadZoneFile(path string, origin string) (*ZoneSnapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	records, err := ParseZoneFile(f, origin, path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if origin == "" {
+		if origin, err = ZoneOrigin(records); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return NewZoneSnapshot(origin, records)
+}
+
+// NewZoneClientFromURL loads the zone file of a file:// DNS server
+func NewZoneClientFromURL(dnsServer string) (*ZoneClient, error) {
+	path, origin, err := ParseZoneFileURL(dnsServer)
+	if err != nil {
+		return nil, err
+	}
+	zone, err := LoadZoneFile(path, origin)
+	if err != nil {
+		return nil, err
+	}
+	return NewZoneClient(zone), nil
+}
+
+// NewZoneClient indexes the zones, queries outside of all of them are refused
+func NewZoneClient(zones ...*ZoneSnapshot) *ZoneClient {
+	client := &ZoneClient{}
+	for _, zone := range zones {
+		index := &zoneIndex{name: zone.Zone, records: make(map[string]map[uint16][]dns.RR)}
+		for _, rr := range zone.Records {
+			hdr := rr.Header()
+			if index.records[hdr.Name] == nil {
+				index.records[hdr.Name] = make(map[uint16][]dns.RR)
+			}
+			index.records[hdr.Name][hdr.Rrtype] = append(index.records[hdr.Name][hdr.Rrtype], rr)
+			switch {
+			case hdr.Rrtype == dns.TypeSOA && hdr.Name == zone.Zone && index.soa == nil:
+				index.soa = rr
+			case hdr.Rrtype == dns.TypeNS && hdr.Name != zone.Zone:
+				index.cuts = append(index.cuts, hdr.Name)
+			}
+		}
+		client.zones = append(client.zones, index)
+	}
+	return client
+}
+
+func (c *ZoneClient) Exchange(msg *dns.Msg, server string) (*dns.Msg, time.Duration, error) {
+	return c.Resolve(msg), 0, nil
+}
+
+// Resolve builds the authoritative response to the query, following CNAMEs within the loaded zones
+func (c *ZoneClient) Resolve(msg *dns.Msg) *dns.Msg {
+	resp := new(dns.Msg)
+	resp.SetReply(msg)
+	if len(msg.Question) != 1 {
+		resp.Rcode = dns.RcodeFormatError
+		return resp
+	}
+	question := msg.Question[0]
+	zone := c.findZone(dns.CanonicalName(question.Name))
+	if zone == nil {
+		resp.Rcode = dns.RcodeRefused
+		return resp
+	}
+	resp.Authoritative = true
+
+	name := dns.CanonicalName(question.Name)
+	for i := 0; i <= maxCNAMEChain; i++ {
+		if cut := zone.cutFor(name); cut != "" {
+			// A referral isn't authoritative for the name, the child zone's servers are
+			resp.Authoritative = len(resp.Answer) > 0
+			resp.Ns = append(resp.Ns, zone.records[cut][dns.TypeNS]...)
+			return resp
+		}
+
+		rrsets, exists := zone.lookup(name)
+		if !exists {
+			resp.Rcode = dns.RcodeNameError
+			resp.Ns = append(resp.Ns, zone.soa)
+			return resp
+		}
+		if answers, ok := rrsets[question.Qtype]; ok {
+			resp.Answer = append(resp.Answer, withOwner(answers, name)...)
+			return resp
+		}
+		cname, ok := rrsets[dns.TypeCNAME]
+		if !ok || question.Qtype == dns.TypeCNAME {
+			resp.Ns = append(resp.Ns, zone.soa)
+			return resp
+		}
+
+		resp.Answer = append(resp.Answer, withOwner(cname, name)...)
+		name = dns.CanonicalName(cname[0].(*dns.CNAME).Target)
+		if zone = c.findZone(name); zone == nil {
+			// The target is outside of the loaded zones, like a server the rest is left to the resolver
+			return resp
+		}
+	}
+	// The chain is too long or loops
+	resp.Rcode = dns.RcodeServerFailure
+	resp.Answer = nil
+	return resp
+}
+
+// findZone returns the most specific zone the name is in
+func (c *ZoneClient) findZone(name string) *zoneIndex {
+	var best *zoneIndex
+	for _, zone := range c.zones {
+		if dns.IsSubDomain(zone.name, name) && (best == nil || dns.CountLabel(zone.name) > dns.CountLabel(best.name)) {
+			best = zone
+		}
+	}
+	return best
+}
+
+// cutFor returns the delegation the name is at or below, if any
+func (z *zoneIndex) cutFor(name string) string {
+	for _, cut := range z.cuts {
+		if dns.IsSubDomain(cut, name) {
+			return cut
+		}
+	}
+	return ""
+}
+
+// lookup returns the records of the name, synthesized from a wildcard when the name itself doesn't exist. A name
+// without records but with names below it still exists
+func (z *zoneIndex) lookup(name string) (map[uint16][]dns.RR, bool) {
+	if rrsets, ok := z.records[name]; ok {
+		return rrsets, true
+	}
+	if z.hasDescendants(name) {
+		return nil, true
+	}
+
+	// The wildcard of the closest existing ancestor applies, e.g. *.example.com. for a.b.example.com.
+	labels := dns.SplitDomainName(name)
+	for i := 1; i < len(labels); i++ {
+		ancestor := dns.Fqdn(strings.Join(labels[i:], "."))
+		if !dns.IsSubDomain(z.name, ancestor) {
+			break
+		}
+		if rrsets, ok := z.records["*."+ancestor]; ok {
+			return rrsets, true
+		}
+		if _, ok := z.records[ancestor]; ok || z.hasDescendants(ancestor) {
+			break
+		}
+	}
+	return nil, false
+}
+
+func (z *zoneIndex) hasDescendants(name string) bool {
+	for owner := range z.records {
+		if owner != name && dns.IsSubDomain(name, owner) {
+			return true
+		}
+	}
+	return false
+}
+
+// withOwner copies the records with the queried name as their owner, which only differs for wildcards
+func withOwner(records []dns.RR, name string) []dns.RR {
+	copies := make([]dns.RR, 0, len(records))
+	for _, rr := range records {
+		rr = dns.Copy(rr)
+		rr.Header().Name = name
+		copies = append(copies, rr)
+	}
+	return copies
+}
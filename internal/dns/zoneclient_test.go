@@ -0,0 +1,160 @@
+package dns
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+const testClientZone = `$ORIGIN example.com.
+$TTL 300
+@         IN SOA   ns1 hostmaster 1 7200 3600 1209600 300
+          IN NS    ns1
+          IN A     192.0.2.1
+          IN MX    10 mail
+www       IN CNAME @
+ftp       IN CNAME www
+ext       IN CNAME cdn.example.net.
+loop1     IN CNAME loop2
+loop2     IN CNAME loop1
+mail      IN A     192.0.2.25
+a.b       IN A     192.0.2.30
+*.apps    IN A     192.0.2.80
+child     IN NS    ns1.child
+ns1.child IN A     192.0.2.99
+`
+
+func TestZoneClientResolve(t *testing.T) {
+	zone, err := NewZoneSnapshot("example.com.", parseTestRecords(t, testClientZone))
+	if err != nil {
+		t.Fatalf("failed to load the zone: %v", err)
+	}
+	client := NewZoneClient(zone)
+	soa := zone.Records[0]
+	var delegation []dns.RR
+	for _, rr := range zone.Records {
+		if rr.Header().Name == "child.example.com." {
+			delegation = append(delegation, rr)
+		}
+	}
+
+	tests := []struct {
+		name          string
+		qname         string
+		qtype         uint16
+		rcode         int
+		authoritative bool
+		answer        []string
+		authority     []dns.RR
+	}{
+		{name: "Answer", qname: "mail.example.com.", qtype: dns.TypeA, authoritative: true, answer: []string{"mail.example.com.\t300\tIN\tA\t192.0.2.25"}},
+		{name: "Case insensitive", qname: "MAIL.Example.com.", qtype: dns.TypeA, authoritative: true, answer: []string{"mail.example.com.\t300\tIN\tA\t192.0.2.25"}},
+		{
+			name: "CNAME chain", qname: "ftp.example.com.", qtype: dns.TypeA, authoritative: true,
+			answer: []string{
+				"ftp.example.com.\t300\tIN\tCNAME\twww.example.com.",
+				"www.example.com.\t300\tIN\tCNAME\texample.com.",
+				"example.com.\t300\tIN\tA\t192.0.2.1",
+			},
+		},
+		{name: "CNAME query", qname: "www.example.com.", qtype: dns.TypeCNAME, authoritative: true, answer: []string{"www.example.com.\t300\tIN\tCNAME\texample.com."}},
+		{name: "CNAME outside of the zone", qname: "ext.example.com.", qtype: dns.TypeA, authoritative: true, answer: []string{"ext.example.com.\t300\tIN\tCNAME\tcdn.example.net."}},
+		{name: "CNAME loop", qname: "loop1.example.com.", qtype: dns.TypeA, rcode: dns.RcodeServerFailure, authoritative: true},
+		{name: "No data", qname: "mail.example.com.", qtype: dns.TypeAAAA, authoritative: true, authority: []dns.RR{soa}},
+		{name: "Empty non-terminal", qname: "b.example.com.", qtype: dns.TypeA, authoritative: true, authority: []dns.RR{soa}},
+		{name: "NXDOMAIN", qname: "missing.example.com.", qtype: dns.TypeA, rcode: dns.RcodeNameError, authoritative: true, authority: []dns.RR{soa}},
+		{name: "Wildcard", qname: "web.apps.example.com.", qtype: dns.TypeA, authoritative: true, answer: []string{"web.apps.example.com.\t300\tIN\tA\t192.0.2.80"}},
+		{name: "Referral", qname: "www.child.example.com.", qtype: dns.TypeA, authority: delegation},
+		{name: "Outside of the zone", qname: "example.org.", qtype: dns.TypeA, rcode: dns.RcodeRefused},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := new(dns.Msg)
+			msg.SetQuestion(tt.qname, tt.qtype)
+			resp, _, err := client.Exchange(msg, "ignored:53")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if resp.Rcode != tt.rcode {
+				t.Errorf("expected rcode %s, got %s", dns.RcodeToString[tt.rcode], dns.RcodeToString[resp.Rcode])
+			}
+			if resp.Authoritative != tt.authoritative {
+				t.Errorf("expected authoritative %v, got %v", tt.authoritative, resp.Authoritative)
+			}
+			var answer []string
+			for _, rr := range resp.Answer {
+				answer = append(answer, rr.String())
+			}
+			if !reflect.DeepEqual(answer, tt.answer) {
+				t.Errorf("expected answer %v, got %v", tt.answer, answer)
+			}
+			if !reflect.DeepEqual(resp.Ns, tt.authority) {
+				t.Errorf("expected authority %v, got %v", tt.authority, resp.Ns)
+			}
+		})
+	}
+}
+
+func TestParseZoneFileURL(t *testing.T) {
+	tests := []struct {
+		name           string
+		server         string
+		expectedPath   string
+		expectedOrigin string
+		expectError    bool
+	}{
+		{name: "Relative path", server: "file://zones/db.example.com", expectedPath: "zones/db.example.com"},
+		{name: "Absolute path with origin", server: "file:///etc/zones/db.example?origin=example.com", expectedPath: "/etc/zones/db.example", expectedOrigin: "example.com"},
+		{name: "Not a file URL", server: "1.1.1.1", expectError: true},
+		{name: "Missing path", server: "file://", expectError: true},
+		{name: "Unknown option", server: "file://zones/db.example.com?ttl=1", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, origin, err := ParseZoneFileURL(tt.server)
+			if (err != nil) != tt.expectError {
+				t.Fatalf("expected error %v, got %v", tt.expectError, err)
+			}
+			if path != tt.expectedPath || origin != tt.expectedOrigin {
+				t.Errorf("expected %s and %s, got %s and %s", tt.expectedPath, tt.expectedOrigin, path, origin)
+			}
+		})
+	}
+}
+
+func TestQueryDNSZoneFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.example.com")
+	zone := strings.Replace(testClientZone, "$ORIGIN example.com.\n", "", 1)
+	if err := os.WriteFile(path, []byte(zone), 0o600); err != nil {
+		t.Fatalf("failed to write the zone: %v", err)
+	}
+
+	if _, err := NewZoneClientFromURL(ZoneFileScheme + path); err == nil {
+		t.Errorf("expected an error for relative names without an origin, got nil")
+	}
+	client, err := NewZoneClientFromURL(ZoneFileScheme + path + "?origin=example.com")
+	if err != nil {
+		t.Fatalf("failed to load the zone: %v", err)
+	}
+
+	records, err := QueryDNS("ftp.example.com", ZoneFileScheme+path, client)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(records.ARecords, []string{"192.0.2.1"}) {
+		t.Errorf("expected the A record behind the CNAME chain, got %v", records.ARecords)
+	}
+	if !reflect.DeepEqual(records.CNAMERecords, []string{"www.example.com."}) {
+		t.Errorf("expected the CNAME, got %v", records.CNAMERecords)
+	}
+	if !records.Flags[dns.TypeA].Authoritative {
+		t.Errorf("expected an authoritative answer")
+	}
+}
@@ -1,57 +1,118 @@
 package dns_test_executor
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"os"
+	"strings"
 	"sync"
+	"time"
 
 	cfg "github.com/ch0ppy35/sherlock/internal/config"
 	"github.com/ch0ppy35/sherlock/internal/dns"
+	"github.com/ch0ppy35/sherlock/internal/mail"
 	"github.com/ch0ppy35/sherlock/internal/ui"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("github.com/ch0ppy35/sherlock/internal/dns_test_executor")
+
 type DNSTestExecutor struct {
-	Config    cfg.DNSRecordsFullTestConfig
-	Client    dns.IDNSClient
-	Results   map[string]*dns.DNSRecords
-	Errors    map[string]error
-	AllErrors []error
-	mu        sync.Mutex
+	Config      cfg.DNSRecordsFullTestConfig
+	Client      dns.IDNSClient
+	Results     map[string]*dns.DNSRecords
+	Errors      map[string]error
+	Durations   map[string]time.Duration
+	AllErrors   []error
+	TestResults []TestResult
+	// Output receives the progress messages and comparison tables instead of stdout and stderr when set,
+	// io.Discard silences them.
+	Output io.Writer
+	mu     sync.Mutex
+	// subnetResults caches the records queried with an EDNS client subnet by host and subnet
+	subnetResults map[string]*dns.DNSRecords
+}
+
+// TestResult is the outcome of a single test from the configuration
+type TestResult struct {
+	Host     string
+	TestType string
+	Server   string
+	// Variant tells apart tests of the same host and type, see cfg.DNSTestConfig.Variant.
+	Variant string
+	Passed  bool
+	// Duration is the time taken by the query of the test's type, or by the check
+	Duration time.Duration
+	Err      error
+	Expected []string
+	// Comparison is nil when the test failed before the records could be compared
+	Comparison *dns.RecordComparison
 }
 
 func NewDNSTestExecutor(config cfg.DNSRecordsFullTestConfig, client dns.IDNSClient) *DNSTestExecutor {
 	return &DNSTestExecutor{
-		Config:  config,
-		Client:  client,
-		Results: make(map[string]*dns.DNSRecords),
-		Errors:  make(map[string]error),
+		Config:    config,
+		Client:    client,
+		Results:   make(map[string]*dns.DNSRecords),
+		Errors:    make(map[string]error),
+		Durations: make(map[string]time.Duration),
+		// Only used by tests with an EDNS client subnet
+		subnetResults: make(map[string]*dns.DNSRecords),
 	}
 }
 
 // RunAllTests executes all DNS tests defined in the configuration.
 func (e *DNSTestExecutor) RunAllTests() error {
+	ctx, span := tracer.Start(context.Background(), "RunAllTests", trace.WithAttributes(
+		attribute.String("dns.server", e.Config.DNSServer),
+		attribute.Int("sherlock.test_count", len(e.Config.Tests)),
+	))
+	defer span.End()
+
 	var wg sync.WaitGroup
 
 	hostTests := e.groupTestsByHost()
 
-	ui.PrintMsgWithStatus("INFO", "magenta", "Using DNS server: %s\n", e.Config.DNSServer)
+	ui.FprintMsgWithStatus(e.stdout(), "INFO", "magenta", "Using DNS server: %s\n", e.Config.DNSServer)
 	for host := range hostTests {
 		wg.Add(1)
-		go e.queryDNSForHost(host, &wg)
+		go e.queryDNSForHost(ctx, host, &wg)
 	}
 	wg.Wait()
 
 	for host, tests := range hostTests {
-		e.runTestsForHost(host, tests)
+		e.runTestsForHost(ctx, host, tests)
 	}
 
 	if len(e.AllErrors) > 0 {
-		fmt.Printf("\n")
+		span.SetStatus(codes.Error, fmt.Sprintf("%d test failures", len(e.AllErrors)))
+		fmt.Fprintf(e.stdout(), "\n")
 		return fmt.Errorf("test failures:\n%v", e.AllErrors)
 	}
-	fmt.Printf("\n")
+	fmt.Fprintf(e.stdout(), "\n")
 	return nil
 }
 
+// stdout is where progress messages and comparison tables are written.
+func (e *DNSTestExecutor) stdout() io.Writer {
+	if e.Output != nil {
+		return e.Output
+	}
+	return os.Stdout
+}
+
+// stderr is where failures and warnings are written.
+func (e *DNSTestExecutor) stderr() io.Writer {
+	if e.Output != nil {
+		return e.Output
+	}
+	return os.Stderr
+}
+
 // groupTestsByHost groups DNS tests by the host.
 func (e *DNSTestExecutor) groupTestsByHost() map[string][]cfg.DNSTestConfig {
 	hostTests := make(map[string][]cfg.DNSTestConfig)
@@ -62,48 +123,244 @@ func (e *DNSTestExecutor) groupTestsByHost() map[string][]cfg.DNSTestConfig {
 }
 
 // queryDNSForHost queries the DNS for a specific host and stores the result.
-func (e *DNSTestExecutor) queryDNSForHost(host string, wg *sync.WaitGroup) {
+func (e *DNSTestExecutor) queryDNSForHost(ctx context.Context, host string, wg *sync.WaitGroup) {
 	defer wg.Done()
 	defer e.mu.Unlock()
 
-	records, err := dns.QueryDNS(host, e.Config.DNSServer, e.Client)
+	ctx, span := tracer.Start(ctx, "queryDNSForHost", trace.WithAttributes(attribute.String("dns.host", host)))
+	start := time.Now()
+	records, err := dns.QueryDNSContext(ctx, host, e.Config.DNSServer, e.Client, e.hostQueryOptions(host)...)
+	elapsed := time.Since(start)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
 	e.mu.Lock()
 
 	e.Results[host] = records
 	e.Errors[host] = err
+	e.Durations[host] = elapsed
+}
+
+// hostQueryOptions returns the options of the queries for a host. The AD bit is only set when one of the host's
+// tests asserts the ad flag.
+func (e *DNSTestExecutor) hostQueryOptions(host string) []dns.QueryOption {
+	for _, test := range e.Config.Tests {
+		if test.Host != host {
+			continue
+		}
+		for flag := range test.Flags {
+			if strings.EqualFold(flag, "ad") {
+				return []dns.QueryOption{dns.WithAuthenticatedData()}
+			}
+		}
+	}
+	return nil
 }
 
 // runTestsForHost runs all tests for a specific host.
-func (e *DNSTestExecutor) runTestsForHost(host string, tests []cfg.DNSTestConfig) {
-	fmt.Printf("\nRunning tests for host: %s...\n", host)
+func (e *DNSTestExecutor) runTestsForHost(ctx context.Context, host string, tests []cfg.DNSTestConfig) {
+	fmt.Fprintf(e.stdout(), "\nRunning tests for host: %s...\n", host)
 
 	if err, found := e.Errors[host]; found && err != nil {
-		fmt.Printf("Failed to query DNS for host %s: %v\n", host, err)
+		fmt.Fprintf(e.stdout(), "Failed to query DNS for host %s: %v\n", host, err)
 		e.AllErrors = append(e.AllErrors, fmt.Errorf("failed to query DNS for host %s: %w", host, err))
+		for _, test := range tests {
+			e.recordResult(ctx, host, test, nil, e.Durations[host], err)
+		}
 		return
 	}
 
-	records := e.Results[host]
 	for _, test := range tests {
-		ui.PrintDashes()
-		fmt.Printf("Testing '%s' records\n", test.TestType)
+		ui.FprintDashes(e.stdout())
+		fmt.Fprintf(e.stdout(), "Testing '%s' records\n", test.TestType)
+
+		start := time.Now()
+		if comparison, ok := e.runCheck(ctx, host, test); ok {
+			e.reportComparison(ctx, host, test, comparison, time.Since(start))
+			continue
+		}
+
+		records := e.Results[host]
+		if test.ECS != "" {
+			var err error
+			records, err = e.queryWithClientSubnet(ctx, host, test.ECS)
+			if err != nil {
+				fmt.Fprintf(e.stdout(), "Failed to query DNS for host %s with client subnet %s: %v\n", host, test.ECS, err)
+				e.AllErrors = append(e.AllErrors, fmt.Errorf("failed to query DNS for host %s with client subnet %s: %w", host, test.ECS, err))
+				e.recordResult(ctx, host, test, nil, time.Since(start), err)
+				continue
+			}
+		}
+		// Only the query of the test's type counts towards its duration
+		qtype, _ := dns.GetQueryTypeFromString(test.TestType)
+		duration := records.Durations[qtype]
 
-		actualValues, err := dns.ExtractRecords(records, test.TestType)
+		actualValues, err := dns.ExtractComparableRecords(records, test.TestType, test.ExpectedValues, test.RawSegments)
 		if err != nil {
-			fmt.Printf("Error extracting records for test type: %s on host: %s, error: %v\n", test.TestType, host, err)
+			fmt.Fprintf(e.stdout(), "Error extracting records for test type: %s on host: %s, error: %v\n", test.TestType, host, err)
 			e.AllErrors = append(e.AllErrors, fmt.Errorf("failed to extract records for test type %s on host %s: %w", test.TestType, host, err))
+			e.recordResult(ctx, host, test, nil, duration, err)
 			continue
 		}
 
 		if len(actualValues) == 0 {
-			fmt.Printf("No records found for test type: %s on host: %s\n", test.TestType, host)
+			fmt.Fprintf(e.stdout(), "No records found for test type: %s on host: %s\n", test.TestType, host)
 		}
 
-		if err := dns.CompareRecords(test.ExpectedValues, actualValues); err != nil {
-			ui.PrintErrMsgWithStatus("BAD", "red", "Records don't match the configuration\n")
-			e.AllErrors = append(e.AllErrors, fmt.Errorf("DNS check failed for host %s: %v", host, err))
-		} else {
-			ui.PrintMsgWithStatus("GOOD", "green", "All records match the configuration\n")
+		comparison := dns.DiffRecords(test.ExpectedValues, actualValues, dns.CompareOptions{
+			Match:      test.Match,
+			MinCount:   test.MinCount,
+			MaxCount:   test.MaxCount,
+			RecordType: test.TestType,
+			Strict:     test.Strict,
+		})
+		if test.Ordered {
+			if err := dns.CheckMXOrder(test.ExpectedValues, records.MXRecords, test.Strict); err != nil {
+				comparison.Fail(err.Error())
+			}
 		}
+		if test.ECS != "" {
+			noteClientSubnetScope(records, test, comparison)
+		}
+		if len(test.Flags) > 0 {
+			e.checkFlags(records, test, comparison)
+		}
+		if test.DNSSEC {
+			e.validateDNSSEC(ctx, host, test, comparison)
+		}
+		e.reportComparison(ctx, host, test, comparison, duration)
+	}
+}
+
+// runCheck runs tests whose type is a check built on top of DNS rather than a plain record comparison,
+// it returns false when the test is a plain record type.
+func (e *DNSTestExecutor) runCheck(ctx context.Context, host string, test cfg.DNSTestConfig) (*dns.RecordComparison, bool) {
+	switch strings.ToLower(test.TestType) {
+	case "spf":
+		return mail.NewSPFChecker(e.Client, e.Config.DNSServer).Test(ctx, host, test.SenderIP, test.ExpectedValues), true
+	case "dmarc":
+		return mail.NewDMARCChecker(e.Client, e.Config.DNSServer).Test(ctx, host, test.ExpectedValues), true
+	case "dkim":
+		return mail.NewDKIMChecker(e.Client, e.Config.DNSServer).Test(ctx, host, test.Selector, test.ExpectedValues), true
+	case "mta-sts":
+		return mail.NewMTASTSChecker(e.Client, e.Config.DNSServer).Test(ctx, host, test.ExpectedValues), true
+	default:
+		return nil, false
 	}
 }
+
+// queryWithClientSubnet queries the records of a host as seen from the client subnet, each host and subnet is only queried once.
+func (e *DNSTestExecutor) queryWithClientSubnet(ctx context.Context, host string, subnet string) (*dns.DNSRecords, error) {
+	key := host + "|" + subnet
+	if records, ok := e.subnetResults[key]; ok {
+		return records, nil
+	}
+
+	prefix, err := dns.ParseClientSubnet(subnet)
+	if err != nil {
+		return nil, err
+	}
+	opts := append(e.hostQueryOptions(host), dns.WithClientSubnet(prefix))
+	records, err := dns.QueryDNSContext(ctx, host, e.Config.DNSServer, e.Client, opts...)
+	if err != nil {
+		return nil, err
+	}
+	e.subnetResults[key] = records
+	return records, nil
+}
+
+// noteClientSubnetScope adds the scope prefix returned by the server for the test's query type to the comparison.
+func noteClientSubnetScope(records *dns.DNSRecords, test cfg.DNSTestConfig, comparison *dns.RecordComparison) {
+	qtype, err := dns.GetQueryTypeFromString(test.TestType)
+	if err != nil {
+		return
+	}
+	if scope, ok := records.ClientSubnetScopes[qtype]; ok {
+		comparison.Note(fmt.Sprintf("client subnet %s answered with scope /%d", test.ECS, scope))
+	} else {
+		comparison.Note(fmt.Sprintf("client subnet %s sent, the server didn't return a client subnet option", test.ECS))
+	}
+}
+
+// checkFlags asserts the header flags of the response to the test's query type.
+func (e *DNSTestExecutor) checkFlags(records *dns.DNSRecords, test cfg.DNSTestConfig, comparison *dns.RecordComparison) {
+	qtype, err := dns.GetQueryTypeFromString(test.TestType)
+	if err != nil {
+		comparison.Fail(err.Error())
+		return
+	}
+	if err := dns.CheckFlags(test.Flags, records.Flags[qtype]); err != nil {
+		comparison.Fail(err.Error())
+	}
+}
+
+// validateDNSSEC validates the chain of trust of the tested records and adds the outcome to the comparison.
+func (e *DNSTestExecutor) validateDNSSEC(ctx context.Context, host string, test cfg.DNSTestConfig, comparison *dns.RecordComparison) {
+	qtype, err := dns.GetQueryTypeFromString(test.TestType)
+	if err != nil {
+		comparison.Fail(err.Error())
+		return
+	}
+	validator, err := dns.NewDNSSECValidator(e.Client, e.Config.DNSServer, e.Config.DNSSEC.TrustAnchors, e.Config.DNSSEC.ExpiryWarning)
+	if err != nil {
+		comparison.Fail(err.Error())
+		return
+	}
+
+	result, err := validator.Validate(ctx, host, qtype)
+	if err != nil {
+		comparison.Fail(fmt.Sprintf("DNSSEC validation failed: %v", err))
+		return
+	}
+	for _, warning := range result.Warnings {
+		ui.FprintMsgWithStatus(e.stderr(), "WARN", "hiYellow", "%s\n", warning)
+		comparison.Note(warning)
+	}
+	comparison.Note(fmt.Sprintf("DNSSEC chain of trust valid through %s", strings.Join(result.Chain, " -> ")))
+}
+
+// reportComparison prints the outcome of a test and records it.
+func (e *DNSTestExecutor) reportComparison(ctx context.Context, host string, test cfg.DNSTestConfig, comparison *dns.RecordComparison, duration time.Duration) {
+	err := comparison.ReportTo(e.stdout())
+	if err != nil {
+		ui.FprintMsgWithStatus(e.stderr(), "BAD", "red", "Records don't match the configuration\n")
+		e.AllErrors = append(e.AllErrors, fmt.Errorf("DNS check failed for host %s: %v", host, err))
+	} else {
+		ui.FprintMsgWithStatus(e.stdout(), "GOOD", "green", "All records match the configuration\n")
+	}
+	e.recordResult(ctx, host, test, comparison, duration, err)
+}
+
+// recordResult stores the outcome of a test so it can be consumed after the run, and records it as an assertion span.
+func (e *DNSTestExecutor) recordResult(ctx context.Context, host string, test cfg.DNSTestConfig, comparison *dns.RecordComparison, duration time.Duration, err error) {
+	_, span := tracer.Start(ctx, "assert", trace.WithAttributes(
+		attribute.String("dns.host", host),
+		attribute.String("dns.question.type", strings.ToLower(test.TestType)),
+		attribute.StringSlice("sherlock.expected", test.ExpectedValues),
+		attribute.Bool("sherlock.passed", err == nil),
+	))
+	if comparison != nil {
+		span.SetAttributes(
+			attribute.StringSlice("sherlock.missing", comparison.Missing),
+			attribute.StringSlice("sherlock.unexpected", comparison.Unexpected),
+		)
+	}
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+
+	e.TestResults = append(e.TestResults, TestResult{
+		Host:       host,
+		TestType:   strings.ToLower(test.TestType),
+		Server:     e.Config.DNSServer,
+		Variant:    test.Variant(),
+		Passed:     err == nil,
+		Duration:   duration,
+		Err:        err,
+		Expected:   test.ExpectedValues,
+		Comparison: comparison,
+	})
+}
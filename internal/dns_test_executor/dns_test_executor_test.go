@@ -1,32 +1,86 @@
 package dns_test_executor
 
 import (
-	"fmt"
+	"context"
+	"net"
 	"reflect"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
-	"net"
-	"strings"
-
 	cfg "github.com/ch0ppy35/sherlock/internal/config"
 	"github.com/ch0ppy35/sherlock/internal/dns"
 	d "github.com/miekg/dns"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
+// testZoneHeader starts the example.com. zone served to the tests, the records of each test follow it
+const testZoneHeader = `$ORIGIN example.com.
+$TTL 300
+@ IN SOA ns1 hostmaster 1 7200 3600 1209600 300
+`
+
+// startZoneServer serves example.com. with the records on a free local port and returns its address
+func startZoneServer(t *testing.T, records string, options dns.ServerOptions) string {
+	t.Helper()
+	rrs, err := dns.ParseZoneFile(strings.NewReader(testZoneHeader+records), "example.com.", "")
+	if err != nil {
+		t.Fatalf("failed to parse the zone: %v", err)
+	}
+	zone, err := dns.NewZoneSnapshot("example.com.", rrs)
+	if err != nil {
+		t.Fatalf("failed to load the zone: %v", err)
+	}
+
+	server, err := dns.NewServer(dns.NewZoneClient(zone), options)
+	if err != nil {
+		t.Fatalf("failed to create the server: %v", err)
+	}
+	if err := server.Start("127.0.0.1:0"); err != nil {
+		t.Fatalf("failed to start the server: %v", err)
+	}
+	t.Cleanup(func() { _ = server.Shutdown() })
+	return server.Addr
+}
+
+// unreachableServer returns a local address nothing listens on
+func unreachableServer(t *testing.T) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	addr := pc.LocalAddr().String()
+	pc.Close()
+	return addr
+}
+
+// expectedFlags are the header flags of every answer from the test server
+func expectedFlags() map[uint16]dns.ResponseFlags {
+	flags := map[uint16]dns.ResponseFlags{}
+	for _, qtype := range []uint16{d.TypeA, d.TypeAAAA, d.TypeCNAME, d.TypeMX, d.TypeTXT, d.TypeNS} {
+		flags[qtype] = dns.ResponseFlags{Response: true, Authoritative: true, RecursionDesired: true}
+	}
+	return flags
+}
+
 func Test_RunAllTestsInConfig(t *testing.T) {
 	tests := []struct {
 		name          string
 		config        cfg.DNSRecordsFullTestConfig
-		mockResponses map[uint16]*d.Msg
-		mockError     error
+		zone          string
+		options       dns.ServerOptions
+		unreachable   bool
 		expectedError string
 	}{
 		{
 			name: "Valid configuration",
 			config: cfg.DNSRecordsFullTestConfig{
-				DNSServer: "8.8.8.8",
 				Tests: []cfg.DNSTestConfig{
 					{
 						Host:           "example.com",
@@ -35,40 +89,75 @@ func Test_RunAllTestsInConfig(t *testing.T) {
 					},
 				},
 			},
-			mockResponses: map[uint16]*d.Msg{
-				d.TypeA: {
-					Answer: []d.RR{
-						&d.A{Hdr: d.RR_Header{Name: "example.com."}, A: net.ParseIP("10.0.0.1")},
+			zone:          "@ IN A 10.0.0.1\n",
+			expectedError: "",
+		},
+		{
+			name: "CNAME chain",
+			config: cfg.DNSRecordsFullTestConfig{
+				Tests: []cfg.DNSTestConfig{
+					{
+						Host:           "www.example.com",
+						TestType:       "a",
+						ExpectedValues: []string{"10.0.0.1"},
+					},
+					{
+						Host:           "www.example.com",
+						TestType:       "cname",
+						ExpectedValues: []string{"example.com."},
 					},
 				},
 			},
+			zone:          "@ IN A 10.0.0.1\nwww IN CNAME @\n",
 			expectedError: "",
 		},
 		{
-			name: "Configuration with missing records",
+			name: "Header flags match",
 			config: cfg.DNSRecordsFullTestConfig{
-				DNSServer: "8.8.8.8",
 				Tests: []cfg.DNSTestConfig{
 					{
 						Host:           "example.com",
 						TestType:       "a",
-						ExpectedValues: []string{"10.0.0.2"},
+						ExpectedValues: []string{"10.0.0.1"},
+						Flags:          map[string]bool{"aa": true, "tc": false},
 					},
 				},
 			},
-			mockResponses: map[uint16]*d.Msg{
-				d.TypeA: {
-					Answer: []d.RR{
-						&d.A{Hdr: d.RR_Header{Name: "example.com."}, A: net.ParseIP("10.0.0.1")},
+			zone:          "@ IN A 10.0.0.1\n",
+			expectedError: "",
+		},
+		{
+			name: "Header flags mismatch",
+			config: cfg.DNSRecordsFullTestConfig{
+				Tests: []cfg.DNSTestConfig{
+					{
+						Host:           "example.com",
+						TestType:       "a",
+						ExpectedValues: []string{"10.0.0.1"},
+						Flags:          map[string]bool{"ad": true},
 					},
 				},
 			},
+			zone:          "@ IN A 10.0.0.1\n",
+			expectedError: "test failures:\n[DNS check failed for host example.com: mismatched records found]",
+		},
+		{
+			name: "Configuration with missing records",
+			config: cfg.DNSRecordsFullTestConfig{
+				Tests: []cfg.DNSTestConfig{
+					{
+						Host:           "example.com",
+						TestType:       "a",
+						ExpectedValues: []string{"10.0.0.2"},
+					},
+				},
+			},
+			zone:          "@ IN A 10.0.0.1\n",
 			expectedError: "test failures:\n[DNS check failed for host example.com: mismatched records found]",
 		},
 		{
 			name: "Configuration with query error",
 			config: cfg.DNSRecordsFullTestConfig{
-				DNSServer: "8.8.8.8",
 				Tests: []cfg.DNSTestConfig{
 					{
 						Host:           "example.com",
@@ -77,14 +166,75 @@ func Test_RunAllTestsInConfig(t *testing.T) {
 					},
 				},
 			},
-			mockError:     fmt.Errorf("network error"),
-			expectedError: "test failures:\n[failed to query DNS for host example.com: failed to query DNS records: network error]",
+			unreachable:   true,
+			expectedError: "test failures:\n[failed to query DNS for host example.com: failed to query DNS records: ",
+		},
+		{
+			name: "Server failures",
+			config: cfg.DNSRecordsFullTestConfig{
+				Tests: []cfg.DNSTestConfig{
+					{
+						Host:           "example.com",
+						TestType:       "a",
+						ExpectedValues: []string{"10.0.0.1"},
+					},
+				},
+			},
+			zone:          "@ IN A 10.0.0.1\n",
+			options:       dns.ServerOptions{ServFailRate: 1},
+			expectedError: "test failures:\n[DNS check failed for host example.com: mismatched records found]",
+		},
+		{
+			// Truncated answers aren't retried over TCP, so the test sees TC and no records
+			name: "Truncated responses",
+			config: cfg.DNSRecordsFullTestConfig{
+				Tests: []cfg.DNSTestConfig{
+					{
+						Host:     "example.com",
+						TestType: "a",
+						MaxCount: new(int),
+						Flags:    map[string]bool{"tc": true},
+					},
+				},
+			},
+			zone:          "@ IN A 10.0.0.1\n",
+			options:       dns.ServerOptions{Truncate: true},
+			expectedError: "",
+		},
+		{
+			name: "MX records out of priority order",
+			config: cfg.DNSRecordsFullTestConfig{
+				Tests: []cfg.DNSTestConfig{
+					{
+						Host:           "example.com",
+						TestType:       "mx",
+						ExpectedValues: []string{"mail2.example.com.", "mail1.example.com."},
+						Ordered:        true,
+					},
+				},
+			},
+			zone:          "@ IN MX 10 mail1\n@ IN MX 20 mail2\n",
+			expectedError: "test failures:\n[DNS check failed for host example.com: mismatched records found]",
+		},
+		{
+			name: "MX records with preferences",
+			config: cfg.DNSRecordsFullTestConfig{
+				Tests: []cfg.DNSTestConfig{
+					{
+						Host:           "example.com",
+						TestType:       "mx",
+						ExpectedValues: []string{"10 mail1.example.com.", "20 mail2.example.com."},
+						Ordered:        true,
+					},
+				},
+			},
+			zone:          "@ IN MX 10 mail1\n@ IN MX 20 mail2\n",
+			expectedError: "",
 		},
 		{
 			name: "Empty configuration",
 			config: cfg.DNSRecordsFullTestConfig{
-				DNSServer: "8.8.8.8",
-				Tests:     []cfg.DNSTestConfig{},
+				Tests: []cfg.DNSTestConfig{},
 			},
 			expectedError: "",
 		},
@@ -92,18 +242,12 @@ func Test_RunAllTestsInConfig(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			client := &dns.MockIDNSClient{
-				MockExchange: func(msg *d.Msg, server string) (*d.Msg, time.Duration, error) {
-					if tt.mockError != nil {
-						return nil, 0, tt.mockError
-					}
-					if resp, ok := tt.mockResponses[msg.Question[0].Qtype]; ok {
-						return resp, 0, nil
-					}
-					return &d.Msg{}, 0, nil
-				},
+			if tt.unreachable {
+				tt.config.DNSServer = unreachableServer(t)
+			} else {
+				tt.config.DNSServer = startZoneServer(t, tt.zone, tt.options)
 			}
-			executor := NewDNSTestExecutor(tt.config, client)
+			executor := NewDNSTestExecutor(tt.config, new(d.Client))
 			err := executor.RunAllTests()
 
 			if (err != nil && tt.expectedError == "") || (err == nil && tt.expectedError != "") {
@@ -122,66 +266,60 @@ func Test_queryDNSForHost(t *testing.T) {
 	tests := []struct {
 		name          string
 		host          string
-		server        string
-		mockResponses map[uint16]*d.Msg
-		mockError     error
+		zone          string
+		unreachable   bool
 		expected      *dns.DNSRecords
 		expectedError string
 	}{
 		{
-			name:   "Valid A record query",
-			host:   "example.com",
-			server: "8.8.8.8",
-			mockResponses: map[uint16]*d.Msg{
-				d.TypeA: {
-					Answer: []d.RR{
-						&d.A{Hdr: d.RR_Header{Name: "example.com."}, A: net.ParseIP("10.0.0.1")},
-					},
-				},
-			},
+			name: "Valid A record query",
+			host: "example.com",
+			zone: "@ IN A 10.0.0.1\n",
 			expected: &dns.DNSRecords{
 				ARecords: []string{"10.0.0.1"},
 			},
 		},
 		{
-			name:   "Query returns no answers",
-			host:   "example.com",
-			server: "8.8.8.8",
-			mockResponses: map[uint16]*d.Msg{
-				d.TypeA: {},
-			},
+			name:     "Query returns no answers",
+			host:     "missing.example.com",
+			zone:     "@ IN A 10.0.0.1\n",
 			expected: &dns.DNSRecords{},
 		},
 		{
 			name:          "Query returns an error",
 			host:          "example.com",
-			server:        "8.8.8.8",
-			mockError:     fmt.Errorf("network error"),
-			expectedError: "network error",
+			unreachable:   true,
+			expectedError: "failed to query DNS records",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			client := &dns.MockIDNSClient{
-				MockExchange: func(msg *d.Msg, server string) (*d.Msg, time.Duration, error) {
-					if tt.mockError != nil {
-						return nil, 0, tt.mockError
-					}
-					if resp, ok := tt.mockResponses[msg.Question[0].Qtype]; ok {
-						return resp, 0, nil
-					}
-					return &d.Msg{}, 0, nil
-				},
+			config := cfg.DNSRecordsFullTestConfig{}
+			if tt.unreachable {
+				config.DNSServer = unreachableServer(t)
+			} else {
+				config.DNSServer = startZoneServer(t, tt.zone, dns.ServerOptions{})
 			}
 
-			executor := NewDNSTestExecutor(cfg.DNSRecordsFullTestConfig{}, client)
+			executor := NewDNSTestExecutor(config, new(d.Client))
 			var wg sync.WaitGroup
 
 			wg.Add(1)
-			executor.queryDNSForHost(tt.host, &wg)
+			executor.queryDNSForHost(context.Background(), tt.host, &wg)
 			wg.Wait()
 
+			if tt.expected != nil {
+				tt.expected.Flags = expectedFlags()
+			}
+			// Durations vary between runs, only their presence is checked
+			if records := executor.Results[tt.host]; records != nil {
+				if len(records.Durations) != len(records.Flags) {
+					t.Errorf("queryDNSForHost() durations = %v, expected one per query type", records.Durations)
+				}
+				records.Durations = nil
+			}
+
 			if !reflect.DeepEqual(executor.Results[tt.host], tt.expected) {
 				t.Errorf("queryDNSForHost() records = %v, expected %v", executor.Results[tt.host], tt.expected)
 			}
@@ -196,3 +334,189 @@ func Test_queryDNSForHost(t *testing.T) {
 		})
 	}
 }
+
+func Test_TestResults(t *testing.T) {
+	server := startZoneServer(t, "@ IN A 10.0.0.1\n", dns.ServerOptions{})
+	config := cfg.DNSRecordsFullTestConfig{
+		DNSServer: server,
+		Tests: []cfg.DNSTestConfig{
+			{Host: "example.com", TestType: "A", ExpectedValues: []string{"10.0.0.1"}},
+			{Host: "example.com", TestType: "ns", ExpectedValues: []string{"ns1.example.com."}},
+		},
+	}
+
+	executor := NewDNSTestExecutor(config, new(d.Client))
+	_ = executor.RunAllTests()
+
+	expected := []TestResult{
+		{Host: "example.com", TestType: "a", Server: server, Passed: true},
+		{Host: "example.com", TestType: "ns", Server: server, Passed: false},
+	}
+	if len(executor.TestResults) != len(expected) {
+		t.Fatalf("TestResults = %+v, expected %+v", executor.TestResults, expected)
+	}
+	for i, want := range expected {
+		got := executor.TestResults[i]
+		if got.Host != want.Host || got.TestType != want.TestType || got.Server != want.Server || got.Passed != want.Passed {
+			t.Errorf("TestResults[%d] = %+v, expected %+v", i, got, want)
+		}
+	}
+}
+
+func Test_hostQueryOptions(t *testing.T) {
+	config := cfg.DNSRecordsFullTestConfig{
+		Tests: []cfg.DNSTestConfig{
+			{Host: "example.com", TestType: "a", ExpectedValues: []string{"10.0.0.1"}, Flags: map[string]bool{"aa": true}},
+			{Host: "signed.example.com", TestType: "a", ExpectedValues: []string{"10.0.0.1"}},
+			{Host: "signed.example.com", TestType: "aaaa", ExpectedValues: []string{"2001:db8::1"}, Flags: map[string]bool{"AD": true}},
+		},
+	}
+	executor := NewDNSTestExecutor(config, new(d.Client))
+
+	tests := []struct {
+		host     string
+		expected bool
+	}{
+		{host: "example.com", expected: false},
+		{host: "signed.example.com", expected: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			msg := new(d.Msg)
+			for _, opt := range executor.hostQueryOptions(tt.host) {
+				opt(msg)
+			}
+			if msg.AuthenticatedData != tt.expected {
+				t.Errorf("hostQueryOptions() AD bit = %v, expected %v", msg.AuthenticatedData, tt.expected)
+			}
+		})
+	}
+}
+
+func Test_RunAllTestsClientSubnet(t *testing.T) {
+	// The zone server doesn't tailor answers to the client subnet, this one answers 203.0.113.0/24 differently
+	var queries atomic.Int32
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	started := make(chan struct{})
+	server := &d.Server{
+		PacketConn:        pc,
+		NotifyStartedFunc: func() { close(started) },
+		Handler: d.HandlerFunc(func(w d.ResponseWriter, msg *d.Msg) {
+			queries.Add(1)
+			resp := new(d.Msg)
+			resp.SetReply(msg)
+			if msg.Question[0].Qtype != d.TypeA {
+				_ = w.WriteMsg(resp)
+				return
+			}
+
+			answer := "10.0.0.1"
+			if opt := msg.IsEdns0(); opt != nil {
+				subnet := opt.Option[0].(*d.EDNS0_SUBNET)
+				if subnet.Address.Equal(net.ParseIP("203.0.113.0")) {
+					answer = "10.1.0.1"
+				}
+				scoped := *subnet
+				scoped.SourceScope = 24
+				resp.SetEdns0(4096, false)
+				resp.IsEdns0().Option = append(resp.IsEdns0().Option, &scoped)
+			}
+			resp.Answer = []d.RR{&d.A{Hdr: d.RR_Header{Name: "example.com.", Rrtype: d.TypeA, Class: d.ClassINET, Ttl: 300}, A: net.ParseIP(answer)}}
+			_ = w.WriteMsg(resp)
+		}),
+	}
+	go func() { _ = server.ActivateAndServe() }()
+	<-started
+	t.Cleanup(func() { _ = server.Shutdown() })
+
+	config := cfg.DNSRecordsFullTestConfig{
+		DNSServer: pc.LocalAddr().String(),
+		Tests: []cfg.DNSTestConfig{
+			{Host: "example.com", TestType: "a", ExpectedValues: []string{"10.0.0.1"}},
+			{Host: "example.com", TestType: "a", ExpectedValues: []string{"10.1.0.1"}, ECS: "203.0.113.0/24"},
+			{Host: "example.com", TestType: "a", ExpectedValues: []string{"10.1.0.1"}, ECS: "198.51.100.0/24"},
+		},
+	}
+
+	executor := NewDNSTestExecutor(config, new(d.Client))
+	_ = executor.RunAllTests()
+
+	passed := []bool{true, true, false}
+	if len(executor.TestResults) != len(passed) {
+		t.Fatalf("TestResults = %+v, expected %d results", executor.TestResults, len(passed))
+	}
+	for i, want := range passed {
+		if executor.TestResults[i].Passed != want {
+			t.Errorf("TestResults[%d].Passed = %v, expected %v", i, executor.TestResults[i].Passed, want)
+		}
+	}
+	if reasons := executor.TestResults[1].Comparison.Reasons; !strings.Contains(strings.Join(reasons, "; "), "scope /24") {
+		t.Errorf("expected the scope to be noted, got %v", reasons)
+	}
+	// One set of queries for the host, plus one for each client subnet
+	if got := queries.Load(); got != 18 {
+		t.Errorf("expected 18 queries, got %d", got)
+	}
+}
+
+func Test_RunAllTestsTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	original := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(original)
+
+	config := cfg.DNSRecordsFullTestConfig{
+		DNSServer: startZoneServer(t, "@ IN A 10.0.0.1\n", dns.ServerOptions{Latency: 2 * time.Millisecond}),
+		Tests: []cfg.DNSTestConfig{
+			{Host: "example.com", TestType: "a", ExpectedValues: []string{"10.0.0.1"}},
+		},
+	}
+
+	executor := NewDNSTestExecutor(config, new(d.Client))
+	if err := executor.RunAllTests(); err != nil {
+		t.Fatalf("RunAllTests() unexpected error = %v", err)
+	}
+
+	counts := map[string]int{}
+	var root, host sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		counts[span.Name()]++
+		switch span.Name() {
+		case "RunAllTests":
+			root = span
+		case "queryDNSForHost":
+			host = span
+		}
+	}
+
+	expected := map[string]int{"RunAllTests": 1, "queryDNSForHost": 1, "dns.exchange": 6, "assert": 1}
+	if !reflect.DeepEqual(counts, expected) {
+		t.Fatalf("RunAllTests() spans = %v, expected %v", counts, expected)
+	}
+
+	for _, span := range recorder.Ended() {
+		switch span.Name() {
+		case "queryDNSForHost", "assert":
+			if span.Parent().SpanID() != root.SpanContext().SpanID() {
+				t.Errorf("%s span is not a child of RunAllTests", span.Name())
+			}
+		case "dns.exchange":
+			if span.Parent().SpanID() != host.SpanContext().SpanID() {
+				t.Errorf("dns.exchange span is not a child of queryDNSForHost")
+			}
+			attrs := map[string]string{}
+			for _, attr := range span.Attributes() {
+				attrs[string(attr.Key)] = attr.Value.Emit()
+			}
+			// The server delays every response by 2ms
+			rtt, _ := strconv.ParseFloat(attrs["dns.rtt_ms"], 64)
+			if attrs["dns.question.type"] == "A" && (attrs["dns.response.answer_count"] != "1" || rtt < 2 || attrs["dns.response.rcode"] != "NOERROR") {
+				t.Errorf("dns.exchange span attributes = %v", attrs)
+			}
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package mail
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ch0ppy35/sherlock/internal/dns"
+)
+
+// MinDKIMRSABits is the smallest RSA key verifiers must accept, per RFC 8301
+const MinDKIMRSABits = 1024
+
+// DKIMKey is a parsed DKIM public key record
+type DKIMKey struct {
+	Record string
+	// Tags holds every tag of the record except the key itself, with the defaults filled in
+	Tags    map[string]string
+	KeyType string
+	Bits    int
+	// Revoked is set when the record has an empty key
+	Revoked bool
+}
+
+// Values returns the tags as sorted tag=value strings along with bits=<key size>
+func (k *DKIMKey) Values() []string {
+	values := []string{"bits=" + strconv.Itoa(k.Bits)}
+	for tag, value := range k.Tags {
+		values = append(values, tag+"="+value)
+	}
+	sort.Strings(values)
+	return values
+}
+
+// ParseDKIMKey parses a DKIM key record and validates the key it carries
+func ParseDKIMKey(record string) (*DKIMKey, error) {
+	tags, err := parseTagList(record)
+	if err != nil {
+		return nil, err
+	}
+	if v, ok := tags["v"]; ok && v != "DKIM1" {
+		return nil, fmt.Errorf("invalid v=%s, must be DKIM1", v)
+	}
+	p, ok := tags["p"]
+	if !ok {
+		return nil, fmt.Errorf("DKIM record is missing the required p tag")
+	}
+	delete(tags, "p")
+	delete(tags, "n")
+
+	if _, ok := tags["k"]; !ok {
+		tags["k"] = "rsa"
+	}
+	tags["k"] = strings.ToLower(tags["k"])
+	if _, ok := tags["s"]; !ok {
+		tags["s"] = "*"
+	}
+	key := &DKIMKey{Record: record, Tags: tags, KeyType: tags["k"]}
+
+	p = strings.Join(strings.Fields(p), "")
+	if p == "" {
+		key.Revoked = true
+		return key, nil
+	}
+	der, err := base64.StdEncoding.DecodeString(p)
+	if err != nil {
+		return nil, fmt.Errorf("the p tag isn't valid base64: %w", err)
+	}
+
+	switch key.KeyType {
+	case "rsa":
+		pub, err := parseRSAPublicKey(der)
+		if err != nil {
+			return nil, err
+		}
+		key.Bits = pub.N.BitLen()
+	case "ed25519":
+		// RFC 8463 keys are the raw 32 byte public key rather than a SubjectPublicKeyInfo
+		if len(der) != 32 {
+			return nil, fmt.Errorf("ed25519 key must be 32 bytes, got %d", len(der))
+		}
+		key.Bits = 256
+	default:
+		return nil, fmt.Errorf("unsupported key type k=%s, supported types: rsa, ed25519", key.KeyType)
+	}
+	return key, nil
+}
+
+// parseRSAPublicKey parses an RSA key as a SubjectPublicKeyInfo, falling back to the PKCS#1 form some signers publish
+func parseRSAPublicKey(der []byte) (*rsa.PublicKey, error) {
+	if pub, err := x509.ParsePKIXPublicKey(der); err == nil {
+		rsaPub, ok := pub.(*rsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("k=rsa but the p tag holds a %T", pub)
+		}
+		return rsaPub, nil
+	}
+	pub, err := x509.ParsePKCS1PublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("the p tag isn't a valid RSA public key: %w", err)
+	}
+	return pub, nil
+}
+
+// DKIMChecker looks up and validates DKIM keys
+type DKIMChecker struct {
+	Client dns.IDNSClient
+	Server string
+}
+
+func NewDKIMChecker(client dns.IDNSClient, dnsServer string) *DKIMChecker {
+	return &DKIMChecker{Client: client, Server: dns.ServerAddress(dnsServer)}
+}
+
+// Lookup fetches and parses the key published at <selector>._domainkey.<domain>
+func (c *DKIMChecker) Lookup(ctx context.Context, domain string, selector string) (*DKIMKey, error) {
+	name := selector + "._domainkey." + strings.TrimSuffix(domain, ".")
+	txts, err := lookupTXT(ctx, c.Client, c.Server, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query TXT records for %s: %w", name, err)
+	}
+
+	// The version tag is optional for DKIM keys, so any record with a key is a candidate
+	var records []string
+	for _, txt := range txts {
+		if tags, err := parseTagList(txt); err == nil {
+			if _, ok := tags["p"]; ok {
+				records = append(records, txt)
+			}
+		}
+	}
+
+	switch len(records) {
+	case 0:
+		return nil, fmt.Errorf("no DKIM key found at %s", name)
+	case 1:
+		return ParseDKIMKey(records[0])
+	default:
+		return nil, fmt.Errorf("%d DKIM keys found at %s, there must be exactly one", len(records), name)
+	}
+}
+
+// Test runs a dkim test: the selector must publish a single valid, unrevoked key carrying every expected tag=value
+func (c *DKIMChecker) Test(ctx context.Context, domain string, selector string, expected []string) *dns.RecordComparison {
+	key, err := c.Lookup(ctx, domain, selector)
+	if err != nil {
+		comparison := dns.NewRecordComparison()
+		comparison.Fail(err.Error())
+		return comparison
+	}
+
+	comparison := dns.DiffRecords(normalizeTagAssertions(expected), key.Values(), dns.CompareOptions{Match: dns.MatchContains})
+	switch {
+	case key.Revoked:
+		comparison.Fail(fmt.Sprintf("the key for selector %s has been revoked", selector))
+	case key.KeyType == "rsa" && key.Bits < MinDKIMRSABits:
+		comparison.Fail(fmt.Sprintf("%d bit RSA key is below the minimum of %d bits", key.Bits, MinDKIMRSABits))
+	default:
+		comparison.Note(fmt.Sprintf("%s key, %d bits", key.KeyType, key.Bits))
+	}
+	return comparison
+}
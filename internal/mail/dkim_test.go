@@ -0,0 +1,138 @@
+package mail
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// rsa512Key is a 512 bit RSA public key, too small for crypto/rsa to generate
+const rsa512Key = "MFwwDQYJKoZIhvcNAQEBBQADSwAwSAJBAMUwJSi6lZ37eJgpb7D3j28Qi3ZoP9ljT0Kg9BpJd9s3a+yBWF0Sb5iGH+EA7jXVGTrOvongsRk2fFVolav82ZUCAwEAAQ=="
+
+func testDKIMKeys(t *testing.T) (string, string, string) {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+	spki, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to marshal RSA key: %v", err)
+	}
+	edKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ed25519 key: %v", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(spki),
+		base64.StdEncoding.EncodeToString(x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey)),
+		base64.StdEncoding.EncodeToString(edKey)
+}
+
+func TestParseDKIMKey(t *testing.T) {
+	spki, pkcs1, ed := testDKIMKeys(t)
+
+	tests := []struct {
+		name        string
+		record      string
+		expected    []string
+		revoked     bool
+		expectError bool
+	}{
+		{
+			name:     "RSA key",
+			record:   "v=DKIM1; k=rsa; p=" + spki,
+			expected: []string{"bits=2048", "k=rsa", "s=*", "v=DKIM1"},
+		},
+		{
+			name:     "RSA key type defaults and flags",
+			record:   "h=sha256; t=y; p=" + spki[:40] + " " + spki[40:],
+			expected: []string{"bits=2048", "h=sha256", "k=rsa", "s=*", "t=y"},
+		},
+		{
+			name:     "PKCS1 RSA key",
+			record:   "v=DKIM1; p=" + pkcs1,
+			expected: []string{"bits=2048", "k=rsa", "s=*", "v=DKIM1"},
+		},
+		{
+			name:     "ed25519 key",
+			record:   "v=DKIM1; k=ed25519; p=" + ed,
+			expected: []string{"bits=256", "k=ed25519", "s=*", "v=DKIM1"},
+		},
+		{
+			name:     "Revoked key",
+			record:   "v=DKIM1; p=",
+			expected: []string{"bits=0", "k=rsa", "s=*", "v=DKIM1"},
+			revoked:  true,
+		},
+		{name: "Missing key", record: "v=DKIM1; k=rsa", expectError: true},
+		{name: "Invalid version", record: "v=DKIM2; p=" + spki, expectError: true},
+		{name: "Invalid base64", record: "v=DKIM1; p=not*base64", expectError: true},
+		{name: "Key type mismatch", record: "v=DKIM1; k=ed25519; p=" + spki, expectError: true},
+		{name: "Not an RSA key", record: "v=DKIM1; k=rsa; p=" + ed, expectError: true},
+		{name: "Unsupported key type", record: "v=DKIM1; k=dsa; p=" + spki, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := ParseDKIMKey(tt.record)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("expected an error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("didn't expect an error but got %v", err)
+			}
+			if key.Revoked != tt.revoked {
+				t.Errorf("Revoked = %v, want %v", key.Revoked, tt.revoked)
+			}
+			if !reflect.DeepEqual(key.Values(), tt.expected) {
+				t.Errorf("Values() = %v, want %v", key.Values(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestDKIMTest(t *testing.T) {
+	spki, _, _ := testDKIMKeys(t)
+	client := zoneClient(t,
+		fmt.Sprintf(`s1._domainkey.example.com. TXT "v=DKIM1; k=rsa; " "p=%s" "%s"`, spki[:200], spki[200:]),
+		`old._domainkey.example.com. TXT "v=DKIM1; p="`,
+		fmt.Sprintf(`weak._domainkey.example.com. TXT "v=DKIM1; p=%s"`, rsa512Key),
+		`bad._domainkey.example.com. TXT "v=DKIM1; p=nope!"`,
+	)
+
+	tests := []struct {
+		name       string
+		selector   string
+		expected   []string
+		wantPassed bool
+	}{
+		{name: "Valid key", selector: "s1", wantPassed: true},
+		{name: "Matching assertions", selector: "s1", expected: []string{"k=rsa", "bits=2048"}, wantPassed: true},
+		{name: "Mixed case tag names", selector: "s1", expected: []string{"K=rsa", "Bits=2048"}, wantPassed: true},
+		{name: "Mismatched key size", selector: "s1", expected: []string{"bits=4096"}, wantPassed: false},
+		{name: "Revoked key", selector: "old", wantPassed: false},
+		{name: "Key too small", selector: "weak", wantPassed: false},
+		{name: "Invalid key", selector: "bad", wantPassed: false},
+		{name: "Missing selector", selector: "missing", wantPassed: false},
+	}
+
+	checker := NewDKIMChecker(client, "8.8.8.8")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comparison := checker.Test(context.Background(), "example.com", tt.selector, tt.expected)
+			if comparison.Passed != tt.wantPassed {
+				t.Errorf("Test() passed = %v, want %v (reasons: %v)", comparison.Passed, tt.wantPassed, comparison.Reasons)
+			}
+		})
+	}
+}
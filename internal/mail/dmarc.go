@@ -0,0 +1,149 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ch0ppy35/sherlock/internal/dns"
+)
+
+// dmarcDefaults are the values of the optional DMARC tags when they're left out of the record
+var dmarcDefaults = map[string]string{
+	"adkim": "r",
+	"aspf":  "r",
+	"fo":    "0",
+	"pct":   "100",
+	"rf":    "afrf",
+	"ri":    "86400",
+}
+
+// DMARCRecord is a parsed DMARC policy
+type DMARCRecord struct {
+	Record string
+	// Tags holds every tag of the record with the defaults filled in, sp defaults to p
+	Tags map[string]string
+}
+
+// Values returns the tags as sorted tag=value strings, rua and ruf get one value per URI
+func (r *DMARCRecord) Values() []string {
+	values := []string{}
+	for tag, value := range r.Tags {
+		if tag == "rua" || tag == "ruf" {
+			for _, uri := range strings.Split(value, ",") {
+				values = append(values, tag+"="+strings.TrimSpace(uri))
+			}
+			continue
+		}
+		values = append(values, tag+"="+value)
+	}
+	sort.Strings(values)
+	return values
+}
+
+// ParseDMARCRecord parses and validates a DMARC record
+func ParseDMARCRecord(record string) (*DMARCRecord, error) {
+	if len(findVersionedRecords([]string{record}, "DMARC1")) == 0 {
+		return nil, fmt.Errorf("DMARC record must start with v=DMARC1")
+	}
+	tags, err := parseTagList(record)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tag := range []string{"p", "sp", "adkim", "aspf"} {
+		if value, ok := tags[tag]; ok {
+			tags[tag] = strings.ToLower(value)
+		}
+	}
+
+	p, ok := tags["p"]
+	if !ok {
+		return nil, fmt.Errorf("DMARC record is missing the required p tag")
+	}
+	for _, tag := range []string{"p", "sp"} {
+		if value, ok := tags[tag]; ok && value != "none" && value != "quarantine" && value != "reject" {
+			return nil, fmt.Errorf("invalid %s=%s, must be one of none, quarantine, reject", tag, value)
+		}
+	}
+	for _, tag := range []string{"adkim", "aspf"} {
+		if value, ok := tags[tag]; ok && value != "r" && value != "s" {
+			return nil, fmt.Errorf("invalid %s=%s, must be r or s", tag, value)
+		}
+	}
+	if value, ok := tags["pct"]; ok {
+		pct, err := strconv.Atoi(value)
+		if err != nil || pct < 0 || pct > 100 {
+			return nil, fmt.Errorf("invalid pct=%s, must be between 0 and 100", value)
+		}
+	}
+	if value, ok := tags["ri"]; ok {
+		if _, err := strconv.ParseUint(value, 10, 32); err != nil {
+			return nil, fmt.Errorf("invalid ri=%s, must be a number of seconds", value)
+		}
+	}
+	for _, tag := range []string{"rua", "ruf"} {
+		value, ok := tags[tag]
+		if !ok {
+			continue
+		}
+		for _, uri := range strings.Split(value, ",") {
+			if !strings.HasPrefix(strings.ToLower(strings.TrimSpace(uri)), "mailto:") {
+				return nil, fmt.Errorf("invalid %s URI '%s', only mailto: URIs are supported", tag, strings.TrimSpace(uri))
+			}
+		}
+	}
+
+	for tag, value := range dmarcDefaults {
+		if _, ok := tags[tag]; !ok {
+			tags[tag] = value
+		}
+	}
+	if _, ok := tags["sp"]; !ok {
+		tags["sp"] = p
+	}
+	return &DMARCRecord{Record: record, Tags: tags}, nil
+}
+
+// DMARCChecker looks up and validates the DMARC policies of domains
+type DMARCChecker struct {
+	Client dns.IDNSClient
+	Server string
+}
+
+func NewDMARCChecker(client dns.IDNSClient, dnsServer string) *DMARCChecker {
+	return &DMARCChecker{Client: client, Server: dns.ServerAddress(dnsServer)}
+}
+
+// Lookup fetches and parses the DMARC record published at _dmarc.<domain>
+func (c *DMARCChecker) Lookup(ctx context.Context, domain string) (*DMARCRecord, error) {
+	name := "_dmarc." + strings.TrimSuffix(domain, ".")
+	txts, err := lookupTXT(ctx, c.Client, c.Server, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query TXT records for %s: %w", name, err)
+	}
+
+	records := findVersionedRecords(txts, "DMARC1")
+	switch len(records) {
+	case 0:
+		return nil, fmt.Errorf("no DMARC record found at %s", name)
+	case 1:
+		return ParseDMARCRecord(records[0])
+	default:
+		return nil, fmt.Errorf("%d DMARC records found at %s, there must be exactly one", len(records), name)
+	}
+}
+
+// Test runs a dmarc test: the domain must have a single valid DMARC record carrying every expected tag=value
+func (c *DMARCChecker) Test(ctx context.Context, domain string, expected []string) *dns.RecordComparison {
+	record, err := c.Lookup(ctx, domain)
+	if err != nil {
+		comparison := dns.NewRecordComparison()
+		comparison.Fail(err.Error())
+		return comparison
+	}
+	expected = normalizeTagAssertions(expected, "p", "sp", "adkim", "aspf")
+	return dns.DiffRecords(expected, record.Values(), dns.CompareOptions{Match: dns.MatchContains})
+}
@@ -0,0 +1,96 @@
+package mail
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestParseDMARCRecord(t *testing.T) {
+	tests := []struct {
+		name        string
+		record      string
+		expected    []string
+		expectError bool
+	}{
+		{
+			name:   "Minimal record gets defaults",
+			record: "v=DMARC1; p=reject",
+			expected: []string{
+				"adkim=r", "aspf=r", "fo=0", "p=reject", "pct=100", "rf=afrf", "ri=86400", "sp=reject", "v=DMARC1",
+			},
+		},
+		{
+			name:   "Tags in any order with multiple report URIs",
+			record: "v=DMARC1;rua=mailto:a@example.com,mailto:b@example.net;  ADKIM=S; pct=50; sp=none; p=Quarantine",
+			expected: []string{
+				"adkim=s", "aspf=r", "fo=0", "p=quarantine", "pct=50", "rf=afrf", "ri=86400",
+				"rua=mailto:a@example.com", "rua=mailto:b@example.net", "sp=none", "v=DMARC1",
+			},
+		},
+		{name: "Missing version", record: "p=reject; v=DMARC1", expectError: true},
+		{name: "Missing policy", record: "v=DMARC1; rua=mailto:a@example.com", expectError: true},
+		{name: "Invalid policy", record: "v=DMARC1; p=block", expectError: true},
+		{name: "Invalid alignment", record: "v=DMARC1; p=none; aspf=x", expectError: true},
+		{name: "Invalid pct", record: "v=DMARC1; p=none; pct=150", expectError: true},
+		{name: "Non mailto report URI", record: "v=DMARC1; p=none; rua=https://example.com", expectError: true},
+		{name: "Duplicate tag", record: "v=DMARC1; p=none; p=reject", expectError: true},
+		{name: "Malformed tag", record: "v=DMARC1; p=none; reject", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record, err := ParseDMARCRecord(tt.record)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("expected an error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("didn't expect an error but got %v", err)
+			}
+			if !reflect.DeepEqual(record.Values(), tt.expected) {
+				t.Errorf("Values() = %v, want %v", record.Values(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestDMARCTest(t *testing.T) {
+	client := zoneClient(t,
+		`_dmarc.example.com. TXT "v=DMARC1; p=reject; rua=mailto:dmarc@example.com; adkim=s"`,
+		`_dmarc.example.com. TXT "some other record"`,
+		`_dmarc.dupe.com. TXT "v=DMARC1; p=none"`,
+		`_dmarc.dupe.com. TXT "v=DMARC1; p=reject"`,
+		`_dmarc.invalid.com. TXT "v=DMARC1; p=maybe"`,
+		`_dmarc.upper.com. TXT "v=DMARC1; P=Quarantine; SP=REJECT; ASPF=S"`,
+	)
+
+	tests := []struct {
+		name       string
+		domain     string
+		expected   []string
+		wantPassed bool
+	}{
+		{name: "Valid record without assertions", domain: "example.com", wantPassed: true},
+		{name: "Matching assertions", domain: "example.com", expected: []string{"p=reject", "adkim=s", "pct=100", "rua=mailto:dmarc@example.com"}, wantPassed: true},
+		{name: "Mixed case assertions", domain: "example.com", expected: []string{"P=Reject", "ADKIM=S", " pct = 100"}, wantPassed: true},
+		{name: "Mixed case record", domain: "upper.com", expected: []string{"p=quarantine", "sp=reject", "aspf=s"}, wantPassed: true},
+		{name: "Pattern assertion", domain: "example.com", expected: []string{"glob:rua=mailto:*@example.com"}, wantPassed: true},
+		{name: "Mismatched policy", domain: "example.com", expected: []string{"p=quarantine"}, wantPassed: false},
+		{name: "No record", domain: "missing.com", wantPassed: false},
+		{name: "Duplicate records", domain: "dupe.com", wantPassed: false},
+		{name: "Invalid record", domain: "invalid.com", wantPassed: false},
+	}
+
+	checker := NewDMARCChecker(client, "8.8.8.8")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comparison := checker.Test(context.Background(), tt.domain, tt.expected)
+			if comparison.Passed != tt.wantPassed {
+				t.Errorf("Test() passed = %v, want %v (reasons: %v)", comparison.Passed, tt.wantPassed, comparison.Reasons)
+			}
+		})
+	}
+}
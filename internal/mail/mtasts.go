@@ -0,0 +1,361 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ch0ppy35/sherlock/internal/dns"
+	d "github.com/miekg/dns"
+)
+
+// maxMTASTSPolicySize limits how much of a policy is read, RFC 8461 suggests 64KiB
+const maxMTASTSPolicySize = 64 * 1024
+
+// maxMTASTSMaxAge is the largest max_age allowed by RFC 8461, about a year
+const maxMTASTSMaxAge = 31557600
+
+var mtaSTSIDPattern = regexp.MustCompile(`^[a-zA-Z0-9]{1,32}$`)
+
+// MTASTSPolicy is a parsed MTA-STS policy file
+type MTASTSPolicy struct {
+	Version string
+	Mode    string
+	MX      []string
+	MaxAge  int
+}
+
+// Covers reports whether one of the policy's mx patterns matches the host, a leading *. matches exactly one label
+func (p *MTASTSPolicy) Covers(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, pattern := range p.MX {
+		pattern = strings.ToLower(strings.TrimSuffix(pattern, "."))
+		if suffix, wildcard := strings.CutPrefix(pattern, "*."); wildcard {
+			label, rest, found := strings.Cut(host, ".")
+			if found && label != "" && rest == suffix {
+				return true
+			}
+			continue
+		}
+		if host == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseMTASTSPolicy parses and validates the body of a policy file
+func ParseMTASTSPolicy(body string) (*MTASTSPolicy, error) {
+	policy := &MTASTSPolicy{}
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			return nil, fmt.Errorf("invalid policy line '%s', expected key: value", line)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if key != "mx" && seen[key] {
+			return nil, fmt.Errorf("duplicate policy field '%s'", key)
+		}
+		seen[key] = true
+
+		switch key {
+		case "version":
+			policy.Version = value
+		case "mode":
+			policy.Mode = value
+		case "mx":
+			policy.MX = append(policy.MX, value)
+		case "max_age":
+			maxAge, err := strconv.Atoi(value)
+			if err != nil || maxAge < 0 || maxAge > maxMTASTSMaxAge {
+				return nil, fmt.Errorf("invalid max_age '%s', must be between 0 and %d", value, maxMTASTSMaxAge)
+			}
+			policy.MaxAge = maxAge
+		default:
+			// Unknown fields must be ignored
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if policy.Version != "STSv1" {
+		return nil, fmt.Errorf("policy version must be STSv1, got '%s'", policy.Version)
+	}
+	switch policy.Mode {
+	case "enforce", "testing":
+		if len(policy.MX) == 0 {
+			return nil, fmt.Errorf("policy in %s mode must list at least one mx", policy.Mode)
+		}
+	case "none":
+	default:
+		return nil, fmt.Errorf("invalid policy mode '%s', must be one of enforce, testing, none", policy.Mode)
+	}
+	if !seen["max_age"] {
+		return nil, fmt.Errorf("policy is missing the required max_age field")
+	}
+	return policy, nil
+}
+
+// MTASTSReport is the outcome of checking a domain's MTA-STS and TLS-RPT setup
+type MTASTSReport struct {
+	ID     string
+	Policy *MTASTSPolicy
+	// TLSRPT holds the report URIs of the TLS-RPT record
+	TLSRPT []string
+	// Uncovered lists the MX hosts not matched by the policy, it's always empty in none mode
+	Uncovered []string
+}
+
+// Values returns the checked settings as sorted key=value strings, one per mx pattern and report URI
+func (r *MTASTSReport) Values() []string {
+	values := []string{
+		"id=" + r.ID,
+		"mode=" + r.Policy.Mode,
+		"max_age=" + strconv.Itoa(r.Policy.MaxAge),
+	}
+	for _, mx := range r.Policy.MX {
+		values = append(values, "mx="+mx)
+	}
+	for _, uri := range r.TLSRPT {
+		values = append(values, "rua="+uri)
+	}
+	sort.Strings(values)
+	return values
+}
+
+// MTASTSChecker checks the MTA-STS policy and TLS-RPT record of domains
+type MTASTSChecker struct {
+	Client     dns.IDNSClient
+	Server     string
+	HTTPClient *http.Client
+}
+
+func NewMTASTSChecker(client dns.IDNSClient, dnsServer string) *MTASTSChecker {
+	c := &MTASTSChecker{Client: client, Server: dns.ServerAddress(dnsServer)}
+	// The policy host is resolved through the DNS server like the records, not the system resolver
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = c.dialContext
+	c.HTTPClient = &http.Client{
+		Transport: transport,
+		Timeout:   10 * time.Second,
+		// Policy fetches must not follow redirects
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return c
+}
+
+// dialContext connects to the host's A and AAAA addresses as answered by the DNS server, in turn until one accepts
+func (c *MTASTSChecker) dialContext(ctx context.Context, network string, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	var dialer net.Dialer
+	if _, err := netip.ParseAddr(host); err == nil {
+		return dialer.DialContext(ctx, network, addr)
+	}
+
+	var ips []string
+	var lookupErr error
+	for _, qtype := range []uint16{d.TypeA, d.TypeAAAA} {
+		err := dns.QueryDNSRecordContext(ctx, c.Client, host, c.Server, qtype, func(rr d.RR) {
+			switch rr := rr.(type) {
+			case *d.A:
+				ips = append(ips, rr.A.String())
+			case *d.AAAA:
+				ips = append(ips, rr.AAAA.String())
+			}
+		})
+		if err != nil {
+			lookupErr = err
+		}
+	}
+	if len(ips) == 0 {
+		if lookupErr != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", host, lookupErr)
+		}
+		return nil, fmt.Errorf("no A or AAAA records found for %s", host)
+	}
+
+	for _, ip := range ips {
+		var conn net.Conn
+		conn, err = dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+		if err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
+
+// Check validates the _mta-sts record, fetches the policy, checks it covers every MX host and validates the TLS-RPT record
+func (c *MTASTSChecker) Check(ctx context.Context, domain string) (*MTASTSReport, error) {
+	domain = strings.TrimSuffix(domain, ".")
+	report := &MTASTSReport{}
+
+	id, err := c.lookupID(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	report.ID = id
+
+	report.Policy, err = c.FetchPolicy(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	var hosts []string
+	err = dns.QueryDNSRecordContext(ctx, c.Client, domain, c.Server, d.TypeMX, func(rr d.RR) {
+		if mx, ok := rr.(*d.MX); ok {
+			hosts = append(hosts, mx.Mx)
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query MX records for %s: %w", domain, err)
+	}
+	for _, host := range hosts {
+		// A policy in none mode has been withdrawn, so it isn't expected to list the MX hosts
+		if report.Policy.Mode != "none" && !report.Policy.Covers(host) {
+			report.Uncovered = append(report.Uncovered, host)
+		}
+	}
+
+	report.TLSRPT, err = c.lookupTLSRPT(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// lookupID validates the _mta-sts TXT record and returns its policy id
+func (c *MTASTSChecker) lookupID(ctx context.Context, domain string) (string, error) {
+	name := "_mta-sts." + domain
+	tags, err := c.lookupTagRecord(ctx, name, "STSv1", "MTA-STS")
+	if err != nil {
+		return "", err
+	}
+	id := tags["id"]
+	if !mtaSTSIDPattern.MatchString(id) {
+		return "", fmt.Errorf("invalid MTA-STS id '%s' at %s, must be 1 to 32 letters and digits", id, name)
+	}
+	return id, nil
+}
+
+// lookupTLSRPT validates the _smtp._tls TXT record and returns its report URIs
+func (c *MTASTSChecker) lookupTLSRPT(ctx context.Context, domain string) ([]string, error) {
+	name := "_smtp._tls." + domain
+	tags, err := c.lookupTagRecord(ctx, name, "TLSRPTv1", "TLS-RPT")
+	if err != nil {
+		return nil, err
+	}
+	rua, ok := tags["rua"]
+	if !ok {
+		return nil, fmt.Errorf("TLS-RPT record at %s is missing the required rua tag", name)
+	}
+
+	var uris []string
+	for _, uri := range strings.Split(rua, ",") {
+		uri = strings.TrimSpace(uri)
+		lower := strings.ToLower(uri)
+		if !strings.HasPrefix(lower, "mailto:") && !strings.HasPrefix(lower, "https:") {
+			return nil, fmt.Errorf("invalid TLS-RPT rua URI '%s' at %s, must be mailto: or https:", uri, name)
+		}
+		uris = append(uris, uri)
+	}
+	return uris, nil
+}
+
+// lookupTagRecord finds the single record of a version at name and parses its tags
+func (c *MTASTSChecker) lookupTagRecord(ctx context.Context, name string, version string, kind string) (map[string]string, error) {
+	txts, err := lookupTXT(ctx, c.Client, c.Server, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query TXT records for %s: %w", name, err)
+	}
+
+	records := findVersionedRecords(txts, version)
+	switch len(records) {
+	case 0:
+		return nil, fmt.Errorf("no %s record found at %s", kind, name)
+	case 1:
+		tags, err := parseTagList(records[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s record at %s: %w", kind, name, err)
+		}
+		return tags, nil
+	default:
+		return nil, fmt.Errorf("%d %s records found at %s, there must be exactly one", len(records), kind, name)
+	}
+}
+
+// FetchPolicy fetches and parses https://mta-sts.<domain>/.well-known/mta-sts.txt
+func (c *MTASTSChecker) FetchPolicy(ctx context.Context, domain string) (*MTASTSPolicy, error) {
+	url := "https://mta-sts." + strings.TrimSuffix(domain, ".") + "/.well-known/mta-sts.txt"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the MTA-STS policy: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s returned status %s", url, resp.Status)
+	}
+	if mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err != nil || mediaType != "text/plain" {
+		return nil, fmt.Errorf("%s must be served as text/plain, got '%s'", url, resp.Header.Get("Content-Type"))
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxMTASTSPolicySize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the MTA-STS policy: %w", err)
+	}
+	policy, err := ParseMTASTSPolicy(string(body))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MTA-STS policy at %s: %w", url, err)
+	}
+	return policy, nil
+}
+
+// Test runs an mta-sts test: the domain must publish a valid MTA-STS record, policy and TLS-RPT record, the policy
+// must cover every MX host and carry every expected key=value
+func (c *MTASTSChecker) Test(ctx context.Context, domain string, expected []string) *dns.RecordComparison {
+	report, err := c.Check(ctx, domain)
+	if err != nil {
+		comparison := dns.NewRecordComparison()
+		comparison.Fail(err.Error())
+		return comparison
+	}
+
+	expected = normalizeTagAssertions(expected, "mode")
+	comparison := dns.DiffRecords(expected, report.Values(), dns.CompareOptions{Match: dns.MatchContains})
+	if len(report.Uncovered) > 0 {
+		comparison.Fail(fmt.Sprintf("MX hosts not covered by the policy: %s", strings.Join(report.Uncovered, ", ")))
+	} else if report.Policy.Mode != "none" {
+		comparison.Note(fmt.Sprintf("policy in %s mode covers every MX host", report.Policy.Mode))
+	} else {
+		comparison.Note("policy in none mode, MX coverage not checked")
+	}
+	return comparison
+}
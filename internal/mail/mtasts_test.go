@@ -0,0 +1,238 @@
+package mail
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMTASTSPolicy(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		expected    *MTASTSPolicy
+		expectError bool
+	}{
+		{
+			name: "Valid policy",
+			body: "version: STSv1\r\nmode: enforce\r\nmx: mail.example.com\r\nmx: *.example.net\r\nmax_age: 604800\r\n",
+			expected: &MTASTSPolicy{
+				Version: "STSv1",
+				Mode:    "enforce",
+				MX:      []string{"mail.example.com", "*.example.net"},
+				MaxAge:  604800,
+			},
+		},
+		{
+			name:     "None mode without mx and unknown fields",
+			body:     "version: STSv1\nmode: none\nmax_age: 0\nextension: value\n",
+			expected: &MTASTSPolicy{Version: "STSv1", Mode: "none", MaxAge: 0},
+		},
+		{name: "Wrong version", body: "version: STSv2\nmode: enforce\nmx: a.example.com\nmax_age: 1\n", expectError: true},
+		{name: "Invalid mode", body: "version: STSv1\nmode: strict\nmx: a.example.com\nmax_age: 1\n", expectError: true},
+		{name: "Enforce without mx", body: "version: STSv1\nmode: enforce\nmax_age: 1\n", expectError: true},
+		{name: "Missing max_age", body: "version: STSv1\nmode: testing\nmx: a.example.com\n", expectError: true},
+		{name: "max_age too large", body: "version: STSv1\nmode: testing\nmx: a.example.com\nmax_age: 99999999\n", expectError: true},
+		{name: "Duplicate mode", body: "version: STSv1\nmode: testing\nmode: enforce\nmx: a.example.com\nmax_age: 1\n", expectError: true},
+		{name: "Malformed line", body: "version: STSv1\nmode enforce\n", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := ParseMTASTSPolicy(tt.body)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("expected an error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("didn't expect an error but got %v", err)
+			}
+			if !reflect.DeepEqual(policy, tt.expected) {
+				t.Errorf("ParseMTASTSPolicy() = %+v, want %+v", policy, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMTASTSPolicyCovers(t *testing.T) {
+	policy := &MTASTSPolicy{MX: []string{"mail.example.com", "*.example.net"}}
+
+	tests := []struct {
+		host     string
+		expected bool
+	}{
+		{host: "mail.example.com.", expected: true},
+		{host: "MAIL.example.com", expected: true},
+		{host: "mx1.example.net.", expected: true},
+		{host: "example.net.", expected: false},
+		{host: "a.mx1.example.net.", expected: false},
+		{host: "other.example.com.", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := policy.Covers(tt.host); got != tt.expected {
+				t.Errorf("Covers(%s) = %v, want %v", tt.host, got, tt.expected)
+			}
+		})
+	}
+}
+
+// policyServer serves MTA-STS policies over HTTPS by host, the returned client sends every request to it
+func policyServer(t *testing.T, policies map[string]string) *http.Client {
+	t.Helper()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, _ := strings.Cut(r.Host, ":")
+		switch host {
+		case "mta-sts.redirect.example.com":
+			http.Redirect(w, r, "https://mta-sts.example.com/.well-known/mta-sts.txt", http.StatusFound)
+			return
+		case "mta-sts.html.example.com":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte(policies["mta-sts.example.com"]))
+			return
+		}
+		policy, ok := policies[host]
+		if !ok || r.URL.Path != "/.well-known/mta-sts.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte(policy))
+	}))
+	t.Cleanup(server.Close)
+
+	client := server.Client()
+	transport := client.Transport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+	}
+	// The test certificate only covers example.com and *.example.com
+	transport.TLSClientConfig.ServerName = "example.com"
+	client.Transport = transport
+	return client
+}
+
+func TestMTASTSTest(t *testing.T) {
+	enforce := "version: STSv1\nmode: enforce\nmx: *.example.com\nmax_age: 604800\n"
+	client := zoneClient(t,
+		`_mta-sts.example.com. TXT "v=STSv1; id=20240101T000000"`,
+		`_smtp._tls.example.com. TXT "v=TLSRPTv1; rua=mailto:tls@example.com,https://report.example.com/tls"`,
+		`example.com. MX 10 mx1.example.com.`,
+		`example.com. MX 20 mx2.example.com.`,
+		`_mta-sts.partial.example.com. TXT "v=STSv1; id=1"`,
+		`_smtp._tls.partial.example.com. TXT "v=TLSRPTv1; rua=mailto:tls@example.com"`,
+		`partial.example.com. MX 10 mx1.example.com.`,
+		`partial.example.com. MX 20 backup.example.org.`,
+		`_mta-sts.none.example.com. TXT "v=STSv1; id=1"`,
+		`_smtp._tls.none.example.com. TXT "v=TLSRPTv1; rua=mailto:tls@example.com"`,
+		`none.example.com. MX 10 backup.example.org.`,
+		`_mta-sts.norpt.example.com. TXT "v=STSv1; id=1"`,
+		`norpt.example.com. MX 10 mx1.example.com.`,
+		`_mta-sts.badrpt.example.com. TXT "v=STSv1; id=1"`,
+		`_smtp._tls.badrpt.example.com. TXT "v=TLSRPTv1; rua=ftp://example.com"`,
+		`badrpt.example.com. MX 10 mx1.example.com.`,
+		`_mta-sts.badid.example.com. TXT "v=STSv1; id=not-valid!"`,
+		`_mta-sts.nopolicy.example.com. TXT "v=STSv1; id=1"`,
+		`_mta-sts.redirect.example.com. TXT "v=STSv1; id=1"`,
+		`_mta-sts.html.example.com. TXT "v=STSv1; id=1"`,
+	)
+	httpClient := policyServer(t, map[string]string{
+		"mta-sts.example.com":         enforce,
+		"mta-sts.partial.example.com": enforce,
+		"mta-sts.none.example.com":    "version: STSv1\nmode: none\nmax_age: 86400\n",
+		"mta-sts.norpt.example.com":   enforce,
+		"mta-sts.badrpt.example.com":  enforce,
+	})
+
+	tests := []struct {
+		name       string
+		domain     string
+		expected   []string
+		wantPassed bool
+	}{
+		{name: "Valid setup", domain: "example.com", wantPassed: true},
+		{
+			name:       "Matching assertions",
+			domain:     "example.com",
+			expected:   []string{"mode=enforce", "max_age=604800", "mx=*.example.com", "rua=mailto:tls@example.com"},
+			wantPassed: true,
+		},
+		{name: "Mixed case assertions", domain: "example.com", expected: []string{"Mode=Enforce", " max_age = 604800"}, wantPassed: true},
+		{name: "Mismatched mode", domain: "example.com", expected: []string{"mode=testing"}, wantPassed: false},
+		{name: "MX host not covered", domain: "partial.example.com", wantPassed: false},
+		{name: "None mode skips coverage", domain: "none.example.com", wantPassed: true},
+		{name: "Missing TLS-RPT record", domain: "norpt.example.com", wantPassed: false},
+		{name: "Invalid TLS-RPT URI", domain: "badrpt.example.com", wantPassed: false},
+		{name: "Invalid id", domain: "badid.example.com", wantPassed: false},
+		{name: "Missing MTA-STS record", domain: "missing.example.com", wantPassed: false},
+		{name: "Policy not found", domain: "nopolicy.example.com", wantPassed: false},
+		{name: "Redirects aren't followed", domain: "redirect.example.com", wantPassed: false},
+		{name: "Wrong content type", domain: "html.example.com", wantPassed: false},
+	}
+
+	checker := NewMTASTSChecker(client, "8.8.8.8")
+	checker.HTTPClient.Transport = httpClient.Transport
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comparison := checker.Test(context.Background(), tt.domain, tt.expected)
+			if comparison.Passed != tt.wantPassed {
+				t.Errorf("Test() passed = %v, want %v (reasons: %v)", comparison.Passed, tt.wantPassed, comparison.Reasons)
+			}
+		})
+	}
+}
+
+func TestMTASTSDialContext(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	client := zoneClient(t,
+		`mta-sts.example.com. A 127.0.0.1`,
+		`mta-sts.alias.example.com. CNAME mta-sts.example.com.`,
+	)
+	checker := NewMTASTSChecker(client, "8.8.8.8")
+
+	tests := []struct {
+		name      string
+		host      string
+		wantError bool
+	}{
+		{name: "Resolved through the DNS server", host: "mta-sts.example.com"},
+		{name: "CNAME is followed", host: "mta-sts.alias.example.com"},
+		{name: "IP address", host: "127.0.0.1"},
+		{name: "No records", host: "mta-sts.missing.example.com", wantError: true},
+		{name: "Query failure", host: "error.com", wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := checker.dialContext(context.Background(), "tcp", net.JoinHostPort(tt.host, port))
+			if (err != nil) != tt.wantError {
+				t.Fatalf("dialContext() error = %v, wantError %v", err, tt.wantError)
+			}
+			if conn != nil {
+				conn.Close()
+			}
+		})
+	}
+}
@@ -0,0 +1,554 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/ch0ppy35/sherlock/internal/dns"
+	d "github.com/miekg/dns"
+)
+
+// MaxSPFLookups is the RFC 7208 limit on DNS querying terms evaluated for a single check
+const MaxSPFLookups = 10
+
+// maxSPFMXNames is the RFC 7208 limit on MX hosts looked up for a single mx mechanism
+const maxSPFMXNames = 10
+
+type SPFResult string
+
+const (
+	SPFPass      SPFResult = "pass"
+	SPFFail      SPFResult = "fail"
+	SPFSoftFail  SPFResult = "softfail"
+	SPFNeutral   SPFResult = "neutral"
+	SPFNone      SPFResult = "none"
+	SPFTempError SPFResult = "temperror"
+	SPFPermError SPFResult = "permerror"
+)
+
+// ParseSPFResult maps a string to an SPFResult
+func ParseSPFResult(result string) (SPFResult, error) {
+	switch r := SPFResult(strings.ToLower(result)); r {
+	case SPFPass, SPFFail, SPFSoftFail, SPFNeutral, SPFNone, SPFTempError, SPFPermError:
+		return r, nil
+	default:
+		return "", fmt.Errorf("unsupported SPF result, supported results: pass, fail, softfail, neutral, none, temperror, permerror")
+	}
+}
+
+// SPFReport is the outcome of evaluating a domain's SPF policy
+type SPFReport struct {
+	Record  string
+	Result  SPFResult
+	Lookups int
+	// Reason explains how the result was reached
+	Reason string
+}
+
+// SPFChecker evaluates SPF policies, resolving include:, redirect=, a, mx and exists through the DNS client
+type SPFChecker struct {
+	Client dns.IDNSClient
+	Server string
+}
+
+func NewSPFChecker(client dns.IDNSClient, dnsServer string) *SPFChecker {
+	return &SPFChecker{Client: client, Server: dns.ServerAddress(dnsServer)}
+}
+
+// spfEvaluation holds the state of a single check across includes and redirects
+type spfEvaluation struct {
+	ctx     context.Context
+	checker *SPFChecker
+	ip      net.IP
+	sender  string
+	lookups int
+}
+
+// Check evaluates the SPF policy of the domain for the sender IP. When ip is nil no mechanism can match, so
+// every term is walked, which validates the whole policy and counts every lookup it could take
+func (c *SPFChecker) Check(ctx context.Context, domain string, ip net.IP) *SPFReport {
+	e := &spfEvaluation{ctx: ctx, checker: c, ip: ip, sender: "postmaster@" + strings.TrimSuffix(domain, ".")}
+	report := &SPFReport{}
+
+	record, result, reason := e.fetchRecord(domain)
+	report.Record = record
+	if result == "" {
+		result, reason = e.checkHost(domain, record)
+	}
+
+	report.Result = result
+	report.Reason = reason
+	report.Lookups = e.lookups
+	return report
+}
+
+// fetchRecord finds the single SPF record of a domain, an empty result means it was found
+func (e *spfEvaluation) fetchRecord(domain string) (string, SPFResult, string) {
+	txts, err := e.lookupTXT(domain)
+	if err != nil {
+		return "", SPFTempError, fmt.Sprintf("failed to query TXT records for %s: %v", domain, err)
+	}
+
+	var records []string
+	for _, txt := range txts {
+		lower := strings.ToLower(txt)
+		if lower == "v=spf1" || strings.HasPrefix(lower, "v=spf1 ") {
+			records = append(records, txt)
+		}
+	}
+
+	switch len(records) {
+	case 0:
+		return "", SPFNone, fmt.Sprintf("no SPF record found for %s", domain)
+	case 1:
+		return records[0], "", ""
+	default:
+		return strings.Join(records, " | "), SPFPermError, fmt.Sprintf("%d SPF records found for %s, there must be exactly one", len(records), domain)
+	}
+}
+
+// checkHost implements the check_host() function of RFC 7208 for an already fetched record
+func (e *spfEvaluation) checkHost(domain string, record string) (SPFResult, string) {
+	terms := strings.Fields(record)[1:]
+	var redirect string
+	hasAll := false
+
+	for _, term := range terms {
+		name, value, isModifier := parseModifier(term)
+		if isModifier {
+			switch name {
+			case "redirect":
+				if redirect != "" {
+					return SPFPermError, fmt.Sprintf("%s has more than one redirect modifier", domain)
+				}
+				redirect = value
+			case "exp":
+			default:
+				// Unknown modifiers must be ignored
+			}
+			continue
+		}
+
+		qualifier, mechanism := parseQualifier(term)
+		if strings.EqualFold(mechanism, "all") {
+			hasAll = true
+		}
+		matched, result, reason := e.evalMechanism(domain, mechanism)
+		if result != "" {
+			return result, reason
+		}
+		if matched {
+			return qualifier, fmt.Sprintf("matched '%s' in the SPF record of %s", term, domain)
+		}
+	}
+
+	// redirect is ignored when there's an all mechanism
+	if redirect != "" && !hasAll {
+		target, err := e.expand(redirect, domain)
+		if err != nil {
+			return SPFPermError, err.Error()
+		}
+		if err := e.countLookup(); err != nil {
+			return SPFPermError, err.Error()
+		}
+		record, result, reason := e.fetchRecord(target)
+		if result == SPFNone {
+			return SPFPermError, fmt.Sprintf("redirect to %s which has no SPF record", target)
+		}
+		if result != "" {
+			return result, reason
+		}
+		return e.checkHost(target, record)
+	}
+
+	return SPFNeutral, fmt.Sprintf("no mechanism in the SPF record of %s matched", domain)
+}
+
+// evalMechanism evaluates a single mechanism, a non-empty result ends the evaluation with that result
+func (e *spfEvaluation) evalMechanism(domain string, mechanism string) (bool, SPFResult, string) {
+	name, arg, _ := strings.Cut(mechanism, ":")
+	name = strings.ToLower(name)
+	cidrArg := ""
+	if name != "ip4" && name != "ip6" {
+		name, cidrArg, _ = strings.Cut(name, "/")
+		if cidrArg != "" {
+			cidrArg = "/" + cidrArg
+		}
+		if i := strings.Index(arg, "/"); i >= 0 {
+			arg, cidrArg = arg[:i], arg[i:]
+		}
+	}
+
+	switch name {
+	case "all":
+		return e.ip != nil, "", ""
+	case "ip4", "ip6":
+		network, err := parseIPNetwork(arg, name == "ip6")
+		if err != nil {
+			return false, SPFPermError, fmt.Sprintf("invalid %s mechanism in the SPF record of %s: %v", name, domain, err)
+		}
+		return e.ip != nil && network.Contains(e.ip), "", ""
+	case "include":
+		if arg == "" {
+			return false, SPFPermError, fmt.Sprintf("include without a domain in the SPF record of %s", domain)
+		}
+		target, err := e.expand(arg, domain)
+		if err != nil {
+			return false, SPFPermError, err.Error()
+		}
+		if err := e.countLookup(); err != nil {
+			return false, SPFPermError, err.Error()
+		}
+		record, result, reason := e.fetchRecord(target)
+		if result == SPFNone {
+			return false, SPFPermError, fmt.Sprintf("include:%s has no SPF record", target)
+		}
+		if result != "" {
+			return false, result, reason
+		}
+		switch result, reason := e.checkHost(target, record); result {
+		case SPFPass:
+			return true, "", ""
+		case SPFTempError, SPFPermError:
+			return false, result, reason
+		default:
+			return false, "", ""
+		}
+	case "a", "mx", "exists", "ptr":
+		target := domain
+		if arg != "" {
+			expanded, err := e.expand(arg, domain)
+			if err != nil {
+				return false, SPFPermError, err.Error()
+			}
+			target = expanded
+		}
+		if err := e.countLookup(); err != nil {
+			return false, SPFPermError, err.Error()
+		}
+		return e.evalLookupMechanism(name, target, cidrArg)
+	default:
+		return false, SPFPermError, fmt.Sprintf("unknown mechanism '%s' in the SPF record of %s", mechanism, domain)
+	}
+}
+
+// evalLookupMechanism evaluates the a, mx, exists and ptr mechanisms against the target domain
+func (e *spfEvaluation) evalLookupMechanism(name string, target string, cidrArg string) (bool, SPFResult, string) {
+	v4Bits, v6Bits, err := parseDualCIDR(cidrArg)
+	if err != nil {
+		return false, SPFPermError, fmt.Sprintf("invalid prefix length on %s:%s: %v", name, target, err)
+	}
+
+	switch name {
+	case "exists":
+		ips, err := e.lookupIPs(target, d.TypeA)
+		if err != nil {
+			return false, SPFTempError, fmt.Sprintf("failed to query %s: %v", target, err)
+		}
+		return e.ip != nil && len(ips) > 0, "", ""
+	case "a":
+		ips, err := e.lookupHostIPs(target)
+		if err != nil {
+			return false, SPFTempError, fmt.Sprintf("failed to query %s: %v", target, err)
+		}
+		return e.ipInAny(ips, v4Bits, v6Bits), "", ""
+	case "mx":
+		hosts, err := e.lookupMX(target)
+		if err != nil {
+			return false, SPFTempError, fmt.Sprintf("failed to query MX records of %s: %v", target, err)
+		}
+		if len(hosts) > maxSPFMXNames {
+			return false, SPFPermError, fmt.Sprintf("%s has %d MX records, more than the limit of %d", target, len(hosts), maxSPFMXNames)
+		}
+		for _, host := range hosts {
+			ips, err := e.lookupHostIPs(host)
+			if err != nil {
+				return false, SPFTempError, fmt.Sprintf("failed to query %s: %v", host, err)
+			}
+			if e.ipInAny(ips, v4Bits, v6Bits) {
+				return true, "", ""
+			}
+		}
+		return false, "", ""
+	default:
+		// ptr is deprecated by RFC 7208 and isn't evaluated, it still counts towards the lookup limit
+		return false, "", ""
+	}
+}
+
+func (e *spfEvaluation) ipInAny(ips []net.IP, v4Bits int, v6Bits int) bool {
+	if e.ip == nil {
+		return false
+	}
+	for _, ip := range ips {
+		bits, size := v6Bits, 128
+		if ip.To4() != nil {
+			bits, size = v4Bits, 32
+		}
+		network := &net.IPNet{IP: ip.Mask(net.CIDRMask(bits, size)), Mask: net.CIDRMask(bits, size)}
+		if network.Contains(e.ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *spfEvaluation) countLookup() error {
+	e.lookups++
+	if e.lookups > MaxSPFLookups {
+		return fmt.Errorf("more than %d DNS lookups required", MaxSPFLookups)
+	}
+	return nil
+}
+
+func (e *spfEvaluation) lookupTXT(domain string) ([]string, error) {
+	return lookupTXT(e.ctx, e.checker.Client, e.checker.Server, domain)
+}
+
+func (e *spfEvaluation) lookupMX(domain string) ([]string, error) {
+	var hosts []string
+	err := dns.QueryDNSRecordContext(e.ctx, e.checker.Client, domain, e.checker.Server, d.TypeMX, func(rr d.RR) {
+		if mx, ok := rr.(*d.MX); ok {
+			hosts = append(hosts, mx.Mx)
+		}
+	})
+	return hosts, err
+}
+
+func (e *spfEvaluation) lookupIPs(domain string, qtype uint16) ([]net.IP, error) {
+	var ips []net.IP
+	err := dns.QueryDNSRecordContext(e.ctx, e.checker.Client, domain, e.checker.Server, qtype, func(rr d.RR) {
+		switch r := rr.(type) {
+		case *d.A:
+			ips = append(ips, r.A)
+		case *d.AAAA:
+			ips = append(ips, r.AAAA)
+		}
+	})
+	return ips, err
+}
+
+// lookupHostIPs resolves the addresses of the same family as the IP being checked
+func (e *spfEvaluation) lookupHostIPs(domain string) ([]net.IP, error) {
+	qtype := d.TypeA
+	if e.ip != nil && e.ip.To4() == nil {
+		qtype = d.TypeAAAA
+	}
+	return e.lookupIPs(domain, qtype)
+}
+
+// expand expands the macros of RFC 7208 section 7 in a domain-spec
+func (e *spfEvaluation) expand(spec string, domain string) (string, error) {
+	if !strings.Contains(spec, "%") {
+		return spec, nil
+	}
+
+	var out strings.Builder
+	for i := 0; i < len(spec); i++ {
+		if spec[i] != '%' {
+			out.WriteByte(spec[i])
+			continue
+		}
+		if i+1 >= len(spec) {
+			return "", fmt.Errorf("invalid macro in '%s'", spec)
+		}
+		i++
+		switch spec[i] {
+		case '%':
+			out.WriteByte('%')
+		case '_':
+			out.WriteByte(' ')
+		case '-':
+			out.WriteString("%20")
+		case '{':
+			end := strings.IndexByte(spec[i:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated macro in '%s'", spec)
+			}
+			value, err := e.expandMacro(spec[i+1:i+end], domain)
+			if err != nil {
+				return "", fmt.Errorf("invalid macro in '%s': %v", spec, err)
+			}
+			out.WriteString(value)
+			i += end
+		default:
+			return "", fmt.Errorf("invalid macro in '%s'", spec)
+		}
+	}
+	return out.String(), nil
+}
+
+func (e *spfEvaluation) expandMacro(macro string, domain string) (string, error) {
+	if macro == "" {
+		return "", fmt.Errorf("empty macro")
+	}
+
+	var value string
+	switch strings.ToLower(macro[:1]) {
+	case "s":
+		value = e.sender
+	case "l":
+		value, _, _ = strings.Cut(e.sender, "@")
+	case "o":
+		_, value, _ = strings.Cut(e.sender, "@")
+	case "d", "h":
+		value = strings.TrimSuffix(domain, ".")
+	case "i":
+		value = macroIP(e.ip)
+	case "v":
+		value = "in-addr"
+		if e.ip != nil && e.ip.To4() == nil {
+			value = "ip6"
+		}
+	default:
+		return "", fmt.Errorf("unknown macro letter '%s'", macro[:1])
+	}
+
+	transformers := macro[1:]
+	digits := 0
+	for len(transformers) > 0 && transformers[0] >= '0' && transformers[0] <= '9' {
+		digits = digits*10 + int(transformers[0]-'0')
+		transformers = transformers[1:]
+	}
+	reverse := false
+	if len(transformers) > 0 && (transformers[0] == 'r' || transformers[0] == 'R') {
+		reverse = true
+		transformers = transformers[1:]
+	}
+	delimiters := transformers
+	if delimiters == "" {
+		delimiters = "."
+	}
+
+	parts := strings.FieldsFunc(value, func(r rune) bool { return strings.ContainsRune(delimiters, r) })
+	if reverse {
+		for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+			parts[i], parts[j] = parts[j], parts[i]
+		}
+	}
+	if digits > 0 && digits < len(parts) {
+		parts = parts[len(parts)-digits:]
+	}
+	return strings.Join(parts, "."), nil
+}
+
+// macroIP formats the IP for the i macro, IPv6 addresses are written as dot separated nibbles
+func macroIP(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return v4.String()
+	}
+	nibbles := make([]string, 0, 32)
+	for _, b := range ip.To16() {
+		nibbles = append(nibbles, strconv.FormatUint(uint64(b>>4), 16), strconv.FormatUint(uint64(b&0xf), 16))
+	}
+	return strings.Join(nibbles, ".")
+}
+
+// parseModifier splits a name=value modifier, mechanisms never contain '=' before a ':' or '/'
+func parseModifier(term string) (string, string, bool) {
+	name, value, found := strings.Cut(term, "=")
+	if !found || strings.ContainsAny(name, ":/") {
+		return "", "", false
+	}
+	return strings.ToLower(name), value, true
+}
+
+func parseQualifier(term string) (SPFResult, string) {
+	switch term[0] {
+	case '+':
+		return SPFPass, term[1:]
+	case '-':
+		return SPFFail, term[1:]
+	case '~':
+		return SPFSoftFail, term[1:]
+	case '?':
+		return SPFNeutral, term[1:]
+	default:
+		return SPFPass, term
+	}
+}
+
+func parseIPNetwork(value string, v6 bool) (*net.IPNet, error) {
+	if !strings.Contains(value, "/") {
+		if v6 {
+			value += "/128"
+		} else {
+			value += "/32"
+		}
+	}
+	ip, network, err := net.ParseCIDR(value)
+	if err != nil {
+		return nil, err
+	}
+	if (ip.To4() == nil) != v6 {
+		return nil, fmt.Errorf("'%s' is not an IPv%s network", value, map[bool]string{true: "6", false: "4"}[v6])
+	}
+	return network, nil
+}
+
+// parseDualCIDR parses the optional "/v4" and "//v6" prefix lengths of the a and mx mechanisms
+func parseDualCIDR(value string) (int, int, error) {
+	v4Bits, v6Bits := 32, 128
+	if value == "" {
+		return v4Bits, v6Bits, nil
+	}
+
+	v4, v6, hasV6 := strings.Cut(strings.TrimPrefix(value, "/"), "//")
+	if strings.HasPrefix(value, "//") {
+		v4, v6, hasV6 = "", strings.TrimPrefix(value, "//"), true
+	}
+	if v4 != "" {
+		bits, err := strconv.Atoi(v4)
+		if err != nil || bits < 0 || bits > 32 {
+			return 0, 0, fmt.Errorf("invalid IPv4 prefix length '%s'", v4)
+		}
+		v4Bits = bits
+	}
+	if hasV6 {
+		bits, err := strconv.Atoi(v6)
+		if err != nil || bits < 0 || bits > 128 {
+			return 0, 0, fmt.Errorf("invalid IPv6 prefix length '%s'", v6)
+		}
+		v6Bits = bits
+	}
+	return v4Bits, v6Bits, nil
+}
+
+// Test runs an spf test: the domain must have exactly one valid SPF record that stays within the lookup limit
+// and, when a sender IP is given, the policy must evaluate to one of the expected results for it
+func (c *SPFChecker) Test(ctx context.Context, domain string, senderIP string, expected []string) *dns.RecordComparison {
+	comparison := dns.NewRecordComparison()
+	if senderIP != "" {
+		ip := net.ParseIP(senderIP)
+		if ip == nil {
+			comparison.Fail(fmt.Sprintf("invalid sender IP '%s'", senderIP))
+			return comparison
+		}
+		// Results are compared in lowercase, the way the report has them
+		results := make([]string, 0, len(expected))
+		for _, val := range expected {
+			if result, err := ParseSPFResult(val); err == nil {
+				val = string(result)
+			}
+			results = append(results, val)
+		}
+		report := c.Check(ctx, domain, ip)
+		comparison = dns.DiffRecords(results, []string{string(report.Result)}, dns.CompareOptions{Match: dns.MatchAnyOf})
+		comparison.Note(fmt.Sprintf("%s evaluates to %s: %s", senderIP, report.Result, report.Reason))
+	}
+
+	lint := c.Check(ctx, domain, nil)
+	switch lint.Result {
+	case SPFNone, SPFPermError, SPFTempError:
+		comparison.Fail(lint.Reason)
+	default:
+		comparison.Note(fmt.Sprintf("%d of %d DNS lookups used", lint.Lookups, MaxSPFLookups))
+	}
+	return comparison
+}
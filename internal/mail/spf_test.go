@@ -0,0 +1,137 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/ch0ppy35/sherlock/pkg/sherlock/dns/dnstest"
+	d "github.com/miekg/dns"
+)
+
+// zoneClient answers queries from a list of records in zone file format
+func zoneClient(t *testing.T, zone ...string) *dnstest.Resolver {
+	t.Helper()
+	records := []d.RR{}
+	for _, line := range zone {
+		rr, err := d.NewRR(line)
+		if err != nil {
+			t.Fatalf("invalid test record '%s': %v", line, err)
+		}
+		records = append(records, rr)
+	}
+
+	return dnstest.NewResolver(records...).Fail("error.com", fmt.Errorf("network error"))
+}
+
+func TestSPFCheck(t *testing.T) {
+	baseZone := []string{
+		`example.com. TXT "v=spf1 ip4:192.0.2.0/24 include:_spf.example.net ~all"`,
+		`_spf.example.net. TXT "v=spf1 ip4:198.51.100.10 ip6:2001:db8::/32 -all"`,
+		`redirected.com. TXT "v=spf1 redirect=example.com"`,
+		`hardfail.com. TXT "v=spf1 a mx -all"`,
+		`hardfail.com. A 203.0.113.5`,
+		`hardfail.com. MX 10 mail.hardfail.com.`,
+		`mail.hardfail.com. A 203.0.113.25`,
+		`cidr.com. TXT "v=spf1 a:hosts.cidr.com/28 -all"`,
+		`hosts.cidr.com. A 203.0.113.1`,
+		`exists.com. TXT "v=spf1 exists:%{i}._allow.exists.com -all"`,
+		`203.0.113.77._allow.exists.com. A 127.0.0.2`,
+		`dupe.com. TXT "v=spf1 -all"`,
+		`dupe.com. TXT "v=spf1 ~all"`,
+		`noinclude.com. TXT "v=spf1 include:missing.com -all"`,
+		`neutral.com. TXT "v=spf1 ip4:192.0.2.1"`,
+		`bad.com. TXT "v=spf1 ip4:not-an-ip -all"`,
+		`temp.com. TXT "v=spf1 include:error.com -all"`,
+		`split.com. TXT "v=spf1 ip4:192.0.2.1 " "-all"`,
+		`ptr.com. TXT "v=spf1 ptr -all"`,
+		`loop.com. TXT "v=spf1 include:loop.com -all"`,
+	}
+
+	// 11 includes is over the limit of 10 lookups
+	chain := []string{}
+	for i := 0; i < 11; i++ {
+		chain = append(chain, fmt.Sprintf(`chain%d.com. TXT "v=spf1 include:chain%d.com -all"`, i, i+1))
+	}
+	chain = append(chain, `chain11.com. TXT "v=spf1 -all"`)
+
+	tests := []struct {
+		name        string
+		domain      string
+		ip          string
+		wantResult  SPFResult
+		wantLookups int
+	}{
+		{name: "Direct ip4 match", domain: "example.com", ip: "192.0.2.55", wantResult: SPFPass, wantLookups: 0},
+		{name: "Include match", domain: "example.com", ip: "198.51.100.10", wantResult: SPFPass, wantLookups: 1},
+		{name: "Include ip6 match", domain: "example.com", ip: "2001:db8::25", wantResult: SPFPass, wantLookups: 1},
+		{name: "Softfail on all", domain: "example.com", ip: "203.0.113.1", wantResult: SPFSoftFail, wantLookups: 1},
+		{name: "Redirect", domain: "redirected.com", ip: "198.51.100.10", wantResult: SPFPass, wantLookups: 2},
+		{name: "a mechanism", domain: "hardfail.com", ip: "203.0.113.5", wantResult: SPFPass, wantLookups: 1},
+		{name: "mx mechanism", domain: "hardfail.com", ip: "203.0.113.25", wantResult: SPFPass, wantLookups: 2},
+		{name: "Hard fail", domain: "hardfail.com", ip: "203.0.113.99", wantResult: SPFFail, wantLookups: 2},
+		{name: "a mechanism with prefix length", domain: "cidr.com", ip: "203.0.113.14", wantResult: SPFPass, wantLookups: 1},
+		{name: "exists with macro", domain: "exists.com", ip: "203.0.113.77", wantResult: SPFPass, wantLookups: 1},
+		{name: "exists with macro no match", domain: "exists.com", ip: "203.0.113.78", wantResult: SPFFail, wantLookups: 1},
+		{name: "Duplicate SPF records", domain: "dupe.com", ip: "203.0.113.1", wantResult: SPFPermError},
+		{name: "No SPF record", domain: "nothing.com", ip: "203.0.113.1", wantResult: SPFNone},
+		{name: "Include without an SPF record", domain: "noinclude.com", ip: "203.0.113.1", wantResult: SPFPermError, wantLookups: 1},
+		{name: "No match and no all", domain: "neutral.com", ip: "203.0.113.1", wantResult: SPFNeutral},
+		{name: "Invalid ip4", domain: "bad.com", ip: "203.0.113.1", wantResult: SPFPermError},
+		{name: "Lookup error", domain: "temp.com", ip: "203.0.113.1", wantResult: SPFTempError, wantLookups: 1},
+		{name: "Multi-string record", domain: "split.com", ip: "203.0.113.1", wantResult: SPFFail},
+		{name: "ptr is never matched", domain: "ptr.com", ip: "203.0.113.1", wantResult: SPFFail, wantLookups: 1},
+		{name: "Include loop hits the lookup limit", domain: "loop.com", ip: "203.0.113.1", wantResult: SPFPermError, wantLookups: 11},
+		{name: "Too many lookups", domain: "chain0.com", ip: "203.0.113.1", wantResult: SPFPermError, wantLookups: 11},
+		{name: "Lint walks every term", domain: "hardfail.com", wantResult: SPFNeutral, wantLookups: 2},
+	}
+
+	checker := NewSPFChecker(zoneClient(t, append(baseZone, chain...)...), "8.8.8.8")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := checker.Check(context.Background(), tt.domain, net.ParseIP(tt.ip))
+			if report.Result != tt.wantResult {
+				t.Errorf("Check() result = %v, want %v (%s)", report.Result, tt.wantResult, report.Reason)
+			}
+			if report.Lookups != tt.wantLookups {
+				t.Errorf("Check() lookups = %v, want %v", report.Lookups, tt.wantLookups)
+			}
+		})
+	}
+}
+
+func TestSPFTest(t *testing.T) {
+	client := zoneClient(t,
+		`example.com. TXT "v=spf1 ip4:192.0.2.0/24 -all"`,
+		`dupe.com. TXT "v=spf1 -all"`,
+		`dupe.com. TXT "v=spf1 ~all"`,
+	)
+
+	tests := []struct {
+		name       string
+		domain     string
+		senderIP   string
+		expected   []string
+		wantPassed bool
+	}{
+		{name: "Valid record without a sender", domain: "example.com", wantPassed: true},
+		{name: "Sender passes", domain: "example.com", senderIP: "192.0.2.1", expected: []string{"pass"}, wantPassed: true},
+		{name: "Mixed case expected result", domain: "example.com", senderIP: "192.0.2.1", expected: []string{"Pass"}, wantPassed: true},
+		{name: "Sender fails as expected", domain: "example.com", senderIP: "203.0.113.1", expected: []string{"fail", "softfail"}, wantPassed: true},
+		{name: "Sender result unexpected", domain: "example.com", senderIP: "203.0.113.1", expected: []string{"pass"}, wantPassed: false},
+		{name: "Duplicate records", domain: "dupe.com", wantPassed: false},
+		{name: "No record", domain: "missing.com", wantPassed: false},
+		{name: "Invalid sender IP", domain: "example.com", senderIP: "nope", expected: []string{"pass"}, wantPassed: false},
+	}
+
+	checker := NewSPFChecker(client, "8.8.8.8")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comparison := checker.Test(context.Background(), tt.domain, tt.senderIP, tt.expected)
+			if comparison.Passed != tt.wantPassed {
+				t.Errorf("Test() passed = %v, want %v (reasons: %v)", comparison.Passed, tt.wantPassed, comparison.Reasons)
+			}
+		})
+	}
+}
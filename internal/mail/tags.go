@@ -0,0 +1,80 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ch0ppy35/sherlock/internal/dns"
+	d "github.com/miekg/dns"
+)
+
+// lookupTXT returns the TXT records of a name with the strings of each record joined
+func lookupTXT(ctx context.Context, client dns.IDNSClient, server string, name string) ([]string, error) {
+	records := &dns.DNSRecords{}
+	err := dns.QueryDNSRecordContext(ctx, client, name, server, d.TypeTXT, func(rr d.RR) {
+		if txt, ok := rr.(*d.TXT); ok {
+			records.TXTRecords = append(records.TXTRecords, strings.Join(txt.Txt, ""))
+		}
+	})
+	return records.TXTRecords, err
+}
+
+// findVersionedRecords returns the TXT records starting with the version tag, e.g. v=DMARC1
+func findVersionedRecords(txts []string, version string) []string {
+	var records []string
+	for _, txt := range txts {
+		tag, value, _ := strings.Cut(strings.SplitN(txt, ";", 2)[0], "=")
+		if strings.EqualFold(strings.TrimSpace(tag), "v") && strings.EqualFold(strings.TrimSpace(value), version) {
+			records = append(records, txt)
+		}
+	}
+	return records
+}
+
+// parseTagList parses a semicolon separated tag=value list as used by DMARC, DKIM, MTA-STS and TLS-RPT records,
+// tag names are lowercased and whitespace around tags and values is dropped
+func parseTagList(record string) (map[string]string, error) {
+	tags := make(map[string]string)
+	for _, spec := range strings.Split(record, ";") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		name, value, found := strings.Cut(spec, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid tag '%s', expected tag=value", spec)
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			return nil, fmt.Errorf("invalid tag '%s', the tag name is empty", spec)
+		}
+		if _, dup := tags[name]; dup {
+			return nil, fmt.Errorf("duplicate tag '%s'", name)
+		}
+		tags[name] = strings.TrimSpace(value)
+	}
+	return tags, nil
+}
+
+// normalizeTagAssertions brings expected tag=value strings into the form parseTagList gives the record: tag names
+// are lowercased, as are the values of the caseless tags, and whitespace around both is dropped. Patterns are left
+// as they are
+func normalizeTagAssertions(expected []string, caseless ...string) []string {
+	normalized := make([]string, 0, len(expected))
+	for _, val := range expected {
+		name, value, found := strings.Cut(val, "=")
+		if dns.IsPattern(val) || !found {
+			normalized = append(normalized, val)
+			continue
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		value = strings.TrimSpace(value)
+		if slices.Contains(caseless, name) {
+			value = strings.ToLower(value)
+		}
+		normalized = append(normalized, name+"="+value)
+	}
+	return normalized
+}
@@ -0,0 +1,136 @@
+package metrics
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ch0ppy35/sherlock/internal/dns"
+	dtexc "github.com/ch0ppy35/sherlock/internal/dns_test_executor"
+	d "github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+const namespace = "sherlock"
+
+// Metrics holds the Prometheus collectors exposed by sherlock
+type Metrics struct {
+	Registry      *prometheus.Registry
+	testPassing   *prometheus.GaugeVec
+	testLastRun   *prometheus.GaugeVec
+	testDuration  *prometheus.GaugeVec
+	queryDuration *prometheus.HistogramVec
+	queryRcodes   *prometheus.CounterVec
+	lastRun       prometheus.Gauge
+}
+
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+		testPassing: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "dns",
+			Name:      "test_passing",
+			Help:      "Whether the DNS test passed (1) or failed (0) on the last run.",
+		}, []string{"host", "type", "server", "variant"}),
+		testLastRun: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "dns",
+			Name:      "test_last_run_timestamp_seconds",
+			Help:      "Unix timestamp of the last time the DNS test ran.",
+		}, []string{"host", "type", "server", "variant"}),
+		testDuration: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "dns",
+			Name:      "test_duration_seconds",
+			Help:      "Time spent on the query or check of the DNS test on the last run.",
+		}, []string{"host", "type", "server", "variant"}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "dns",
+			Name:      "query_duration_seconds",
+			Help:      "Round trip time of DNS queries.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 13),
+		}, []string{"type", "server"}),
+		queryRcodes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "dns",
+			Name:      "query_responses_total",
+			Help:      "DNS responses received, by response code.",
+		}, []string{"type", "server", "rcode"}),
+		lastRun: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "dns",
+			Name:      "last_run_timestamp_seconds",
+			Help:      "Unix timestamp of the last completed test run.",
+		}),
+	}
+
+	m.Registry.MustRegister(m.testPassing, m.testLastRun, m.testDuration, m.queryDuration, m.queryRcodes, m.lastRun)
+	return m
+}
+
+// Handler returns an http.Handler serving the metrics in the Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.Registry, promhttp.HandlerOpts{})
+}
+
+// Push sends the current metrics to a Prometheus Pushgateway, replacing any metrics previously pushed for the job
+func (m *Metrics) Push(url string, job string) error {
+	if err := push.New(url, job).Gatherer(m.Registry).Push(); err != nil {
+		return fmt.Errorf("failed to push metrics to %s: %w", url, err)
+	}
+	return nil
+}
+
+// RecordRun updates the per-test gauges from the results of a test run
+func (m *Metrics) RecordRun(results []dtexc.TestResult, at time.Time) {
+	for _, result := range results {
+		passing := 0.0
+		if result.Passed {
+			passing = 1
+		}
+		labels := []string{result.Host, result.TestType, result.Server, result.Variant}
+		m.testPassing.WithLabelValues(labels...).Set(passing)
+		m.testLastRun.WithLabelValues(labels...).Set(float64(at.Unix()))
+		m.testDuration.WithLabelValues(labels...).Set(result.Duration.Seconds())
+	}
+	m.lastRun.Set(float64(at.Unix()))
+}
+
+// InstrumentClient wraps a DNS client so every exchange is observed by the query metrics
+func (m *Metrics) InstrumentClient(client dns.IDNSClient) dns.IDNSClient {
+	return &instrumentedClient{client: client, metrics: m}
+}
+
+type instrumentedClient struct {
+	client  dns.IDNSClient
+	metrics *Metrics
+}
+
+func (c *instrumentedClient) Exchange(msg *d.Msg, server string) (*d.Msg, time.Duration, error) {
+	resp, rtt, err := c.client.Exchange(msg, server)
+
+	qtype := "unknown"
+	if len(msg.Question) > 0 {
+		qtype = strings.ToLower(d.TypeToString[msg.Question[0].Qtype])
+	}
+
+	host := server
+	if h, _, err := net.SplitHostPort(server); err == nil {
+		host = h
+	}
+
+	rcode := "error"
+	if err == nil && resp != nil {
+		rcode = d.RcodeToString[resp.Rcode]
+		c.metrics.queryDuration.WithLabelValues(qtype, host).Observe(rtt.Seconds())
+	}
+	c.metrics.queryRcodes.WithLabelValues(qtype, host, rcode).Inc()
+
+	return resp, rtt, err
+}
@@ -0,0 +1,163 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	dtexc "github.com/ch0ppy35/sherlock/internal/dns_test_executor"
+	"github.com/ch0ppy35/sherlock/pkg/sherlock/dns/dnstest"
+	d "github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRecordRun(t *testing.T) {
+	tests := []struct {
+		name        string
+		results     []dtexc.TestResult
+		wantPassing map[[4]string]float64
+	}{
+		{
+			name: "Passing and failing tests",
+			results: []dtexc.TestResult{
+				{Host: "example.com", TestType: "a", Server: "8.8.8.8", Passed: true},
+				{Host: "example.com", TestType: "mx", Server: "8.8.8.8", Passed: false},
+			},
+			wantPassing: map[[4]string]float64{
+				{"example.com", "a", "8.8.8.8", ""}:  1,
+				{"example.com", "mx", "8.8.8.8", ""}: 0,
+			},
+		},
+		{
+			name: "Tests of the same host and type",
+			results: []dtexc.TestResult{
+				{Host: "example.com", TestType: "dkim", Server: "8.8.8.8", Variant: "selector=s1", Passed: false},
+				{Host: "example.com", TestType: "dkim", Server: "8.8.8.8", Variant: "selector=s2", Passed: true},
+				{Host: "www.example.com", TestType: "a", Server: "8.8.8.8", Passed: true},
+				{Host: "www.example.com", TestType: "a", Server: "8.8.8.8", Variant: "ecs=203.0.113.0/24", Passed: false},
+			},
+			wantPassing: map[[4]string]float64{
+				{"example.com", "dkim", "8.8.8.8", "selector=s1"}:         0,
+				{"example.com", "dkim", "8.8.8.8", "selector=s2"}:         1,
+				{"www.example.com", "a", "8.8.8.8", ""}:                   1,
+				{"www.example.com", "a", "8.8.8.8", "ecs=203.0.113.0/24"}: 0,
+			},
+		},
+		{
+			name:        "No tests",
+			results:     []dtexc.TestResult{},
+			wantPassing: map[[4]string]float64{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMetrics()
+			at := time.Unix(1700000000, 0)
+			m.RecordRun(tt.results, at)
+
+			if got := testutil.CollectAndCount(m.testPassing); got != len(tt.wantPassing) {
+				t.Errorf("RecordRun() test_passing series = %d, want %d", got, len(tt.wantPassing))
+			}
+			for labels, want := range tt.wantPassing {
+				if got := testutil.ToFloat64(m.testPassing.WithLabelValues(labels[:]...)); got != want {
+					t.Errorf("RecordRun() test_passing%v = %v, want %v", labels, got, want)
+				}
+				if got := testutil.ToFloat64(m.testLastRun.WithLabelValues(labels[:]...)); got != float64(at.Unix()) {
+					t.Errorf("RecordRun() test_last_run_timestamp_seconds%v = %v, want %v", labels, got, at.Unix())
+				}
+			}
+			if got := testutil.ToFloat64(m.lastRun); got != float64(at.Unix()) {
+				t.Errorf("RecordRun() last_run_timestamp_seconds = %v, want %v", got, at.Unix())
+			}
+		})
+	}
+}
+
+func TestInstrumentClient(t *testing.T) {
+	tests := []struct {
+		name         string
+		mockResponse *d.Msg
+		mockError    error
+		wantRcode    string
+		wantObserved int
+	}{
+		{
+			name: "Successful query",
+			mockResponse: &d.Msg{
+				Answer: []d.RR{
+					&d.A{Hdr: d.RR_Header{Name: "example.com."}, A: net.ParseIP("10.0.0.1")},
+				},
+			},
+			wantRcode:    "NOERROR",
+			wantObserved: 1,
+		},
+		{
+			name:         "NXDOMAIN response",
+			mockResponse: &d.Msg{MsgHdr: d.MsgHdr{Rcode: d.RcodeNameError}},
+			wantRcode:    "NXDOMAIN",
+			wantObserved: 1,
+		},
+		{
+			name:         "Query returns an error",
+			mockError:    fmt.Errorf("network error"),
+			wantRcode:    "error",
+			wantObserved: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMetrics()
+			client := m.InstrumentClient(dnstest.ExchangeFunc(func(msg *d.Msg, server string) (*d.Msg, time.Duration, error) {
+				return tt.mockResponse, 5 * time.Millisecond, tt.mockError
+			}))
+
+			msg := new(d.Msg)
+			msg.SetQuestion("example.com.", d.TypeA)
+			_, _, _ = client.Exchange(msg, "8.8.8.8:53")
+
+			if got := testutil.ToFloat64(m.queryRcodes.WithLabelValues("a", "8.8.8.8", tt.wantRcode)); got != 1 {
+				t.Errorf("Exchange() query_responses_total{rcode=%q} = %v, want 1", tt.wantRcode, got)
+			}
+			if got := testutil.CollectAndCount(m.queryDuration); got != tt.wantObserved {
+				t.Errorf("Exchange() query_duration_seconds series = %d, want %d", got, tt.wantObserved)
+			}
+		})
+	}
+}
+
+func TestPush(t *testing.T) {
+	var gotPath, gotBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotPath, gotBody = r.URL.Path, string(body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	m := NewMetrics()
+	m.RecordRun([]dtexc.TestResult{
+		{Host: "example.com", TestType: "a", Server: "8.8.8.8", Passed: true},
+	}, time.Now())
+
+	if err := m.Push(server.URL, "sherlock"); err != nil {
+		t.Fatalf("Push() error = %v", err)
+	}
+	if gotPath != "/metrics/job/sherlock" {
+		t.Errorf("Push() path = %q, expected %q", gotPath, "/metrics/job/sherlock")
+	}
+	if !strings.Contains(gotBody, "sherlock_dns_test_passing") {
+		t.Errorf("Push() body does not contain sherlock_dns_test_passing")
+	}
+
+	server.Close()
+	if err := m.Push(server.URL, "sherlock"); err == nil {
+		t.Errorf("Push() expected an error when the gateway is unreachable")
+	}
+}
@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	dtexc "github.com/ch0ppy35/sherlock/internal/dns_test_executor"
+)
+
+// SendStatsD writes the results of a test run to a StatsD server over UDP
+func SendStatsD(addr string, results []dtexc.TestResult) error {
+	conn, err := net.DialTimeout("udp", addr, 5*time.Second)
+	if err != nil {
+		return fmt.Errorf("failed to connect to statsd at %s: %w", addr, err)
+	}
+	defer conn.Close()
+
+	for _, line := range statsDLines(results) {
+		if _, err := conn.Write([]byte(line)); err != nil {
+			return fmt.Errorf("failed to send metrics to statsd at %s: %w", addr, err)
+		}
+	}
+	return nil
+}
+
+// statsDLines renders one StatsD line per metric, using the metric name as the hierarchy
+func statsDLines(results []dtexc.TestResult) []string {
+	lines := []string{}
+	failed := 0
+	for _, result := range results {
+		key := strings.Join([]string{statsDKey(result.Server), statsDKey(result.Host), statsDKey(result.TestType)}, ".")
+		if result.Variant != "" {
+			key += "." + statsDKey(result.Variant)
+		}
+		passing := 0
+		if result.Passed {
+			passing = 1
+		} else {
+			failed++
+		}
+		lines = append(lines,
+			fmt.Sprintf("%s.dns.test_passing.%s:%d|g", namespace, key, passing),
+			fmt.Sprintf("%s.dns.test_duration.%s:%d|ms", namespace, key, result.Duration.Milliseconds()),
+		)
+	}
+	lines = append(lines,
+		fmt.Sprintf("%s.dns.tests_total:%d|g", namespace, len(results)),
+		fmt.Sprintf("%s.dns.tests_failed:%d|g", namespace, failed),
+	)
+	return lines
+}
+
+// statsDKey makes a value safe to use as a single StatsD name segment
+func statsDKey(value string) string {
+	return strings.NewReplacer(".", "_", ":", "_", "|", "_", "@", "_", " ", "_", "/", "_", "=", "_", ",", "_").Replace(strings.TrimSuffix(value, "."))
+}
@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"net"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	dtexc "github.com/ch0ppy35/sherlock/internal/dns_test_executor"
+)
+
+func TestSendStatsD(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start udp listener: %v", err)
+	}
+	defer listener.Close()
+
+	results := []dtexc.TestResult{
+		{Host: "example.com", TestType: "a", Server: "8.8.8.8", Passed: true, Duration: 12 * time.Millisecond},
+		{Host: "mail.example.com.", TestType: "mx", Server: "8.8.8.8", Passed: false, Duration: 3 * time.Millisecond},
+		{Host: "example.com", TestType: "a", Server: "8.8.8.8", Variant: "ecs=203.0.113.0/24", Passed: true, Duration: 5 * time.Millisecond},
+	}
+	if err := SendStatsD(listener.LocalAddr().String(), results); err != nil {
+		t.Fatalf("SendStatsD() error = %v", err)
+	}
+
+	expected := []string{
+		"sherlock.dns.test_duration.8_8_8_8.example_com.a.ecs_203_0_113_0_24:5|ms",
+		"sherlock.dns.test_duration.8_8_8_8.example_com.a:12|ms",
+		"sherlock.dns.test_duration.8_8_8_8.mail_example_com.mx:3|ms",
+		"sherlock.dns.test_passing.8_8_8_8.example_com.a.ecs_203_0_113_0_24:1|g",
+		"sherlock.dns.test_passing.8_8_8_8.example_com.a:1|g",
+		"sherlock.dns.test_passing.8_8_8_8.mail_example_com.mx:0|g",
+		"sherlock.dns.tests_failed:1|g",
+		"sherlock.dns.tests_total:3|g",
+	}
+
+	got := []string{}
+	buf := make([]byte, 1024)
+	_ = listener.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for len(got) < len(expected) {
+		n, _, err := listener.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("failed to read statsd packet: %v", err)
+		}
+		got = append(got, string(buf[:n]))
+	}
+	sort.Strings(got)
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("SendStatsD() sent %v, expected %v", got, expected)
+	}
+}
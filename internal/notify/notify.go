@@ -0,0 +1,231 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	cfg "github.com/ch0ppy35/sherlock/internal/config"
+	dtexc "github.com/ch0ppy35/sherlock/internal/dns_test_executor"
+)
+
+const defaultTemplate = `{{ if .Failures }}:rotating_light: Sherlock: {{ len .Failures }} of {{ .Total }} DNS tests failing
+{{ range .Failures }}• {{ .Host }} {{ .Type }} @ {{ .Server }}{{ if .Error }}: {{ .Error }}{{ end }}{{ if .Missing }} missing: {{ join .Missing ", " }}{{ end }}{{ if .Unexpected }} unexpected: {{ join .Unexpected ", " }}{{ end }}
+{{ end }}{{ end }}{{ if .Recovered }}:white_check_mark: Sherlock: {{ len .Recovered }} DNS tests recovered
+{{ range .Recovered }}• {{ .Host }} {{ .Type }} @ {{ .Server }}
+{{ end }}{{ end }}`
+
+// Payload is the JSON body sent to webhooks, also used as the data for body templates
+type Payload struct {
+	Timestamp time.Time    `json:"timestamp"`
+	Total     int          `json:"total"`
+	Failures  []TestReport `json:"failures"`
+	Recovered []TestReport `json:"recovered,omitempty"`
+}
+
+// TestReport describes a single failing or recovered test in a notification
+type TestReport struct {
+	Host       string   `json:"host"`
+	Type       string   `json:"type"`
+	Server     string   `json:"server"`
+	Variant    string   `json:"variant,omitempty"`
+	Expected   []string `json:"expected"`
+	Missing    []string `json:"missing"`
+	Unexpected []string `json:"unexpected"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// Notifier posts test results to the configured webhooks, keeping track of each test's
+// previous state so webhooks can be fired on state transitions only
+type Notifier struct {
+	Webhooks []cfg.WebhookConfig
+	Client   *http.Client
+	previous map[string]bool
+	mu       sync.Mutex
+}
+
+func NewNotifier(webhooks []cfg.WebhookConfig) *Notifier {
+	return &Notifier{
+		Webhooks: webhooks,
+		Client:   &http.Client{Timeout: 10 * time.Second},
+		previous: make(map[string]bool),
+	}
+}
+
+// Notify sends the results of a run to every webhook whose trigger matches
+func (n *Notifier) Notify(results []dtexc.TestResult) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	now := time.Now()
+	failing := &Payload{Timestamp: now, Total: len(results), Failures: []TestReport{}}
+	changed := &Payload{Timestamp: now, Total: len(results), Failures: []TestReport{}}
+
+	for _, result := range results {
+		key := resultKey(result)
+		// Tests we haven't seen yet are assumed to have been passing
+		wasPassing, seen := n.previous[key]
+		if !seen {
+			wasPassing = true
+		}
+		n.previous[key] = result.Passed
+
+		if !result.Passed {
+			failing.Failures = append(failing.Failures, newTestReport(result))
+			if wasPassing {
+				changed.Failures = append(changed.Failures, newTestReport(result))
+			}
+		} else if !wasPassing {
+			changed.Recovered = append(changed.Recovered, newTestReport(result))
+		}
+	}
+
+	var errs []error
+	for _, webhook := range n.Webhooks {
+		payload := failing
+		switch {
+		case webhook.On == "change" && webhook.Format == "alertmanager":
+			// Alertmanager resolves alerts that stop being sent, so failures are sent on every run
+			payload = &Payload{Timestamp: now, Total: len(results), Failures: failing.Failures, Recovered: changed.Recovered}
+		case webhook.On == "change":
+			payload = changed
+		}
+		if len(payload.Failures) == 0 && len(payload.Recovered) == 0 {
+			continue
+		}
+		if err := n.send(webhook, payload); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (n *Notifier) send(webhook cfg.WebhookConfig, payload *Payload) error {
+	body, err := renderBody(webhook, payload)
+	if err != nil {
+		return fmt.Errorf("failed to render webhook body for %s: %w", webhook.URL, err)
+	}
+
+	resp, err := n.Client.Post(webhook.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to send webhook to %s: %w", webhook.URL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s returned status %s", webhook.URL, resp.Status)
+	}
+	return nil
+}
+
+// renderBody builds the request body for the webhook's format
+func renderBody(webhook cfg.WebhookConfig, payload *Payload) ([]byte, error) {
+	switch webhook.Format {
+	case "slack", "teams":
+		text, err := renderTemplate(webhook.Template, payload)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(map[string]string{"text": text})
+	case "alertmanager":
+		return json.Marshal(alertmanagerAlerts(payload))
+	default:
+		return json.Marshal(payload)
+	}
+}
+
+func renderTemplate(tmpl string, payload *Payload) (string, error) {
+	if tmpl == "" {
+		tmpl = defaultTemplate
+	}
+
+	t, err := template.New("webhook").Funcs(cfg.WebhookTemplateFuncs).Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("invalid template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, payload); err != nil {
+		return "", fmt.Errorf("failed to execute template: %w", err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+type alert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	StartsAt    *time.Time        `json:"startsAt,omitempty"`
+	EndsAt      *time.Time        `json:"endsAt,omitempty"`
+}
+
+// alertmanagerAlerts converts the payload to the body of an Alertmanager /api/v2/alerts request, failing tests
+// are sent without an end time so they keep firing and recovered tests with one so Alertmanager resolves them
+func alertmanagerAlerts(payload *Payload) []alert {
+	alerts := []alert{}
+	for _, failing := range payload.Failures {
+		a := newAlert(failing)
+		a.StartsAt = &payload.Timestamp
+		alerts = append(alerts, a)
+	}
+	for _, recovered := range payload.Recovered {
+		a := newAlert(recovered)
+		a.EndsAt = &payload.Timestamp
+		alerts = append(alerts, a)
+	}
+	return alerts
+}
+
+func newAlert(report TestReport) alert {
+	summary := fmt.Sprintf("DNS test for %s %s records on %s is failing", report.Host, report.Type, report.Server)
+	annotations := map[string]string{
+		"summary":    summary,
+		"expected":   strings.Join(report.Expected, ", "),
+		"missing":    strings.Join(report.Missing, ", "),
+		"unexpected": strings.Join(report.Unexpected, ", "),
+	}
+	if report.Error != "" {
+		annotations["error"] = report.Error
+	}
+
+	labels := map[string]string{
+		"alertname": "SherlockDNSTestFailing",
+		"host":      report.Host,
+		"type":      report.Type,
+		"server":    report.Server,
+	}
+	// Alertmanager identifies alerts by their labels, so tests of the same host and type need the variant
+	if report.Variant != "" {
+		labels["variant"] = report.Variant
+	}
+	return alert{Labels: labels, Annotations: annotations}
+}
+
+func newTestReport(result dtexc.TestResult) TestReport {
+	report := TestReport{
+		Host:       result.Host,
+		Type:       result.TestType,
+		Server:     result.Server,
+		Variant:    result.Variant,
+		Expected:   result.Expected,
+		Missing:    []string{},
+		Unexpected: []string{},
+	}
+	if result.Comparison != nil {
+		report.Missing = result.Comparison.Missing
+		report.Unexpected = result.Comparison.Unexpected
+	}
+	if result.Err != nil {
+		report.Error = result.Err.Error()
+	}
+	return report
+}
+
+func resultKey(result dtexc.TestResult) string {
+	return strings.Join([]string{result.Host, result.TestType, result.Server, result.Variant}, "|")
+}
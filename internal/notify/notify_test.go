@@ -0,0 +1,199 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	cfg "github.com/ch0ppy35/sherlock/internal/config"
+	"github.com/ch0ppy35/sherlock/internal/dns"
+	dtexc "github.com/ch0ppy35/sherlock/internal/dns_test_executor"
+)
+
+func failingResult() dtexc.TestResult {
+	return dtexc.TestResult{
+		Host:     "example.com",
+		TestType: "a",
+		Server:   "8.8.8.8",
+		Passed:   false,
+		Err:      fmt.Errorf("mismatched records found"),
+		Expected: []string{"10.0.0.2"},
+		Comparison: &dns.RecordComparison{
+			Matched:    []string{},
+			Unexpected: []string{"10.0.0.1"},
+			Missing:    []string{"10.0.0.2"},
+		},
+	}
+}
+
+func passingResult() dtexc.TestResult {
+	return dtexc.TestResult{Host: "example.com", TestType: "a", Server: "8.8.8.8", Passed: true, Expected: []string{"10.0.0.2"}}
+}
+
+// variantResults are a failing and a passing test that only differ by their variant
+func variantResults() []dtexc.TestResult {
+	failing, passing := failingResult(), passingResult()
+	failing.Variant = "ecs=203.0.113.0/24"
+	return []dtexc.TestResult{failing, passing}
+}
+
+func TestNotify(t *testing.T) {
+	tests := []struct {
+		name      string
+		on        string
+		runs      [][]dtexc.TestResult
+		wantCalls int
+	}{
+		{
+			name:      "Failure trigger fires on every failing run",
+			on:        "failure",
+			runs:      [][]dtexc.TestResult{{failingResult()}, {failingResult()}},
+			wantCalls: 2,
+		},
+		{
+			name:      "Failure trigger ignores passing runs",
+			on:        "failure",
+			runs:      [][]dtexc.TestResult{{passingResult()}},
+			wantCalls: 0,
+		},
+		{
+			name:      "Change trigger fires once per transition",
+			on:        "change",
+			runs:      [][]dtexc.TestResult{{failingResult()}, {failingResult()}, {passingResult()}, {passingResult()}},
+			wantCalls: 2,
+		},
+		{
+			name:      "Change trigger keeps the state of each variant",
+			on:        "change",
+			runs:      [][]dtexc.TestResult{variantResults(), variantResults(), variantResults()},
+			wantCalls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+			}))
+			defer server.Close()
+
+			notifier := NewNotifier([]cfg.WebhookConfig{{URL: server.URL, Format: "json", On: tt.on}})
+			for _, results := range tt.runs {
+				if err := notifier.Notify(results); err != nil {
+					t.Fatalf("Notify() error = %v", err)
+				}
+			}
+
+			if calls != tt.wantCalls {
+				t.Errorf("Notify() webhook calls = %d, expected %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestNotifyAlertmanagerChange(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+	}))
+	defer server.Close()
+
+	notifier := NewNotifier([]cfg.WebhookConfig{{URL: server.URL, Format: "alertmanager", On: "change"}})
+	for _, results := range [][]dtexc.TestResult{{failingResult()}, {failingResult()}, {passingResult()}, {passingResult()}} {
+		if err := notifier.Notify(results); err != nil {
+			t.Fatalf("Notify() error = %v", err)
+		}
+	}
+
+	// Both failing runs keep the alert firing, the recovery resolves it and passing runs send nothing
+	if len(bodies) != 3 {
+		t.Fatalf("Notify() webhook calls = %d, expected 3", len(bodies))
+	}
+	for i, body := range bodies[:2] {
+		if !strings.Contains(body, `"startsAt":`) || strings.Contains(body, `"endsAt":`) {
+			t.Errorf("Notify() run %d body = %s, expected a firing alert", i+1, body)
+		}
+	}
+	if !strings.Contains(bodies[2], `"endsAt":`) || strings.Contains(bodies[2], `"startsAt":`) {
+		t.Errorf("Notify() run 3 body = %s, expected a resolved alert", bodies[2])
+	}
+}
+
+func TestNotifyFormats(t *testing.T) {
+	tests := []struct {
+		name     string
+		webhook  cfg.WebhookConfig
+		contains []string
+	}{
+		{
+			name:     "JSON payload",
+			webhook:  cfg.WebhookConfig{Format: "json", On: "failure"},
+			contains: []string{`"host":"example.com"`, `"missing":["10.0.0.2"]`, `"unexpected":["10.0.0.1"]`, `"server":"8.8.8.8"`},
+		},
+		{
+			name:     "Slack default template",
+			webhook:  cfg.WebhookConfig{Format: "slack", On: "failure"},
+			contains: []string{`"text":`, "1 of 1 DNS tests failing", "missing: 10.0.0.2"},
+		},
+		{
+			name:     "Teams custom template",
+			webhook:  cfg.WebhookConfig{Format: "teams", On: "failure", Template: "{{ range .Failures }}{{ .Host }} is broken{{ end }}"},
+			contains: []string{`{"text":"example.com is broken"}`},
+		},
+		{
+			name:     "Alertmanager alerts",
+			webhook:  cfg.WebhookConfig{Format: "alertmanager", On: "failure"},
+			contains: []string{`"alertname":"SherlockDNSTestFailing"`, `"startsAt":`, `"missing":"10.0.0.2"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				body = string(b)
+			}))
+			defer server.Close()
+
+			tt.webhook.URL = server.URL
+			if err := NewNotifier([]cfg.WebhookConfig{tt.webhook}).Notify([]dtexc.TestResult{failingResult()}); err != nil {
+				t.Fatalf("Notify() error = %v", err)
+			}
+
+			if !json.Valid([]byte(body)) {
+				t.Errorf("Notify() body is not valid JSON: %s", body)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(body, want) {
+					t.Errorf("Notify() body = %s, expected it to contain %s", body, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNotifyErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	notifier := NewNotifier([]cfg.WebhookConfig{
+		{URL: server.URL, Format: "json", On: "failure"},
+		{URL: server.URL, Format: "slack", On: "failure", Template: "{{ .Nope }"},
+	})
+	err := notifier.Notify([]dtexc.TestResult{failingResult()})
+	if err == nil {
+		t.Fatalf("Notify() expected an error")
+	}
+	if !strings.Contains(err.Error(), "returned status 500") || !strings.Contains(err.Error(), "invalid template") {
+		t.Errorf("Notify() error = %v, expected both webhook errors", err)
+	}
+}
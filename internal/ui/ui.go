@@ -32,7 +32,11 @@ var DefaultColorWriters = ColorWriters{
 }
 
 func PrintDashes() {
-	fmt.Printf("—————————————————————————————————————————————————————————\n")
+	FprintDashes(os.Stdout)
+}
+
+func FprintDashes(output io.Writer) {
+	fmt.Fprintf(output, "—————————————————————————————————————————————————————————\n")
 }
 
 func PrintMsgWithStatus(status string, color string, format string, a ...any) {
@@ -43,6 +47,10 @@ func PrintErrMsgWithStatus(status string, color string, format string, a ...any)
 	printWithStatus(os.Stderr, status, color, format, a...)
 }
 
+func FprintMsgWithStatus(output io.Writer, status string, color string, format string, a ...any) {
+	printWithStatus(output, status, color, format, a...)
+}
+
 func printWithStatus(output io.Writer, status string, color string, format string, a ...any) {
 	writer, ok := DefaultColorWriters[color]
 	if !ok {
@@ -0,0 +1,100 @@
+// Package dns is the supported Go API of sherlock. It queries and compares DNS records and runs test configs the
+// same way `sherlock dns run` does, so Go test suites can assert on DNS without shelling out.
+//
+// The dnstest package provides an in-memory resolver and record builders to test against.
+package dns
+
+import (
+	"context"
+
+	cfg "github.com/ch0ppy35/sherlock/internal/config"
+	internaldns "github.com/ch0ppy35/sherlock/internal/dns"
+	dtexc "github.com/ch0ppy35/sherlock/internal/dns_test_executor"
+)
+
+type (
+	// Client sends DNS queries, *github.com/miekg/dns.Client and the dnstest resolver both implement it
+	Client = internaldns.IDNSClient
+	// Records are the A, AAAA, CNAME, MX, TXT and NS records of a name
+	Records = internaldns.DNSRecords
+	// MXRecord is an MX record's host and preference
+	MXRecord = internaldns.MXRecord
+	// ResponseFlags are the header flags of a response
+	ResponseFlags = internaldns.ResponseFlags
+	// QueryOption adjusts a query before it's sent
+	QueryOption = internaldns.QueryOption
+	// MatchMode controls how the expected records are compared against the actual records
+	MatchMode = internaldns.MatchMode
+	// CompareOptions sets the match mode, counts and normalization of a comparison
+	CompareOptions = internaldns.CompareOptions
+	// Comparison is the outcome of comparing expected and actual records
+	Comparison = internaldns.RecordComparison
+
+	// Config is a test config, as loaded from a config file
+	Config = cfg.DNSRecordsFullTestConfig
+	// TestConfig is a single test of a config
+	TestConfig = cfg.DNSTestConfig
+
+	// Executor runs the tests of a config
+	Executor = dtexc.DNSTestExecutor
+	// TestResult is the outcome of a single test run by an Executor
+	TestResult = dtexc.TestResult
+)
+
+const (
+	// MatchExact requires the actual records to be exactly the expected records, it's the default
+	MatchExact = internaldns.MatchExact
+	// MatchContains requires every expected record to be present, extra records are allowed
+	MatchContains = internaldns.MatchContains
+	// MatchSubsetOf requires every actual record to be one of the expected records, missing records are allowed
+	MatchSubsetOf = internaldns.MatchSubsetOf
+	// MatchAnyOf requires at least one of the expected records to be present
+	MatchAnyOf = internaldns.MatchAnyOf
+	// MatchNone requires none of the expected records to be present
+	MatchNone = internaldns.MatchNone
+)
+
+// Query fetches the A, AAAA, CNAME, MX, TXT and NS records of the domain from the server, port 53 is used unless
+// the server includes one
+func Query(ctx context.Context, domain string, server string, client Client, opts ...QueryOption) (*Records, error) {
+	return internaldns.QueryDNSContext(ctx, domain, server, client, opts...)
+}
+
+// WithClientSubnet sends an EDNS client subnet such as 203.0.113.0/24 with the queries
+func WithClientSubnet(subnet string) (QueryOption, error) {
+	prefix, err := internaldns.ParseClientSubnet(subnet)
+	if err != nil {
+		return nil, err
+	}
+	return internaldns.WithClientSubnet(prefix), nil
+}
+
+// WithAuthenticatedData sets the AD bit on the queries, so validating resolvers report whether the answers were
+// authenticated
+func WithAuthenticatedData() QueryOption {
+	return internaldns.WithAuthenticatedData()
+}
+
+// Values returns the records of the test type (a, aaaa, cname, mx, txt or ns) in the form they're compared in,
+// MX records include their preference when any of the expected values does
+func Values(records *Records, testType string, expected []string) ([]string, error) {
+	return internaldns.ExtractComparableRecords(records, testType, expected, false)
+}
+
+// Compare compares the actual values with the expected ones without printing anything, expected values may be
+// cidr:, re: or glob: patterns
+func Compare(expected []string, actual []string, opts CompareOptions) *Comparison {
+	return internaldns.DiffRecords(expected, actual, opts)
+}
+
+// ParseConfig decodes and validates a config from YAML or JSON
+func ParseConfig(data []byte) (Config, error) {
+	return cfg.ParseDNSRecordsFullTestConfig(data)
+}
+
+// NewExecutor returns an executor for the config's tests. Like `sherlock dns run` it prints a report of each test
+// while running, set its Output to send the report elsewhere or io.Discard to silence it. The results are also kept
+// in its TestResults
+func NewExecutor(config Config, client Client) *Executor {
+	return dtexc.NewDNSTestExecutor(config, client)
+}
@@ -0,0 +1,106 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ch0ppy35/sherlock/pkg/sherlock/dns/dnstest"
+)
+
+func testResolver() *dnstest.Resolver {
+	return dnstest.NewResolver(
+		dnstest.Records("example.com").
+			A("192.0.2.1", "192.0.2.2").
+			MX(10, "mail.example.com").
+			TXT("v=spf1 -all").
+			Name("www.example.com").CNAME("example.com").
+			Build()...,
+	)
+}
+
+func TestQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		domain   string
+		testType string
+		expected []string
+		values   []string
+	}{
+		{name: "A records", domain: "example.com", testType: "a", values: []string{"192.0.2.1", "192.0.2.2"}},
+		{name: "MX records with preference", domain: "example.com", testType: "mx", expected: []string{"10 mail.example.com."}, values: []string{"10 mail.example.com."}},
+		{name: "TXT records", domain: "example.com", testType: "txt", values: []string{"v=spf1 -all"}},
+		{name: "CNAME records", domain: "www.example.com", testType: "cname", values: []string{"example.com."}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := Query(context.Background(), tt.domain, "192.0.2.53", testResolver())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			values, err := Values(records, tt.testType, tt.expected)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(values, tt.values) {
+				t.Errorf("expected %v, got %v", tt.values, values)
+			}
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	comparison := Compare([]string{"cidr:192.0.2.0/24", "198.51.100.1"}, []string{"192.0.2.1", "203.0.113.1"}, CompareOptions{})
+	if comparison.Passed {
+		t.Errorf("expected the comparison to fail")
+	}
+	if !reflect.DeepEqual(comparison.Missing, []string{"198.51.100.1"}) {
+		t.Errorf("expected 198.51.100.1 to be missing, got %v", comparison.Missing)
+	}
+	if !reflect.DeepEqual(comparison.Unexpected, []string{"203.0.113.1"}) {
+		t.Errorf("expected 203.0.113.1 to be unexpected, got %v", comparison.Unexpected)
+	}
+
+	comparison = Compare([]string{"192.0.2.1"}, []string{"192.0.2.1", "203.0.113.1"}, CompareOptions{Match: MatchContains})
+	if !comparison.Passed || comparison.Mode != MatchContains {
+		t.Errorf("expected the contains comparison to pass, got %v in %s mode", comparison.Reasons, comparison.Mode)
+	}
+}
+
+func TestExecutor(t *testing.T) {
+	config, err := ParseConfig([]byte(`
+dnsServer: 192.0.2.53
+tests:
+  - host: example.com
+    testType: a
+    expectedValues: ["192.0.2.1", "192.0.2.2"]
+  - host: www.example.com
+    testType: cname
+    expectedValues: ["lb.example.com."]
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var output bytes.Buffer
+	executor := NewExecutor(config, testResolver())
+	executor.Output = &output
+	if err := executor.RunAllTests(); err == nil {
+		t.Errorf("expected the CNAME test to fail")
+	}
+	if !strings.Contains(output.String(), "Running tests for host: www.example.com") {
+		t.Errorf("expected the report to be written to Output, got %q", output.String())
+	}
+
+	passed := map[string]bool{}
+	for _, result := range executor.TestResults {
+		passed[result.Host+" "+result.TestType] = result.Passed
+	}
+	expected := map[string]bool{"example.com a": true, "www.example.com cname": false}
+	if !reflect.DeepEqual(passed, expected) {
+		t.Errorf("expected results %v, got %v", expected, passed)
+	}
+}
@@ -0,0 +1,114 @@
+package dnstest
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// DefaultTTL is the TTL of built records unless another one is set
+const DefaultTTL = 300
+
+// RecordBuilder builds records fluently, each record is owned by the name set last. Like the Must functions of
+// the standard library, it panics on invalid values since they're mistakes in the test itself
+type RecordBuilder struct {
+	name    string
+	ttl     uint32
+	records []dns.RR
+}
+
+// Records starts building records owned by the name
+func Records(name string) *RecordBuilder {
+	return &RecordBuilder{name: dns.Fqdn(name), ttl: DefaultTTL}
+}
+
+// Name sets the owner of the records added next
+func (b *RecordBuilder) Name(name string) *RecordBuilder {
+	b.name = dns.Fqdn(name)
+	return b
+}
+
+// TTL sets the TTL of the records added next
+func (b *RecordBuilder) TTL(ttl uint32) *RecordBuilder {
+	b.ttl = ttl
+	return b
+}
+
+// A adds an A record for each IPv4 address
+func (b *RecordBuilder) A(ips ...string) *RecordBuilder {
+	for _, ip := range ips {
+		addr := net.ParseIP(ip)
+		if addr == nil || addr.To4() == nil {
+			panic(fmt.Sprintf("dnstest: '%s' isn't an IPv4 address", ip))
+		}
+		b.records = append(b.records, &dns.A{Hdr: b.header(dns.TypeA), A: addr.To4()})
+	}
+	return b
+}
+
+// AAAA adds an AAAA record for each IPv6 address
+func (b *RecordBuilder) AAAA(ips ...string) *RecordBuilder {
+	for _, ip := range ips {
+		addr := net.ParseIP(ip)
+		if addr == nil || addr.To4() != nil {
+			panic(fmt.Sprintf("dnstest: '%s' isn't an IPv6 address", ip))
+		}
+		b.records = append(b.records, &dns.AAAA{Hdr: b.header(dns.TypeAAAA), AAAA: addr})
+	}
+	return b
+}
+
+// CNAME adds a CNAME record pointing at the target
+func (b *RecordBuilder) CNAME(target string) *RecordBuilder {
+	b.records = append(b.records, &dns.CNAME{Hdr: b.header(dns.TypeCNAME), Target: dns.Fqdn(target)})
+	return b
+}
+
+// MX adds an MX record for the host with the preference
+func (b *RecordBuilder) MX(pref uint16, host string) *RecordBuilder {
+	b.records = append(b.records, &dns.MX{Hdr: b.header(dns.TypeMX), Preference: pref, Mx: dns.Fqdn(host)})
+	return b
+}
+
+// TXT adds a single TXT record made of the character strings, values over 255 bytes are split like servers do
+func (b *RecordBuilder) TXT(segments ...string) *RecordBuilder {
+	var txt []string
+	for _, segment := range segments {
+		for len(segment) > 255 {
+			txt = append(txt, segment[:255])
+			segment = segment[255:]
+		}
+		txt = append(txt, segment)
+	}
+	b.records = append(b.records, &dns.TXT{Hdr: b.header(dns.TypeTXT), Txt: txt})
+	return b
+}
+
+// NS adds an NS record for each host
+func (b *RecordBuilder) NS(hosts ...string) *RecordBuilder {
+	for _, host := range hosts {
+		b.records = append(b.records, &dns.NS{Hdr: b.header(dns.TypeNS), Ns: dns.Fqdn(host)})
+	}
+	return b
+}
+
+// RR adds any other record from its type and data in zone file format, e.g. RR("SRV 10 60 5060 sip.example.com.")
+func (b *RecordBuilder) RR(data string) *RecordBuilder {
+	rr, err := dns.NewRR(fmt.Sprintf("%s %d IN %s", b.name, b.ttl, strings.TrimSpace(data)))
+	if err != nil {
+		panic(fmt.Sprintf("dnstest: invalid record '%s': %v", data, err))
+	}
+	b.records = append(b.records, rr)
+	return b
+}
+
+// Build returns the records added so far
+func (b *RecordBuilder) Build() []dns.RR {
+	return append([]dns.RR(nil), b.records...)
+}
+
+func (b *RecordBuilder) header(rrtype uint16) dns.RR_Header {
+	return dns.RR_Header{Name: b.name, Rrtype: rrtype, Class: dns.ClassINET, Ttl: b.ttl}
+}
@@ -0,0 +1,80 @@
+package dnstest
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRecords(t *testing.T) {
+	long := strings.Repeat("a", 300)
+
+	tests := []struct {
+		name     string
+		builder  *RecordBuilder
+		expected []string
+	}{
+		{
+			name:     "Address records",
+			builder:  Records("example.com").A("192.0.2.1").AAAA("2001:db8::1"),
+			expected: []string{"example.com.\t300\tIN\tA\t192.0.2.1", "example.com.\t300\tIN\tAAAA\t2001:db8::1"},
+		},
+		{
+			name:    "Names and TTLs",
+			builder: Records("example.com").TTL(60).MX(10, "mail.example.com").Name("www.example.com").CNAME("example.com"),
+			expected: []string{
+				"example.com.\t60\tIN\tMX\t10 mail.example.com.",
+				"www.example.com.\t60\tIN\tCNAME\texample.com.",
+			},
+		},
+		{
+			name:     "NS records",
+			builder:  Records("example.com").NS("ns1.example.com", "ns2.example.com."),
+			expected: []string{"example.com.\t300\tIN\tNS\tns1.example.com.", "example.com.\t300\tIN\tNS\tns2.example.com."},
+		},
+		{
+			name:     "Long TXT",
+			builder:  Records("example.com").TXT(long),
+			expected: []string{"example.com.\t300\tIN\tTXT\t\"" + long[:255] + "\" \"" + long[255:] + "\""},
+		},
+		{
+			name:     "Other types",
+			builder:  Records("_sip._tcp.example.com").RR("SRV 10 60 5060 sip.example.com."),
+			expected: []string{"_sip._tcp.example.com.\t300\tIN\tSRV\t10 60 5060 sip.example.com."},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, rr := range tt.builder.Build() {
+				got = append(got, rr.String())
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRecordsPanics(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func()
+	}{
+		{name: "Invalid IPv4", build: func() { Records("example.com").A("2001:db8::1") }},
+		{name: "Invalid IPv6", build: func() { Records("example.com").AAAA("192.0.2.1") }},
+		{name: "Invalid record", build: func() { Records("example.com").RR("SRV nope") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected a panic")
+				}
+			}()
+			tt.build()
+		})
+	}
+}
@@ -0,0 +1,116 @@
+// Package dnstest provides an in-memory resolver and record builders for testing code that queries DNS through
+// sherlock, without any network access.
+//
+//	resolver := dnstest.NewResolver(
+//		dnstest.Records("example.com").A("192.0.2.1").MX(10, "mail.example.com.").Build()...,
+//	)
+//	records, err := dns.Query(ctx, "example.com", "192.0.2.53", resolver)
+package dnstest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// maxCNAMEChain is the number of CNAMEs followed before the resolver answers SERVFAIL
+const maxCNAMEChain = 8
+
+// ExchangeFunc adapts a function to the client interface, for tests that need full control over the responses
+type ExchangeFunc func(msg *dns.Msg, server string) (*dns.Msg, time.Duration, error)
+
+func (f ExchangeFunc) Exchange(msg *dns.Msg, server string) (*dns.Msg, time.Duration, error) {
+	return f(msg, server)
+}
+
+// Resolver answers queries from its records the way a recursive resolver would: CNAMEs are followed, names
+// without any records are NXDOMAIN and names are matched case-insensitively. It's safe for concurrent use
+type Resolver struct {
+	mu       sync.Mutex
+	records  map[string][]dns.RR
+	failures map[string]error
+	queries  []dns.Question
+}
+
+// NewResolver returns a resolver answering from the records
+func NewResolver(records ...dns.RR) *Resolver {
+	r := &Resolver{records: make(map[string][]dns.RR), failures: make(map[string]error)}
+	return r.Add(records...)
+}
+
+// Add adds records to the resolver
+func (r *Resolver) Add(records ...dns.RR) *Resolver {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rr := range records {
+		name := dns.CanonicalName(rr.Header().Name)
+		r.records[name] = append(r.records[name], rr)
+	}
+	return r
+}
+
+// Fail makes every query for the name return the error, as if the server couldn't be reached
+func (r *Resolver) Fail(name string, err error) *Resolver {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures[dns.CanonicalName(name)] = err
+	return r
+}
+
+// Queries returns the questions asked so far, in order
+func (r *Resolver) Queries() []dns.Question {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]dns.Question(nil), r.queries...)
+}
+
+func (r *Resolver) Exchange(msg *dns.Msg, server string) (*dns.Msg, time.Duration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	resp := new(dns.Msg)
+	resp.SetReply(msg)
+	resp.RecursionAvailable = true
+	if len(msg.Question) != 1 {
+		resp.Rcode = dns.RcodeFormatError
+		return resp, 0, nil
+	}
+	question := msg.Question[0]
+	r.queries = append(r.queries, question)
+
+	name := dns.CanonicalName(question.Name)
+	if err, ok := r.failures[name]; ok {
+		return nil, 0, err
+	}
+
+	for i := 0; i <= maxCNAMEChain; i++ {
+		records, ok := r.records[name]
+		if !ok {
+			// Like a resolver, NXDOMAIN is for the last name in the chain
+			resp.Rcode = dns.RcodeNameError
+			return resp, 0, nil
+		}
+
+		answered := false
+		var cname *dns.CNAME
+		for _, rr := range records {
+			switch {
+			case rr.Header().Rrtype == question.Qtype:
+				resp.Answer = append(resp.Answer, dns.Copy(rr))
+				answered = true
+			case rr.Header().Rrtype == dns.TypeCNAME:
+				cname = rr.(*dns.CNAME)
+			}
+		}
+		if answered || cname == nil {
+			return resp, 0, nil
+		}
+
+		resp.Answer = append(resp.Answer, dns.Copy(cname))
+		name = dns.CanonicalName(cname.Target)
+	}
+	resp.Rcode = dns.RcodeServerFailure
+	resp.Answer = nil
+	return resp, 0, nil
+}
@@ -0,0 +1,152 @@
+package dnstest
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestResolver(t *testing.T) {
+	resolver := NewResolver(
+		Records("example.com").A("192.0.2.1", "192.0.2.2").MX(10, "mail.example.com").Build()...,
+	).Add(
+		Records("www.example.com").CNAME("lb.example.com").
+			Name("lb.example.com").A("192.0.2.10").
+			Name("dangling.example.com").CNAME("gone.example.com").
+			Name("loop1.example.com").CNAME("loop2.example.com").
+			Name("loop2.example.com").CNAME("loop1.example.com").
+			Build()...,
+	).Fail("broken.example.com", fmt.Errorf("connection refused"))
+
+	tests := []struct {
+		name          string
+		qname         string
+		qtype         uint16
+		expectedRcode int
+		expectedRRs   []string
+		expectError   bool
+	}{
+		{
+			name:          "Answer",
+			qname:         "example.com.",
+			qtype:         dns.TypeA,
+			expectedRcode: dns.RcodeSuccess,
+			expectedRRs:   []string{"example.com.\t300\tIN\tA\t192.0.2.1", "example.com.\t300\tIN\tA\t192.0.2.2"},
+		},
+		{
+			name:          "Case insensitive",
+			qname:         "EXAMPLE.com.",
+			qtype:         dns.TypeMX,
+			expectedRcode: dns.RcodeSuccess,
+			expectedRRs:   []string{"example.com.\t300\tIN\tMX\t10 mail.example.com."},
+		},
+		{
+			name:          "No data",
+			qname:         "example.com.",
+			qtype:         dns.TypeAAAA,
+			expectedRcode: dns.RcodeSuccess,
+		},
+		{
+			name:          "CNAME followed",
+			qname:         "www.example.com.",
+			qtype:         dns.TypeA,
+			expectedRcode: dns.RcodeSuccess,
+			expectedRRs: []string{
+				"www.example.com.\t300\tIN\tCNAME\tlb.example.com.",
+				"lb.example.com.\t300\tIN\tA\t192.0.2.10",
+			},
+		},
+		{
+			name:          "CNAME queried",
+			qname:         "www.example.com.",
+			qtype:         dns.TypeCNAME,
+			expectedRcode: dns.RcodeSuccess,
+			expectedRRs:   []string{"www.example.com.\t300\tIN\tCNAME\tlb.example.com."},
+		},
+		{
+			name:          "Dangling CNAME",
+			qname:         "dangling.example.com.",
+			qtype:         dns.TypeA,
+			expectedRcode: dns.RcodeNameError,
+			expectedRRs:   []string{"dangling.example.com.\t300\tIN\tCNAME\tgone.example.com."},
+		},
+		{
+			name:          "CNAME loop",
+			qname:         "loop1.example.com.",
+			qtype:         dns.TypeA,
+			expectedRcode: dns.RcodeServerFailure,
+		},
+		{
+			name:          "Unknown name",
+			qname:         "missing.example.com.",
+			qtype:         dns.TypeA,
+			expectedRcode: dns.RcodeNameError,
+		},
+		{
+			name:        "Failure",
+			qname:       "broken.example.com.",
+			qtype:       dns.TypeA,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := new(dns.Msg)
+			msg.SetQuestion(tt.qname, tt.qtype)
+			resp, _, err := resolver.Exchange(msg, "192.0.2.53:53")
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.Rcode != tt.expectedRcode {
+				t.Errorf("expected rcode %s, got %s", dns.RcodeToString[tt.expectedRcode], dns.RcodeToString[resp.Rcode])
+			}
+			if resp.Id != msg.Id || !resp.Response || !resp.RecursionAvailable {
+				t.Errorf("expected a reply to the query with RA set, got %+v", resp.MsgHdr)
+			}
+			var rrs []string
+			for _, rr := range resp.Answer {
+				rrs = append(rrs, rr.String())
+			}
+			if !reflect.DeepEqual(rrs, tt.expectedRRs) {
+				t.Errorf("expected answer %q, got %q", tt.expectedRRs, rrs)
+			}
+		})
+	}
+
+	queries := resolver.Queries()
+	if len(queries) != len(tests) {
+		t.Fatalf("expected %d queries to be recorded, got %d", len(tests), len(queries))
+	}
+	if queries[1].Name != "EXAMPLE.com." || queries[1].Qtype != dns.TypeMX {
+		t.Errorf("expected the second query to be EXAMPLE.com. MX, got %s", queries[1].String())
+	}
+}
+
+func TestResolverCopiesRecords(t *testing.T) {
+	resolver := NewResolver(Records("example.com").A("192.0.2.1").Build()...)
+	msg := new(dns.Msg)
+	msg.SetQuestion("example.com.", dns.TypeA)
+
+	resp, _, err := resolver.Exchange(msg, "192.0.2.53:53")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Answer[0].(*dns.A).A[3] = 99
+
+	resp, _, err = resolver.Exchange(msg, "192.0.2.53:53")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := resp.Answer[0].(*dns.A).A.String(); got != "192.0.2.1" {
+		t.Errorf("expected changes to an answer not to leak into the resolver, got %s", got)
+	}
+}